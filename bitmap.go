@@ -0,0 +1,310 @@
+package opendb
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// BitUnit the unit of the range in BitCount and BitPos.
+type BitUnit uint8
+
+const (
+	// BitUnitByte start and end are byte offsets.
+	BitUnitByte BitUnit = iota
+	// BitUnitBit start and end are bit offsets.
+	BitUnitBit
+)
+
+// BitOperation the operation of BitOp.
+type BitOperation uint8
+
+const (
+	BitAnd BitOperation = iota
+	BitOr
+	BitXor
+	BitNot
+)
+
+// the max bit offset of a bitmap, same as redis (512MB).
+const maxBitOffset = 1<<32 - 1
+
+// SetBit sets or clears the bit at offset in the string value stored at key, bit must be 0 or 1.
+// The string is grown to make sure it can hold a bit at offset, the grown part is padded with zero-bytes.
+// Returns the original bit value stored at offset.
+func (db *OpenDB) SetBit(key interface{}, offset int, bit int) (int, error) {
+	if offset < 0 || offset > maxBitOffset {
+		return 0, ErrInvalidOffset
+	}
+	if bit != 0 && bit != 1 {
+		return 0, ErrInvalidBit
+	}
+	encKey, err := db.encodeKey(key)
+	if err != nil {
+		return 0, err
+	}
+	if err := db.checkKeyValue(encKey, nil); err != nil {
+		return 0, err
+	}
+
+	db.strIndex.mu.Lock()
+	defer db.strIndex.mu.Unlock()
+
+	existVal, err := db.getVal(encKey)
+	if err != nil {
+		if err != ErrKeyNotExist && err != ErrKeyExpired {
+			return 0, err
+		}
+		existVal = nil
+	}
+
+	byteIdx := offset >> 3
+	mask := byte(1 << (7 - uint(offset&7)))
+	var b byte
+	if byteIdx < len(existVal) {
+		b = existVal[byteIdx]
+	}
+	old := 0
+	if b&mask != 0 {
+		old = 1
+	}
+
+	if bit == 1 {
+		b |= mask
+	} else {
+		b &^= mask
+	}
+	// nothing changed, and the string need not to grow.
+	if old == bit && byteIdx < len(existVal) {
+		return old, nil
+	}
+
+	// only the modified byte is written.
+	if _, err := db.writeRange(encKey, existVal, byteIdx, []byte{b}); err != nil {
+		return 0, err
+	}
+	return old, nil
+}
+
+// GetBit returns the bit value at offset in the string value stored at key.
+// When offset is beyond the string length, or the key does not exist, 0 is returned.
+func (db *OpenDB) GetBit(key interface{}, offset int) (int, error) {
+	if offset < 0 || offset > maxBitOffset {
+		return 0, ErrInvalidOffset
+	}
+	val, err := db.getBitmap(key)
+	if err != nil {
+		return 0, err
+	}
+
+	byteIdx := offset >> 3
+	if byteIdx >= len(val) {
+		return 0, nil
+	}
+	if val[byteIdx]&(1<<(7-uint(offset&7))) != 0 {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// BitCount count the number of set bits in the string value stored at key, between start and end (both are inclusive).
+// The unit of start and end is specified by unit, negative values can be used to count from the end of the string.
+// Use 0 and -1 to count all the bits.
+func (db *OpenDB) BitCount(key interface{}, start, end int, unit BitUnit) (int, error) {
+	val, err := db.getBitmap(key)
+	if err != nil {
+		return 0, err
+	}
+
+	startBit, endBit, ok := bitRange(len(val), start, end, unit)
+	if !ok {
+		return 0, nil
+	}
+
+	first, last := startBit>>3, endBit>>3
+	headMask := byte(0xff >> uint(startBit&7))
+	tailMask := byte(0xff << uint(7-endBit&7))
+	if first == last {
+		return bits.OnesCount8(val[first] & headMask & tailMask), nil
+	}
+
+	count := bits.OnesCount8(val[first]&headMask) + bits.OnesCount8(val[last]&tailMask)
+	return count + popCount(val[first+1:last]), nil
+}
+
+// BitPos returns the position of the first bit set to 1 or 0 in the string value stored at key, between start and end (both are inclusive).
+// The unit of start and end is specified by unit, negative values can be used to count from the end of the string.
+// The returned position is the absolute bit offset of the string. If no such bit is found, -1 is returned.
+// If the key does not exist, it is considered as an empty string, so 0 is returned when looking for a clear bit.
+func (db *OpenDB) BitPos(key interface{}, bit int, start, end int, unit BitUnit) (int, error) {
+	if bit != 0 && bit != 1 {
+		return 0, ErrInvalidBit
+	}
+	val, err := db.getBitmap(key)
+	if err != nil {
+		return 0, err
+	}
+	if len(val) == 0 {
+		if bit == 0 {
+			return 0, nil
+		}
+		return -1, nil
+	}
+
+	startBit, endBit, ok := bitRange(len(val), start, end, unit)
+	if !ok {
+		return -1, nil
+	}
+
+	first, last := startBit>>3, endBit>>3
+	for i := first; i <= last; i++ {
+		b := val[i]
+		if bit == 0 {
+			b = ^b
+		}
+		if i == first {
+			b &= 0xff >> uint(startBit&7)
+		}
+		if i == last {
+			b &= 0xff << uint(7-endBit&7)
+		}
+		if b != 0 {
+			return i<<3 + bits.LeadingZeros8(b), nil
+		}
+	}
+	return -1, nil
+}
+
+// BitOp perform a bitwise operation between the strings stored at keys and store the result in the destination key.
+// BitNot takes exactly one key. Keys that do not exist are considered as strings of zero-bytes,
+// and the shorter strings are padded with zero-bytes up to the length of the longest one.
+// If the result is an empty string, the destination key is removed. Returns the length of the result.
+func (db *OpenDB) BitOp(op BitOperation, destKey interface{}, keys ...interface{}) (int, error) {
+	if len(keys) == 0 || (op == BitNot && len(keys) != 1) {
+		return 0, ErrWrongNumberOfArgs
+	}
+	encKeys, err := db.encodeKeys(append([]interface{}{destKey}, keys...))
+	if err != nil {
+		return 0, err
+	}
+	encDest := encKeys[0]
+	encKeys = encKeys[1:]
+
+	db.strIndex.mu.Lock()
+	defer db.strIndex.mu.Unlock()
+
+	vals := make([][]byte, 0, len(encKeys))
+	maxLen := 0
+	for _, encKey := range encKeys {
+		val, err := db.getVal(encKey)
+		if err != nil && err != ErrKeyNotExist && err != ErrKeyExpired {
+			return 0, err
+		}
+		if err != nil {
+			val = nil
+		}
+		if len(val) > maxLen {
+			maxLen = len(val)
+		}
+		vals = append(vals, val)
+	}
+
+	if maxLen == 0 {
+		if db.strExists(encDest) {
+			return 0, db.removeVal(encDest)
+		}
+		return 0, nil
+	}
+
+	res := make([]byte, maxLen)
+	copy(res, vals[0])
+	switch op {
+	case BitNot:
+		for i := range res {
+			res[i] = ^res[i]
+		}
+	case BitAnd:
+		for _, val := range vals[1:] {
+			for i := range res {
+				if i < len(val) {
+					res[i] &= val[i]
+				} else {
+					res[i] = 0
+				}
+			}
+		}
+	case BitOr:
+		for _, val := range vals[1:] {
+			for i := range val {
+				res[i] |= val[i]
+			}
+		}
+	case BitXor:
+		for _, val := range vals[1:] {
+			for i := range val {
+				res[i] ^= val[i]
+			}
+		}
+	}
+	return len(res), db.putVal(encDest, res)
+}
+
+// get the string value stored at key, an empty value is returned if the key does not exist.
+func (db *OpenDB) getBitmap(key interface{}) ([]byte, error) {
+	encKey, err := db.encodeKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.checkKeyValue(encKey, nil); err != nil {
+		return nil, err
+	}
+
+	db.strIndex.mu.RLock()
+	defer db.strIndex.mu.RUnlock()
+
+	val, err := db.getVal(encKey)
+	if err == ErrKeyNotExist || err == ErrKeyExpired {
+		return nil, nil
+	}
+	return val, err
+}
+
+// bitRange convert start and end to bit offsets of a string with length bytes,
+// ok is false if the range is empty.
+func bitRange(length, start, end int, unit BitUnit) (startBit, endBit int, ok bool) {
+	total := length
+	if unit == BitUnitBit {
+		total = length << 3
+	}
+	if start < 0 {
+		start += total
+	}
+	if end < 0 {
+		end += total
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end >= total {
+		end = total - 1
+	}
+	if start > end || start >= total {
+		return 0, 0, false
+	}
+
+	if unit == BitUnitBit {
+		return start, end, true
+	}
+	return start << 3, end<<3 + 7, true
+}
+
+// count the set bits of the bytes, 8 bytes at a time.
+func popCount(b []byte) (count int) {
+	for len(b) >= 8 {
+		count += bits.OnesCount64(binary.LittleEndian.Uint64(b))
+		b = b[8:]
+	}
+	for _, v := range b {
+		count += bits.OnesCount8(v)
+	}
+	return
+}
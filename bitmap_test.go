@@ -0,0 +1,210 @@
+package opendb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenDB_SetBit(t *testing.T) {
+	for _, mode := range []DataIndexMode{KeyValueMemMode, KeyOnlyMemMode} {
+		opts := DefaultOptions(t.TempDir())
+		opts.IdxMode = mode
+		db, err := Open(opts)
+		assert.Nil(t, err)
+
+		_, err = db.SetBit("bit_key", -1, 1)
+		assert.Equal(t, ErrInvalidOffset, err)
+		_, err = db.SetBit("bit_key", 0, 2)
+		assert.Equal(t, ErrInvalidBit, err)
+
+		old, err := db.SetBit("bit_key", 7, 1)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, old)
+		old, err = db.SetBit("bit_key", 7, 0)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, old)
+		_, err = db.SetBit("bit_key", 100, 1)
+		assert.Nil(t, err)
+		assert.Equal(t, 13, db.StrLen("bit_key"))
+
+		// "a" is 01100001
+		assert.Nil(t, db.Set("a_key", "a"))
+		_, err = db.SetBit("a_key", 6, 1)
+		assert.Nil(t, err)
+		_, err = db.SetBit("a_key", 7, 0)
+		assert.Nil(t, err)
+
+		db, err = Open(opts)
+		assert.Nil(t, err)
+		var val string
+		assert.Nil(t, db.Get("a_key", &val))
+		assert.Equal(t, "b", val)
+
+		for offset, want := range map[int]int{7: 0, 100: 1, 99: 0, 1000: 0} {
+			bit, err := db.GetBit("bit_key", offset)
+			assert.Nil(t, err)
+			assert.Equal(t, want, bit)
+		}
+	}
+}
+
+func TestOpenDB_BitCount(t *testing.T) {
+	db := openTestDB(t)
+	assert.Nil(t, db.Set("bc_key", "foobar"))
+
+	tests := []struct {
+		start, end int
+		unit       BitUnit
+		want       int
+	}{
+		{0, -1, BitUnitByte, 26},
+		{0, 0, BitUnitByte, 4},
+		{1, 1, BitUnitByte, 6},
+		{1, 1, BitUnitBit, 1},
+		{5, 30, BitUnitBit, 17},
+		{-2, -1, BitUnitByte, 7},
+		{10, 20, BitUnitByte, 0},
+	}
+	for _, tt := range tests {
+		n, err := db.BitCount("bc_key", tt.start, tt.end, tt.unit)
+		assert.Nil(t, err)
+		assert.Equal(t, tt.want, n)
+	}
+
+	n, err := db.BitCount("not_exist", 0, -1, BitUnitByte)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, n)
+}
+
+func TestOpenDB_BitPos(t *testing.T) {
+	db := openTestDB(t)
+	assert.Nil(t, db.Set("bp_key", []byte{0xff, 0xf0, 0x00}))
+
+	tests := []struct {
+		bit, start, end int
+		unit            BitUnit
+		want            int
+	}{
+		{0, 0, -1, BitUnitByte, 12},
+		{1, 0, -1, BitUnitByte, 0},
+		{1, 2, -1, BitUnitByte, -1},
+		{0, 2, -1, BitUnitByte, 16},
+		{1, 7, 15, BitUnitBit, 7},
+		{0, 0, 11, BitUnitBit, -1},
+		{1, 12, 23, BitUnitBit, -1},
+	}
+	for _, tt := range tests {
+		pos, err := db.BitPos("bp_key", tt.bit, tt.start, tt.end, tt.unit)
+		assert.Nil(t, err)
+		assert.Equal(t, tt.want, pos)
+	}
+
+	pos, err := db.BitPos("not_exist", 0, 0, -1, BitUnitByte)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, pos)
+	pos, err = db.BitPos("not_exist", 1, 0, -1, BitUnitByte)
+	assert.Nil(t, err)
+	assert.Equal(t, -1, pos)
+}
+
+func TestOpenDB_BitOp(t *testing.T) {
+	db := openTestDB(t)
+	assert.Nil(t, db.Set("op_key1", []byte{0xf0, 0x0f}))
+	assert.Nil(t, db.Set("op_key2", []byte{0x3c}))
+
+	tests := []struct {
+		op   BitOperation
+		keys []interface{}
+		want []byte
+	}{
+		{BitAnd, []interface{}{"op_key1", "op_key2"}, []byte{0x30, 0x00}},
+		{BitOr, []interface{}{"op_key1", "op_key2"}, []byte{0xfc, 0x0f}},
+		{BitXor, []interface{}{"op_key1", "op_key2"}, []byte{0xcc, 0x0f}},
+		{BitNot, []interface{}{"op_key1"}, []byte{0x0f, 0xf0}},
+	}
+	for _, tt := range tests {
+		n, err := db.BitOp(tt.op, "op_dest", tt.keys...)
+		assert.Nil(t, err)
+		assert.Equal(t, len(tt.want), n)
+
+		var val []byte
+		assert.Nil(t, db.Get("op_dest", &val))
+		assert.Equal(t, tt.want, val)
+	}
+
+	_, err := db.BitOp(BitNot, "op_dest", "op_key1", "op_key2")
+	assert.Equal(t, ErrWrongNumberOfArgs, err)
+
+	n, err := db.BitOp(BitOr, "op_dest", "not_exist")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, n)
+	assert.False(t, db.StrExists("op_dest"))
+}
+
+// bitmapSize the size of the bitmaps in benchmarks, 4MB.
+const bitmapSize = 4 << 20
+
+func openBenchDB(b *testing.B) *OpenDB {
+	opts := DefaultOptions(b.TempDir())
+	opts.IdxMode = KeyValueMemMode
+	db, err := Open(opts)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return db
+}
+
+func BenchmarkOpenDB_SetBit(b *testing.B) {
+	db := openBenchDB(b)
+	if _, err := db.SetBit("bench_bitmap", bitmapSize*8-1, 1); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := db.SetBit("bench_bitmap", (i*7919)%(bitmapSize*8), i&1); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkOpenDB_BitCount(b *testing.B) {
+	db := openBenchDB(b)
+	val := make([]byte, bitmapSize)
+	for i := range val {
+		val[i] = byte(i)
+	}
+	if err := db.Set("bench_bitmap", val); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := db.BitCount("bench_bitmap", 0, -1, BitUnitByte); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkOpenDB_BitOp(b *testing.B) {
+	db := openBenchDB(b)
+	val := make([]byte, bitmapSize)
+	for i := range val {
+		val[i] = byte(i)
+	}
+	if err := db.Set("bench_bitmap1", val); err != nil {
+		b.Fatal(err)
+	}
+	if err := db.Set("bench_bitmap2", val[:bitmapSize/2]); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := db.BitOp(BitAnd, "bench_dest", "bench_bitmap1", "bench_bitmap2"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -1,13 +1,32 @@
 package hash
 
+import (
+	"math/rand"
+
+	"opendb/util"
+)
+
 type (
 	//使用go语言的map构建一个hash表
 	Hash struct {
 		record Record
 	}
 
-	// 二维map map+map+[]byte
-	Record map[string]map[string][]byte
+	// 二维map map+fields
+	Record map[string]*fields
+
+	// fields the fields of a hash. A small hash is packed in a listpack, and it is converted to a map when it grows.
+	// Besides the map, the entries are kept in a slice, so the hash can be scanned by the positions in the slice.
+	fields struct {
+		lp      *listpack
+		index   map[string]int
+		entries []entry
+	}
+
+	entry struct {
+		field string
+		value []byte
+	}
 )
 
 // New create a new hash ds.
@@ -19,15 +38,10 @@ func New() *Hash {
 // If field already exists in the hash, it is overwritten.
 func (h *Hash) HSet(key string, field string, value []byte) (res int) {
 	if !h.exist(key) {
-		h.record[key] = make(map[string][]byte)
+		h.record[key] = newFields()
 	}
 
-	if h.record[key][field] != nil {
-		// if this field exists, overwritten it.
-		h.record[key][field] = value
-	} else {
-		// create if this field not exists.
-		h.record[key][field] = value
+	if h.record[key].set(field, value) {
 		res = 1
 	}
 	return
@@ -37,11 +51,11 @@ func (h *Hash) HSet(key string, field string, value []byte) (res int) {
 // 如果字段已经存在于哈希表中，操作无效。
 func (h *Hash) HSetNx(key string, field string, value []byte) int {
 	if !h.exist(key) {
-		h.record[key] = make(map[string][]byte)
+		h.record[key] = newFields()
 	}
 
-	if _, exist := h.record[key][field]; !exist {
-		h.record[key][field] = value
+	if _, exist := h.record[key].get(field); !exist {
+		h.record[key].set(field, value)
 		return 1
 	}
 	return 0
@@ -53,7 +67,8 @@ func (h *Hash) HGet(key, field string) []byte {
 		return nil
 	}
 
-	return h.record[key][field]
+	val, _ := h.record[key].get(field)
+	return val
 }
 
 //获取在哈希表中指定 key 的所有字段和值
@@ -62,8 +77,8 @@ func (h *Hash) HGetAll(key string) (res [][]byte) {
 		return
 	}
 
-	for k, v := range h.record[key] {
-		res = append(res, []byte(k), v)
+	for _, e := range h.record[key].list() {
+		res = append(res, []byte(e.field), e.value)
 	}
 	return
 }
@@ -75,8 +90,7 @@ func (h *Hash) HDel(key, field string) int {
 		return 0
 	}
 
-	if _, exist := h.record[key][field]; exist {
-		delete(h.record[key], field)
+	if h.record[key].del(field) {
 		return 1
 	}
 	return 0
@@ -93,9 +107,7 @@ func (h *Hash) HExists(key, field string) (ok bool) {
 		return
 	}
 
-	if _, exist := h.record[key][field]; exist {
-		ok = true
-	}
+	_, ok = h.record[key].get(field)
 	return
 }
 
@@ -104,7 +116,7 @@ func (h *Hash) HLen(key string) int {
 	if !h.exist(key) {
 		return 0
 	}
-	return len(h.record[key])
+	return h.record[key].len()
 }
 
 // HKeys returns all field names in the hash stored at key.
@@ -113,8 +125,8 @@ func (h *Hash) HKeys(key string) (val []string) {
 		return
 	}
 
-	for k := range h.record[key] {
-		val = append(val, k)
+	for _, e := range h.record[key].list() {
+		val = append(val, e.field)
 	}
 	return
 }
@@ -125,12 +137,70 @@ func (h *Hash) HVals(key string) (val [][]byte) {
 		return
 	}
 
-	for _, v := range h.record[key] {
-		val = append(val, v)
+	for _, e := range h.record[key].list() {
+		val = append(val, e.value)
+	}
+	return
+}
+
+// HStrLen returns the length of the value associated with field in the hash stored at key.
+// If the key or the field do not exist, 0 is returned.
+func (h *Hash) HStrLen(key, field string) int {
+	return len(h.HGet(key, field))
+}
+
+// HRandField returns random fields from the hash stored at key.
+// If count is positive, at most count distinct fields are returned.
+// If count is negative, -count fields are returned, and the same field may be returned multiple times.
+// If withValues is true, every field is followed by its value.
+func (h *Hash) HRandField(key string, count int, withValues bool) (res [][]byte) {
+	if !h.exist(key) || count == 0 {
+		return
+	}
+
+	// the entries of the map form are sampled in place like HScan, a listpack is small enough to be decoded.
+	entries := h.record[key].list()
+	var picked []int
+	if count > 0 {
+		picked = util.RandDistinct(len(entries), count)
+	} else {
+		for i := 0; i < -count; i++ {
+			picked = append(picked, rand.Intn(len(entries)))
+		}
+	}
+
+	for _, i := range picked {
+		res = append(res, []byte(entries[i].field))
+		if withValues {
+			res = append(res, entries[i].value)
+		}
 	}
 	return
 }
 
+// HScan iterates the fields of the hash stored at key, every field in res is followed by its value.
+// Start with cursor 0 and call HScan with the returned cursor until it returns 0.
+// count is the number of fields examined in a call, only the fields matching the glob-style pattern are returned, an empty pattern matches all.
+// A field present for the whole iteration is returned at least once, and it may be returned more than once.
+// A hash packed in a listpack is returned in a single call.
+func (h *Hash) HScan(key string, cursor int, match string, count int) (next int, res [][]byte) {
+	if !h.exist(key) {
+		return
+	}
+
+	entries := h.record[key].list()
+	start, end := util.ScanRange(len(entries), cursor, count)
+	if h.record[key].lp != nil {
+		start, end = 0, len(entries)
+	}
+	for i := end - 1; i >= start; i-- {
+		if match == "" || util.GlobMatch(match, entries[i].field) {
+			res = append(res, []byte(entries[i].field), entries[i].value)
+		}
+	}
+	return start, res
+}
+
 // HClear clear the key in hash.
 func (h *Hash) HClear(key string) {
 	if !h.exist(key) {
@@ -143,3 +213,86 @@ func (h *Hash) exist(key string) bool {
 	_, exist := h.record[key]
 	return exist
 }
+
+func newFields() *fields {
+	return &fields{lp: new(listpack)}
+}
+
+func (f *fields) len() int {
+	if f.lp != nil {
+		return f.lp.n
+	}
+	return len(f.entries)
+}
+
+// list returns the entries in order, the entries of a listpack are copies.
+func (f *fields) list() []entry {
+	if f.lp != nil {
+		return f.lp.entries()
+	}
+	return f.entries
+}
+
+func (f *fields) get(field string) ([]byte, bool) {
+	if f.lp != nil {
+		return f.lp.get(field)
+	}
+	i, ok := f.index[field]
+	if !ok {
+		return nil, false
+	}
+	return f.entries[i].value, true
+}
+
+// set returns true if the field is new.
+func (f *fields) set(field string, value []byte) bool {
+	if f.lp != nil && f.lp.fits(field, value) {
+		if f.lp.n < maxListpackEntries {
+			return f.lp.set(field, value)
+		}
+		if _, _, _, ok := f.lp.find(field); ok {
+			return f.lp.set(field, value)
+		}
+	}
+	if f.lp != nil {
+		f.convert()
+	}
+
+	if i, ok := f.index[field]; ok {
+		f.entries[i].value = value
+		return false
+	}
+	f.index[field] = len(f.entries)
+	f.entries = append(f.entries, entry{field: field, value: value})
+	return true
+}
+
+// del removes the field, the last entry is moved to its position.
+func (f *fields) del(field string) bool {
+	if f.lp != nil {
+		return f.lp.del(field)
+	}
+	i, ok := f.index[field]
+	if !ok {
+		return false
+	}
+	last := len(f.entries) - 1
+	if i != last {
+		f.entries[i] = f.entries[last]
+		f.index[f.entries[i].field] = i
+	}
+	f.entries[last] = entry{}
+	f.entries = f.entries[:last]
+	delete(f.index, field)
+	return true
+}
+
+// convert converts the listpack to a map, the entries keep their order.
+func (f *fields) convert() {
+	f.entries = f.lp.entries()
+	f.index = make(map[string]int, len(f.entries))
+	for i, e := range f.entries {
+		f.index[e.field] = i
+	}
+	f.lp = nil
+}
@@ -1,6 +1,7 @@
 package hash
 import (
 	"github.com/stretchr/testify/assert"
+	"runtime"
 	"strconv"
 	"testing"
 )
@@ -132,3 +133,161 @@ func TestHash_HKeyExists(t *testing.T) {
 	assert.Equal(t, exists1, false)
 }
 
+
+func TestHash_HStrLen(t *testing.T) {
+	hash := InitHash()
+	assert.Equal(t, 13, hash.HStrLen(key, "a"))
+	assert.Equal(t, 0, hash.HStrLen(key, "z"))
+	assert.Equal(t, 0, hash.HStrLen("no", "a"))
+}
+
+func TestHash_HRandField(t *testing.T) {
+	hash := InitHash()
+
+	fields := hash.HRandField(key, 2, false)
+	assert.Equal(t, 2, len(fields))
+	assert.NotEqual(t, fields[0], fields[1])
+
+	// count is greater than the size of the hash.
+	fields = hash.HRandField(key, 10, false)
+	assert.Equal(t, 3, len(fields))
+
+	// the fields may be repeated if count is negative.
+	fields = hash.HRandField(key, -10, false)
+	assert.Equal(t, 10, len(fields))
+
+	pairs := hash.HRandField(key, 3, true)
+	assert.Equal(t, 6, len(pairs))
+	for i := 0; i < len(pairs); i += 2 {
+		assert.Equal(t, hash.HGet(key, string(pairs[i])), pairs[i+1])
+	}
+
+	assert.Nil(t, hash.HRandField(key, 0, false))
+	assert.Nil(t, hash.HRandField("no", 1, false))
+}
+
+func TestHash_HRandFieldLarge(t *testing.T) {
+	hash := New()
+	for i := 0; i < 1000; i++ {
+		hash.HSet("big", strconv.Itoa(i), []byte(strconv.Itoa(i)))
+	}
+	assert.Nil(t, hash.record["big"].lp)
+
+	// the fields are distinct and sampled from the whole hash.
+	seen := make(map[string]bool)
+	for _, f := range hash.HRandField("big", 1000, false) {
+		seen[string(f)] = true
+	}
+	assert.Equal(t, 1000, len(seen))
+
+	pairs := hash.HRandField("big", 10, true)
+	assert.Equal(t, 20, len(pairs))
+	for i := 0; i < len(pairs); i += 2 {
+		assert.Equal(t, pairs[i], pairs[i+1])
+	}
+}
+
+func TestHash_HScan(t *testing.T) {
+	// a small hash is returned in a single call.
+	hash := InitHash()
+	cursor, res := hash.HScan(key, 0, "[ab]", 1)
+	assert.Equal(t, 0, cursor)
+	assert.Equal(t, [][]byte{[]byte("b"), []byte("hash_data_002"), []byte("a"), []byte("hash_data_001")}, res)
+
+	defer func(n int) { maxListpackEntries = n }(maxListpackEntries)
+	maxListpackEntries = 0
+
+	hash = New()
+	for i := 0; i < 10; i++ {
+		hash.HSet(key, strconv.Itoa(i), []byte("v"+strconv.Itoa(i)))
+	}
+
+	// the fields are scanned from the last position.
+	cursor, res = hash.HScan(key, 0, "", 4)
+	assert.Equal(t, 6, cursor)
+	assert.Equal(t, [][]byte{[]byte("9"), []byte("v9"), []byte("8"), []byte("v8"), []byte("7"), []byte("v7"), []byte("6"), []byte("v6")}, res)
+
+	// 9 is moved to the position of 0, so it is returned again.
+	hash.HDel(key, "0")
+	cursor, res = hash.HScan(key, cursor, "[1-4]", 4)
+	assert.Equal(t, 2, cursor)
+	assert.Equal(t, [][]byte{[]byte("4"), []byte("v4"), []byte("3"), []byte("v3"), []byte("2"), []byte("v2")}, res)
+
+	cursor, res = hash.HScan(key, cursor, "", 4)
+	assert.Equal(t, 0, cursor)
+	assert.Equal(t, [][]byte{[]byte("1"), []byte("v1"), []byte("9"), []byte("v9")}, res)
+}
+
+func TestHash_Listpack(t *testing.T) {
+	hash := New()
+	for i := 0; i < maxListpackEntries; i++ {
+		hash.HSet(key, strconv.Itoa(i), []byte(strconv.Itoa(i)))
+	}
+	assert.NotNil(t, hash.record[key].lp)
+
+	// overwrite with values of another length, the fields keep their order.
+	assert.Equal(t, 0, hash.HSet(key, "0", []byte("zero")))
+	assert.Equal(t, 0, hash.HSet(key, "1", nil))
+	assert.Equal(t, []byte("zero"), hash.HGet(key, "0"))
+	assert.Equal(t, 0, hash.HStrLen(key, "1"))
+	assert.Equal(t, []byte("2"), hash.HGet(key, "2"))
+	assert.Equal(t, 1, hash.HDel(key, "2"))
+	assert.False(t, hash.HExists(key, "2"))
+	assert.Equal(t, maxListpackEntries-1, hash.HLen(key))
+	assert.Equal(t, []string{"0", "1", "3"}, hash.HKeys(key)[:3])
+
+	// the returned values are copies.
+	v := hash.HGet(key, "0")
+	v[0] = 'x'
+	assert.Equal(t, []byte("zero"), hash.HGet(key, "0"))
+
+	// converted to a map when there are too many fields.
+	assert.Equal(t, 1, hash.HSet(key, "2", []byte("2")))
+	assert.NotNil(t, hash.record[key].lp)
+	assert.Equal(t, 1, hash.HSet(key, "new", []byte("new")))
+	assert.Nil(t, hash.record[key].lp)
+	assert.Equal(t, maxListpackEntries+1, hash.HLen(key))
+	assert.Equal(t, []string{"0", "1", "3"}, hash.HKeys(key)[:3])
+	assert.Equal(t, []byte("zero"), hash.HGet(key, "0"))
+
+	// or when a value is too large.
+	hash.HSet("large", "a", []byte("a"))
+	assert.NotNil(t, hash.record["large"].lp)
+	hash.HSet("large", "b", make([]byte, maxListpackValueSize+1))
+	assert.Nil(t, hash.record["large"].lp)
+	assert.Equal(t, []byte("a"), hash.HGet("large", "a"))
+}
+
+func benchmarkSmallHashes(b *testing.B) {
+	const n = 1 << 14
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	hash := New()
+	for i := 0; i < n; i++ {
+		k := "hash:" + strconv.Itoa(i)
+		for j := 0; j < 8; j++ {
+			hash.HSet(k, "field"+strconv.Itoa(j), []byte("value"))
+		}
+	}
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	runtime.KeepAlive(hash)
+	b.ReportMetric(float64(after.HeapAlloc-before.HeapAlloc)/n, "bytes/hash")
+}
+
+// BenchmarkHash_SmallMemory reports the memory of hashes with 8 fields, which are packed in listpacks.
+func BenchmarkHash_SmallMemory(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		benchmarkSmallHashes(b)
+	}
+}
+
+// BenchmarkHash_SmallMemoryMap reports the memory of the same hashes stored in maps.
+func BenchmarkHash_SmallMemoryMap(b *testing.B) {
+	defer func(n int) { maxListpackEntries = n }(maxListpackEntries)
+	maxListpackEntries = 0
+	for i := 0; i < b.N; i++ {
+		benchmarkSmallHashes(b)
+	}
+}
@@ -0,0 +1,96 @@
+package hash
+
+import "encoding/binary"
+
+// The limits of a hash encoded as a listpack, the hash is converted to a map when any of them is exceeded.
+var (
+	maxListpackEntries   = 128
+	maxListpackValueSize = 64
+)
+
+// listpack is the compact encoding of a small hash, all the fields and values are packed in a single buffer.
+// Every entry is the uvarint length of the field, the field, the uvarint length of the value and the value,
+// the entries are kept in the order they are added.
+type listpack struct {
+	buf []byte
+	n   int
+}
+
+// fits returns if the field and value can be added to the listpack.
+func (lp *listpack) fits(field string, value []byte) bool {
+	return len(field) <= maxListpackValueSize && len(value) <= maxListpackValueSize
+}
+
+// find returns the offsets of the entry with the field: the start of the entry, the start of the value and the end of the entry.
+func (lp *listpack) find(field string) (start, valueStart, end int, ok bool) {
+	for p := 0; p < len(lp.buf); {
+		start = p
+		f, n := lp.next(p)
+		valueStart = n
+		_, end = lp.next(n)
+		if string(f) == field {
+			return start, valueStart, end, true
+		}
+		p = end
+	}
+	return 0, 0, 0, false
+}
+
+// next returns the bytes at offset p and the offset after them.
+func (lp *listpack) next(p int) ([]byte, int) {
+	size, n := binary.Uvarint(lp.buf[p:])
+	p += n
+	return lp.buf[p : p+int(size)], p + int(size)
+}
+
+// get returns a copy of the value of the field.
+func (lp *listpack) get(field string) ([]byte, bool) {
+	_, valueStart, _, ok := lp.find(field)
+	if !ok {
+		return nil, false
+	}
+	v, _ := lp.next(valueStart)
+	return append([]byte{}, v...), true
+}
+
+// set returns true if the field is new, an existing field keeps its position.
+func (lp *listpack) set(field string, value []byte) bool {
+	_, valueStart, end, ok := lp.find(field)
+	if !ok {
+		lp.buf = appendBytes(appendBytes(lp.buf, []byte(field)), value)
+		lp.n++
+		return true
+	}
+
+	tail := append(appendBytes(nil, value), lp.buf[end:]...)
+	lp.buf = append(lp.buf[:valueStart], tail...)
+	return false
+}
+
+func (lp *listpack) del(field string) bool {
+	start, _, end, ok := lp.find(field)
+	if !ok {
+		return false
+	}
+	lp.buf = append(lp.buf[:start], lp.buf[end:]...)
+	lp.n--
+	return true
+}
+
+// entries returns copies of all the entries in order.
+func (lp *listpack) entries() []entry {
+	res := make([]entry, 0, lp.n)
+	for p := 0; p < len(lp.buf); {
+		var f, v []byte
+		f, p = lp.next(p)
+		v, p = lp.next(p)
+		res = append(res, entry{field: string(f), value: append([]byte{}, v...)})
+	}
+	return res
+}
+
+func appendBytes(buf, b []byte) []byte {
+	var size [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(size[:], uint64(len(b)))
+	return append(append(buf, size[:n]...), b...)
+}
@@ -0,0 +1,137 @@
+package hll
+
+import (
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// the standard error is 0.81%, allow 4 times of it.
+const maxError = 0.0325
+
+func TestHLL_Add(t *testing.T) {
+	h := New()
+	assert.True(t, h.Add([]byte("a")))
+	assert.False(t, h.Add([]byte("a")))
+	assert.Equal(t, uint64(1), h.Count())
+	assert.Equal(t, uint64(0), New().Count())
+}
+
+func TestHLL_Count(t *testing.T) {
+	for _, n := range []int{10, 100, 1000, 10000, 100000, 1000000} {
+		h := New()
+		for i := 0; i < n; i++ {
+			h.Add([]byte("member_" + strconv.Itoa(i)))
+		}
+		// add them again and the count should not change.
+		for i := 0; i < n/10; i++ {
+			h.Add([]byte("member_" + strconv.Itoa(i)))
+		}
+
+		count := float64(h.Count())
+		relErr := math.Abs(count-float64(n)) / float64(n)
+		assert.True(t, relErr < maxError, "n: %d, count: %v, err: %v", n, count, relErr)
+	}
+}
+
+func TestHLL_Promote(t *testing.T) {
+	h := New()
+	for i := 0; i < 100; i++ {
+		h.Add([]byte(strconv.Itoa(i)))
+	}
+	assert.True(t, h.IsSparse())
+
+	for i := 0; i < 10000; i++ {
+		h.Add([]byte(strconv.Itoa(i)))
+	}
+	assert.False(t, h.IsSparse())
+}
+
+func TestHLL_EncodeDecode(t *testing.T) {
+	for _, n := range []int{0, 100, 10000} {
+		h := New()
+		for i := 0; i < n; i++ {
+			h.Add([]byte(strconv.Itoa(i)))
+		}
+
+		h2, err := Decode(h.Encode())
+		assert.Nil(t, err)
+		assert.Equal(t, h.IsSparse(), h2.IsSparse())
+		assert.Equal(t, h.Count(), h2.Count())
+	}
+
+	_, err := Decode([]byte("not a hll"))
+	assert.Equal(t, ErrInvalidHLL, err)
+	_, err = Decode(append(New().Encode(), 1))
+	assert.Equal(t, ErrInvalidHLL, err)
+
+	// the registers are bounded in both representations.
+	sparse := New()
+	sparse.Add([]byte("a"))
+	buf := sparse.Encode()
+	buf[len(buf)-1] = 200
+	_, err = Decode(buf)
+	assert.Equal(t, ErrInvalidHLL, err)
+	buf[len(buf)-1] = 0
+	_, err = Decode(buf)
+	assert.Equal(t, ErrInvalidHLL, err)
+
+	dense := New()
+	dense.promote()
+	buf = dense.Encode()
+	buf[headerSize] = maxRegister
+	_, err = Decode(buf)
+	assert.Nil(t, err)
+	buf[headerSize] = 200
+	_, err = Decode(buf)
+	assert.Equal(t, ErrInvalidHLL, err)
+}
+
+func TestHLL_Merge(t *testing.T) {
+	h1, h2, h3 := New(), New(), New()
+	for i := 0; i < 50000; i++ {
+		h1.Add([]byte(strconv.Itoa(i)))
+	}
+	for i := 25000; i < 100000; i++ {
+		h2.Add([]byte(strconv.Itoa(i)))
+	}
+	for i := 99990; i < 100010; i++ {
+		h3.Add([]byte(strconv.Itoa(i)))
+	}
+
+	h1.Merge(h2)
+	h1.Merge(h3)
+	relErr := math.Abs(float64(h1.Count())-100010) / 100010
+	assert.True(t, relErr < maxError, "count: %v, err: %v", h1.Count(), relErr)
+}
+
+func TestHLL_EncodeChanged(t *testing.T) {
+	h := New()
+	h.Add([]byte("a"))
+	sparse, err := Decode(h.Encode())
+	assert.Nil(t, err)
+	_, _, ok := sparse.EncodeChanged()
+	assert.False(t, ok)
+
+	for i := 0; i < 10000; i++ {
+		h.Add([]byte(strconv.Itoa(i)))
+	}
+	buf := h.Encode()
+	dense, err := Decode(buf)
+	assert.Nil(t, err)
+	offset, patch, ok := dense.EncodeChanged()
+	assert.True(t, ok)
+	assert.Empty(t, patch)
+
+	// the patch covers all the changed registers.
+	for i := 10000; i < 10100; i++ {
+		dense.Add([]byte(strconv.Itoa(i)))
+	}
+	offset, patch, ok = dense.EncodeChanged()
+	assert.True(t, ok)
+	assert.True(t, len(patch) > 0 && len(patch) < registers)
+	copy(buf[offset:], patch)
+	assert.Equal(t, dense.Encode(), buf)
+}
@@ -0,0 +1,55 @@
+package list
+
+import "math/bits"
+
+// The checksum of a list is the polynomial hash of its elements, sum(hash(e[i]) * base^i) mod hashMod,
+// so it is updated in O(1) when an element is pushed, popped or set, without reading the other elements.
+const (
+	hashMod  = 1<<61 - 1
+	hashBase = 0x0123456789abcdef % hashMod
+)
+
+// hashBaseInv the inverse of hashBase, the powers are divided by it when an element is popped from the head.
+var hashBaseInv = powMod(hashBase, hashMod-2)
+
+// hashValue returns the FNV-1a hash of the element.
+func hashValue(val []byte) uint64 {
+	h := uint64(14695981039346656037)
+	for _, c := range val {
+		h ^= uint64(c)
+		h *= 1099511628211
+	}
+	return h % hashMod
+}
+
+func addMod(a, b uint64) uint64 {
+	s := a + b
+	if s >= hashMod {
+		s -= hashMod
+	}
+	return s
+}
+
+func subMod(a, b uint64) uint64 {
+	if a >= b {
+		return a - b
+	}
+	return a + hashMod - b
+}
+
+func mulMod(a, b uint64) uint64 {
+	hi, lo := bits.Mul64(a, b)
+	// 2^61 is 1 modulo hashMod, so the high bits are added to the low 61 bits.
+	return addMod(hi<<3|lo>>61, lo&hashMod)
+}
+
+func powMod(a uint64, n int) uint64 {
+	res := uint64(1)
+	for ; n > 0; n >>= 1 {
+		if n&1 == 1 {
+			res = mulMod(res, a)
+		}
+		a = mulMod(a, a)
+	}
+	return res
+}
@@ -0,0 +1,292 @@
+package list
+
+const (
+	// the number of elements in a chunk.
+	chunkSize = 64
+	// the room allocated for a new chunk.
+	minChunkSize = 4
+)
+
+// chunk holds the elements at the positions [off, off+len(vals)) of the chunk.
+// The room of the first chunk is doubled from minChunkSize to chunkSize as the elements are pushed, so a short list does not allocate a whole chunk.
+type chunk struct {
+	off  int
+	vals [][]byte
+}
+
+// grow make room for the position q of the chunk, the room is extended on the side of q.
+func (c *chunk) grow(q int) {
+	lo, hi := c.off, c.off+len(c.vals)
+	if q >= lo && q < hi {
+		return
+	}
+	size := 2 * len(c.vals)
+	if size < minChunkSize {
+		size = minChunkSize
+	}
+	if q < lo && hi-q > size {
+		size = hi - q
+	}
+	if q >= hi && q+1-lo > size {
+		size = q + 1 - lo
+	}
+	if size > chunkSize {
+		size = chunkSize
+	}
+
+	off := lo
+	if q < lo {
+		if off = hi - size; off < 0 {
+			off = 0
+		}
+	} else if off+size > chunkSize {
+		off = chunkSize - size
+	}
+	vals := make([][]byte, size)
+	copy(vals[lo-off:], c.vals)
+	c.off, c.vals = off, vals
+}
+
+// deque is a chunked double-ended queue, the elements are stored in chunks of chunkSize positions,
+// so pushing and popping at both ends are O(1), and accessing by index is O(1) as well.
+// Element i is at position head+i, which is in chunks[(head+i)/chunkSize].
+// The chunks without elements are released, so they are nil.
+// sum is the checksum of the elements, and pow is hashBase^length, the power of the next element pushed at the tail.
+type deque struct {
+	chunks []*chunk
+	head   int
+	length int
+	sum    uint64
+	pow    uint64
+}
+
+func newDeque() *deque {
+	return &deque{pow: 1}
+}
+
+// Len returns the number of elements.
+func (d *deque) Len() int {
+	return d.length
+}
+
+// Get returns the element at index i, i must be in [0, Len()).
+func (d *deque) Get(i int) []byte {
+	p := d.head + i
+	c := d.chunks[p/chunkSize]
+	return c.vals[p%chunkSize-c.off]
+}
+
+// Set sets the element at index i, i must be in [0, Len()).
+func (d *deque) Set(i int, val []byte) {
+	w := powMod(hashBase, i)
+	d.sum = addMod(subMod(d.sum, mulMod(hashValue(d.Get(i)), w)), mulMod(hashValue(val), w))
+	d.put(i, val)
+}
+
+// Checksum returns the checksum of the elements.
+func (d *deque) Checksum() uint64 {
+	return d.sum
+}
+
+// PushFront insert the element at the head.
+func (d *deque) PushFront(val []byte) {
+	d.sum = addMod(hashValue(val), mulMod(d.sum, hashBase))
+	d.pow = mulMod(d.pow, hashBase)
+	d.pushFront(val)
+}
+
+// PushBack insert the element at the tail.
+func (d *deque) PushBack(val []byte) {
+	d.sum = addMod(d.sum, mulMod(hashValue(val), d.pow))
+	d.pow = mulMod(d.pow, hashBase)
+	d.pushBack(val)
+}
+
+// PopFront removes and returns the first element, nil is returned if the deque is empty.
+func (d *deque) PopFront() []byte {
+	if d.length == 0 {
+		return nil
+	}
+	d.sum = mulMod(subMod(d.sum, hashValue(d.Get(0))), hashBaseInv)
+	d.pow = mulMod(d.pow, hashBaseInv)
+	return d.popFront()
+}
+
+// PopBack removes and returns the last element, nil is returned if the deque is empty.
+func (d *deque) PopBack() []byte {
+	if d.length == 0 {
+		return nil
+	}
+	d.pow = mulMod(d.pow, hashBaseInv)
+	d.sum = subMod(d.sum, mulMod(hashValue(d.Get(d.length-1)), d.pow))
+	return d.popBack()
+}
+
+// Insert insert the element at index i, the elements from i are moved backward, i must be in [0, Len()].
+// The shorter side of the deque is moved, and the checksum is updated by hashing the elements of that side.
+func (d *deque) Insert(i int, val []byte) {
+	prefix, suffix := d.split(i, i)
+	d.sum = addMod(addMod(prefix, mulMod(hashValue(val), powMod(hashBase, i))), mulMod(suffix, hashBase))
+	d.pow = mulMod(d.pow, hashBase)
+
+	if i < d.length/2 {
+		d.pushFront(nil)
+		for j := 0; j < i; j++ {
+			d.put(j, d.Get(j+1))
+		}
+	} else {
+		d.pushBack(nil)
+		for j := d.length - 1; j > i; j-- {
+			d.put(j, d.Get(j-1))
+		}
+	}
+	d.put(i, val)
+}
+
+// Remove removes and returns the element at index i, i must be in [0, Len()).
+// The shorter side of the deque is moved, and the checksum is updated by hashing the elements of that side.
+func (d *deque) Remove(i int) []byte {
+	prefix, suffix := d.split(i, i+1)
+	d.sum = addMod(prefix, mulMod(suffix, hashBaseInv))
+	d.pow = mulMod(d.pow, hashBaseInv)
+
+	val := d.Get(i)
+	if i < d.length/2 {
+		for j := i; j > 0; j-- {
+			d.put(j, d.Get(j-1))
+		}
+		d.popFront()
+	} else {
+		for j := i; j < d.length-1; j++ {
+			d.put(j, d.Get(j+1))
+		}
+		d.popBack()
+	}
+	return val
+}
+
+// rehash computes the checksum of all the elements, it is used after the elements are moved by put.
+func (d *deque) rehash() {
+	d.sum, d.pow = d.hashRange(0, d.length), powMod(hashBase, d.length)
+}
+
+// split returns the checksum of the elements before i and the checksum of the elements from j,
+// the elements of the shorter side are hashed, and the other side is derived from the checksum of the deque.
+func (d *deque) split(i, j int) (prefix, suffix uint64) {
+	if i < d.length/2 {
+		prefix = d.hashRange(0, i)
+		suffix = subMod(d.sum, prefix)
+		if j > i {
+			suffix = subMod(suffix, mulMod(hashValue(d.Get(i)), powMod(hashBase, i)))
+		}
+	} else {
+		suffix = d.hashRange(j, d.length)
+		prefix = subMod(d.sum, suffix)
+		if j > i {
+			prefix = subMod(prefix, mulMod(hashValue(d.Get(i)), powMod(hashBase, i)))
+		}
+	}
+	return
+}
+
+// hashRange returns the checksum of the elements in [from, to), each element is weighted by its index.
+func (d *deque) hashRange(from, to int) uint64 {
+	var sum uint64
+	w := powMod(hashBase, from)
+	for i := from; i < to; i++ {
+		sum = addMod(sum, mulMod(hashValue(d.Get(i)), w))
+		w = mulMod(w, hashBase)
+	}
+	return sum
+}
+
+// put sets the element at index i without updating the checksum.
+func (d *deque) put(i int, val []byte) {
+	p := d.head + i
+	c := d.chunks[p/chunkSize]
+	c.vals[p%chunkSize-c.off] = val
+}
+
+func (d *deque) pushFront(val []byte) {
+	if d.head == 0 {
+		d.grow(true)
+	}
+	d.head--
+	d.length++
+	d.alloc(d.head)
+	d.put(0, val)
+}
+
+func (d *deque) pushBack(val []byte) {
+	if d.head+d.length == len(d.chunks)*chunkSize {
+		d.grow(false)
+	}
+	d.length++
+	d.alloc(d.head + d.length - 1)
+	d.put(d.length-1, val)
+}
+
+func (d *deque) popFront() []byte {
+	val := d.Get(0)
+	d.put(0, nil)
+	// release the chunk if it is empty.
+	if d.head%chunkSize == chunkSize-1 || d.length == 1 {
+		d.chunks[d.head/chunkSize] = nil
+	}
+	d.head++
+	d.length--
+	d.reset()
+	return val
+}
+
+func (d *deque) popBack() []byte {
+	p := d.head + d.length - 1
+	val := d.Get(d.length - 1)
+	d.put(d.length-1, nil)
+	if p%chunkSize == 0 || d.length == 1 {
+		d.chunks[p/chunkSize] = nil
+	}
+	d.length--
+	d.reset()
+	return val
+}
+
+// grow make room for a chunk at the front or at the back.
+// The chunks are moved to the middle if the unused room is enough, otherwise the room is doubled.
+func (d *deque) grow(front bool) {
+	used := (d.head+d.length+chunkSize-1)/chunkSize - d.head/chunkSize
+	size := len(d.chunks)
+	if used*2 >= size {
+		size = size*2 + 1
+	}
+	chunks := make([]*chunk, size)
+	offset := (size - used) / 2
+	if offset == 0 && front {
+		offset = 1
+	}
+	copy(chunks[offset:], d.chunks[d.head/chunkSize:d.head/chunkSize+used])
+	d.head = offset*chunkSize + d.head%chunkSize
+	d.chunks = chunks
+}
+
+// allocate the chunk of the position if it is released, and make room for the position in it.
+// Only the first chunk grows from minChunkSize, the list is long if it needs another chunk, so that one is allocated whole.
+func (d *deque) alloc(p int) {
+	c := d.chunks[p/chunkSize]
+	if c == nil {
+		c = &chunk{off: p % chunkSize}
+		if d.length > 1 {
+			c.off, c.vals = 0, make([][]byte, chunkSize)
+		}
+		d.chunks[p/chunkSize] = c
+	}
+	c.grow(p % chunkSize)
+}
+
+// release all the room if the deque is empty.
+func (d *deque) reset() {
+	if d.length == 0 {
+		d.chunks, d.head = nil, 0
+		d.sum, d.pow = 0, 1
+	}
+}
@@ -1,9 +1,8 @@
 package list
 import (
-	"container/list"
-	"reflect"
+	"bytes"
 )
-// List is the implementation of doubly linked list.
+// List is the implementation of list, every list is a chunked deque.
 
 // InsertOption insert option for LInsert.
 type InsertOption uint8
@@ -20,20 +19,16 @@ type (
 	List struct {
 		// record saves the List of a specified key.
 		record Record
-
-		// values saves the values of a List, help checking if a value exists in List.
-		values map[string]map[string]int
 	}
 
-	// Record list record to save.
-	Record map[string]*list.List
+	// Record list record to save, every List is a chunked deque.
+	Record map[string]*deque
 )
 
 // New create a new list idx.
 func New() *List {
 	return &List{
 		make(Record),
-		make(map[string]map[string]int),
 	}
 }
 
@@ -67,15 +62,7 @@ func (lis *List) LIndex(key string, index int) []byte {
 	if !ok {
 		return nil
 	}
-
-	index = newIndex
-	var val []byte
-	e := lis.index(key, index)
-	if e != nil {
-		val = e.Value.([]byte)
-	}
-
-	return val
+	return lis.record[key].Get(newIndex)
 }
 
 // LRem removes the first count occurrences of elements equal to element from the list stored at key.
@@ -89,93 +76,77 @@ func (lis *List) LRem(key string, val []byte, count int) int {
 		return 0
 	}
 
-	var ele []*list.Element
-	if count == 0 {
-		for p := item.Front(); p != nil; p = p.Next() {
-			if reflect.DeepEqual(p.Value.([]byte), val) {
-				ele = append(ele, p)
-			}
-		}
-	}
+	// the matches in [from, to] are removed.
+	from, to, matches := 0, item.Len()-1, 0
 	if count > 0 {
-		for p := item.Front(); p != nil && len(ele) < count; p = p.Next() {
-			if reflect.DeepEqual(p.Value.([]byte), val) {
-				ele = append(ele, p)
+		for i := 0; i < item.Len() && matches < count; i++ {
+			if bytes.Equal(item.Get(i), val) {
+				matches, to = matches+1, i
 			}
 		}
 	}
 	if count < 0 {
-		for p := item.Back(); p != nil && len(ele) < -count; p = p.Prev() {
-			if reflect.DeepEqual(p.Value.([]byte), val) {
-				ele = append(ele, p)
+		for i := item.Len() - 1; i >= 0 && matches < -count; i-- {
+			if bytes.Equal(item.Get(i), val) {
+				matches, from = matches+1, i
 			}
 		}
 	}
-
-	for _, e := range ele {
-		item.Remove(e)
+	if count != 0 && matches == 0 {
+		return 0
 	}
-	length := len(ele)
-	ele = nil
 
-	if lis.values[key] != nil {
-		cnt := lis.values[key][string(val)] - length
-		if cnt <= 0 {
-			delete(lis.values[key], string(val))
-		} else {
-			lis.values[key][string(val)] = cnt
+	// move the kept elements forward, and pop the rest, the checksum is computed again after that.
+	n := 0
+	for i := 0; i < item.Len(); i++ {
+		if i < from || i > to || !bytes.Equal(item.Get(i), val) {
+			item.put(n, item.Get(i))
+			n++
 		}
 	}
-	return length
+	removed := item.Len() - n
+	for item.Len() > n {
+		item.popBack()
+	}
+	item.rehash()
+	return removed
 }
 
 // LInsert inserts element in the list stored at key either before or after the reference value pivot.
 func (lis *List) LInsert(key string, option InsertOption, pivot, val []byte) int {
-	e := lis.find(key, pivot)
-	if e == nil {
+	i := lis.find(key, pivot)
+	if i < 0 {
 		return -1
 	}
 
 	item := lis.record[key]
 	if option == Before {
-		item.InsertBefore(val, e)
+		item.Insert(i, val)
 	}
 	if option == After {
-		item.InsertAfter(val, e)
+		item.Insert(i+1, val)
 	}
+	return item.Len()
+}
 
-	if lis.values[key] == nil {
-		lis.values[key] = make(map[string]int)
+// LInsertAt inserts element at index in the list stored at key, index must be in [0, LLen(key)].
+// Returns the length of the list after the insert operation, -1 is returned if index is out of range.
+func (lis *List) LInsertAt(key string, index int, val []byte) int {
+	item := lis.record[key]
+	if item == nil || index < 0 || index > item.Len() {
+		return -1
 	}
-	lis.values[key][string(val)] += 1
-
+	item.Insert(index, val)
 	return item.Len()
 }
 
 // LSet sets the list element at index to element.
 func (lis *List) LSet(key string, index int, val []byte) bool {
-	e := lis.index(key, index)
-	if e == nil {
+	ok, newIndex := lis.validIndex(key, index)
+	if !ok {
 		return false
 	}
-
-	if lis.values[key] == nil {
-		lis.values[key] = make(map[string]int)
-	}
-
-	// update value count.
-	if e.Value != nil {
-		v := string(e.Value.([]byte))
-		cnt := lis.values[key][v] - 1
-		if cnt <= 0 {
-			delete(lis.values[key], v)
-		} else {
-			lis.values[key][v] = cnt
-		}
-	}
-
-	e.Value = val
-	lis.values[key][string(val)] += 1
+	lis.record[key].Set(newIndex, val)
 	return true
 }
 
@@ -198,28 +169,9 @@ func (lis *List) LRange(key string, start, end int) [][]byte {
 		return val
 	}
 
-	mid := length >> 1
-
-	// Traverse from left to right.
-	if end <= mid || end-mid < mid-start {
-		flag := 0
-		for p := item.Front(); p != nil && flag <= end; p, flag = p.Next(), flag+1 {
-			if flag >= start {
-				val = append(val, p.Value.([]byte))
-			}
-		}
-	} else { // Traverse from right to left.
-		flag := length - 1
-		for p := item.Back(); p != nil && flag >= start; p, flag = p.Prev(), flag-1 {
-			if flag <= end {
-				val = append(val, p.Value.([]byte))
-			}
-		}
-		if len(val) > 0 {
-			for i, j := 0, len(val)-1; i < j; i, j = i+1, j-1 {
-				val[i], val[j] = val[j], val[i]
-			}
-		}
+	val = make([][]byte, 0, end-start+1)
+	for i := start; i <= end; i++ {
+		val = append(val, item.Get(i))
 	}
 	return val
 }
@@ -240,47 +192,15 @@ func (lis *List) LTrim(key string, start, end int) bool {
 	}
 
 	if start > end || start >= length {
-		lis.record[key] = nil
-		lis.values[key] = nil
+		lis.record[key] = newDeque()
 		return true
 	}
 
-	startEle, endEle := lis.index(key, start), lis.index(key, end)
-	if end-start+1 < (length >> 1) {
-		newList := list.New()
-		newValuesMap := make(map[string]int)
-		for p := startEle; p != endEle.Next(); p = p.Next() {
-			newList.PushBack(p.Value)
-			if p.Value != nil {
-				newValuesMap[string(p.Value.([]byte))] += 1
-			}
-		}
-
-		item = nil
-		lis.record[key] = newList
-		lis.values[key] = newValuesMap
-	} else {
-		var ele []*list.Element
-		for p := item.Front(); p != startEle; p = p.Next() {
-			ele = append(ele, p)
-		}
-		for p := item.Back(); p != endEle; p = p.Prev() {
-			ele = append(ele, p)
-		}
-
-		for _, e := range ele {
-			item.Remove(e)
-			if lis.values[key] != nil && e.Value != nil {
-				v := string(e.Value.([]byte))
-				cnt := lis.values[key][v] - 1
-				if cnt <= 0 {
-					delete(lis.values[key], v)
-				} else {
-					lis.values[key][v] = cnt
-				}
-			}
-		}
-		ele = nil
+	for i := 0; i < start; i++ {
+		item.PopFront()
+	}
+	for i := end; i < length-1; i++ {
+		item.PopBack()
 	}
 	return true
 }
@@ -296,10 +216,18 @@ func (lis *List) LLen(key string) int {
 	return length
 }
 
+// LChecksum returns the checksum of the elements of the list stored at key, it depends on the elements and their order.
+// The checksum is updated by every operation, so it is O(1).
+func (lis *List) LChecksum(key string) uint64 {
+	if lis.record[key] == nil {
+		return 0
+	}
+	return lis.record[key].Checksum()
+}
+
 // LClear clear a specified key for List.
 func (lis *List) LClear(key string) {
 	delete(lis.record, key)
-	delete(lis.values, key)
 }
 
 // LKeyExists check if the key of a List exists.
@@ -310,64 +238,59 @@ func (lis *List) LKeyExists(key string) (ok bool) {
 
 // LValExists check if the val exists in a specified List stored at key.
 func (lis *List) LValExists(key string, val []byte) (ok bool) {
-	if lis.values[key] != nil {
-		cnt := lis.values[key][string(val)]
-		ok = cnt > 0
-	}
-	return
+	return lis.find(key, val) >= 0
 }
 
-func (lis *List) find(key string, val []byte) *list.Element {
+// LPos returns the indexes of the elements equal to val in the list stored at key.
+// rank is the first match to return, 1 means the first match, 2 the second and so on,
+// a negative rank searches from the tail, -1 means the last match. rank must not be 0.
+// At most count indexes are returned, 0 means all the matches.
+// At most maxLen elements are compared, 0 means the whole list.
+func (lis *List) LPos(key string, val []byte, rank, count, maxLen int) []int {
 	item := lis.record[key]
-	var e *list.Element
+	if item == nil || rank == 0 {
+		return nil
+	}
 
-	if item != nil {
-		for p := item.Front(); p != nil; p = p.Next() {
-			if reflect.DeepEqual(p.Value.([]byte), val) {
-				e = p
-				break
+	var res []int
+	skip, idx, step := rank-1, 0, 1
+	if rank < 0 {
+		skip, idx, step = -rank-1, item.Len()-1, -1
+	}
+	for compared := 0; idx >= 0 && idx < item.Len() && (maxLen == 0 || compared < maxLen); compared++ {
+		if bytes.Equal(item.Get(idx), val) {
+			if skip > 0 {
+				skip--
+			} else {
+				res = append(res, idx)
+				if count > 0 && len(res) == count {
+					break
+				}
 			}
 		}
+		idx += step
 	}
-
-	return e
+	return res
 }
 
-func (lis *List) index(key string, index int) *list.Element {
-	ok, newIndex := lis.validIndex(key, index)
-	if !ok {
-		return nil
-	}
-
-	index = newIndex
+// find returns the index of the first element equal to val, -1 is returned if not found.
+func (lis *List) find(key string, val []byte) int {
 	item := lis.record[key]
-	var e *list.Element
+	if item == nil {
+		return -1
+	}
 
-	if item != nil && item.Len() > 0 {
-		if index <= (item.Len() >> 1) {
-			val := item.Front()
-			for i := 0; i < index; i++ {
-				val = val.Next()
-			}
-			e = val
-		} else {
-			val := item.Back()
-			for i := item.Len() - 1; i > index; i-- {
-				val = val.Prev()
-			}
-			e = val
+	for i := 0; i < item.Len(); i++ {
+		if bytes.Equal(item.Get(i), val) {
+			return i
 		}
 	}
-
-	return e
+	return -1
 }
 
 func (lis *List) push(front bool, key string, val ...[]byte) int {
 	if lis.record[key] == nil {
-		lis.record[key] = list.New()
-	}
-	if lis.values[key] == nil {
-		lis.values[key] = make(map[string]int)
+		lis.record[key] = newDeque()
 	}
 
 	for _, v := range val {
@@ -376,36 +299,19 @@ func (lis *List) push(front bool, key string, val ...[]byte) int {
 		} else {
 			lis.record[key].PushBack(v)
 		}
-		lis.values[key][string(v)] += 1
 	}
 	return lis.record[key].Len()
 }
 
 func (lis *List) pop(front bool, key string) []byte {
 	item := lis.record[key]
-	var val []byte
-
-	if item != nil && item.Len() > 0 {
-		var e *list.Element
-		if front {
-			e = item.Front()
-		} else {
-			e = item.Back()
-		}
-
-		val = e.Value.([]byte)
-		item.Remove(e)
-		// update value count.
-		if lis.values[key] != nil {
-			cnt := lis.values[key][string(val)] - 1
-			if cnt <= 0 {
-				delete(lis.values[key], string(val))
-			} else {
-				lis.values[key][string(val)] = cnt
-			}
-		}
+	if item == nil {
+		return nil
 	}
-	return val
+	if front {
+		return item.PopFront()
+	}
+	return item.PopBack()
 }
 
 // check if the index is valid and returns the new index.
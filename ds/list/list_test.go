@@ -0,0 +1,296 @@
+package list
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestList_PushPop(t *testing.T) {
+	lis := New()
+	assert.Nil(t, lis.LPop("k"))
+
+	// push across several chunks from both sides.
+	for i := 0; i < 3*chunkSize; i++ {
+		lis.RPush("k", []byte(fmt.Sprint(i)))
+		lis.LPush("k", []byte(fmt.Sprint(-i-1)))
+	}
+	assert.Equal(t, 6*chunkSize, lis.LLen("k"))
+	assert.Equal(t, []byte(fmt.Sprint(-3*chunkSize)), lis.LIndex("k", 0))
+	assert.Equal(t, []byte(fmt.Sprint(3*chunkSize-1)), lis.LIndex("k", -1))
+
+	for i := 3*chunkSize - 1; i >= 0; i-- {
+		assert.Equal(t, []byte(fmt.Sprint(i)), lis.RPop("k"))
+		assert.Equal(t, []byte(fmt.Sprint(-i-1)), lis.LPop("k"))
+	}
+	assert.Equal(t, 0, lis.LLen("k"))
+	assert.True(t, lis.LKeyExists("k"))
+	assert.Nil(t, lis.RPop("k"))
+}
+
+func TestList_Queue(t *testing.T) {
+	lis := New()
+	// the room is reused by a queue, it does not grow with the pushed elements.
+	for i := 0; i < 100*chunkSize; i++ {
+		lis.RPush("q", []byte(fmt.Sprint(i)))
+		if i >= 10 {
+			assert.Equal(t, []byte(fmt.Sprint(i-10)), lis.LPop("q"))
+		}
+	}
+	assert.Equal(t, 10, lis.LLen("q"))
+	assert.True(t, len(lis.record["q"].chunks) <= 4)
+}
+
+func TestList_SmallChunk(t *testing.T) {
+	lis := New()
+	lis.RPush("r", []byte("a"))
+	lis.LPush("l", []byte("a"))
+	// a short list only allocates the room it needs, on the side it grows.
+	assert.Equal(t, minChunkSize, len(lis.record["r"].chunks[0].vals))
+	assert.Equal(t, minChunkSize, len(lis.record["l"].chunks[0].vals))
+
+	for i := 0; i < chunkSize; i++ {
+		lis.RPush("r", []byte(fmt.Sprint(i)))
+		lis.LPush("l", []byte(fmt.Sprint(i)))
+	}
+	d := lis.record["r"]
+	assert.Equal(t, chunkSize, len(d.chunks[d.head/chunkSize].vals))
+	assert.Equal(t, []byte("a"), lis.LIndex("r", 0))
+	assert.Equal(t, []byte("a"), lis.LIndex("l", -1))
+	assert.Equal(t, []byte(fmt.Sprint(chunkSize-1)), lis.LIndex("r", -1))
+	assert.Equal(t, []byte(fmt.Sprint(chunkSize-1)), lis.LIndex("l", 0))
+}
+
+func TestList_Modify(t *testing.T) {
+	lis := New()
+	lis.RPush("k", []byte("a"), []byte("b"), []byte("c"), []byte("b"), []byte("d"))
+
+	assert.Equal(t, 6, lis.LInsert("k", Before, []byte("b"), []byte("x")))
+	assert.Equal(t, 7, lis.LInsert("k", After, []byte("d"), []byte("y")))
+	assert.Equal(t, -1, lis.LInsert("k", After, []byte("z"), []byte("y")))
+	assert.True(t, lis.LSet("k", -1, []byte("b")))
+	assert.False(t, lis.LSet("k", 7, []byte("b")))
+	assert.Equal(t, [][]byte{[]byte("a"), []byte("x"), []byte("b"), []byte("c"), []byte("b"), []byte("d"), []byte("b")}, lis.LRange("k", 0, -1))
+
+	assert.Equal(t, 2, lis.LRem("k", []byte("b"), -2))
+	assert.Equal(t, [][]byte{[]byte("a"), []byte("x"), []byte("b"), []byte("c"), []byte("d")}, lis.LRange("k", 0, -1))
+	assert.True(t, lis.LValExists("k", []byte("b")))
+	assert.Equal(t, 1, lis.LRem("k", []byte("b"), 0))
+	assert.False(t, lis.LValExists("k", []byte("b")))
+
+	assert.True(t, lis.LTrim("k", 1, -2))
+	assert.Equal(t, [][]byte{[]byte("x"), []byte("c")}, lis.LRange("k", 0, -1))
+	assert.False(t, lis.LTrim("k", 0, -1))
+	assert.True(t, lis.LTrim("k", 5, 10))
+	assert.Equal(t, 0, lis.LLen("k"))
+	assert.True(t, lis.LKeyExists("k"))
+
+	lis.LClear("k")
+	assert.False(t, lis.LKeyExists("k"))
+}
+
+// checksumOf computes the checksum of the elements from scratch.
+func checksumOf(vals [][]byte) uint64 {
+	var sum uint64
+	for i, v := range vals {
+		sum = addMod(sum, mulMod(hashValue(v), powMod(hashBase, i)))
+	}
+	return sum
+}
+
+func TestList_Checksum(t *testing.T) {
+	lis := New()
+	assert.Equal(t, uint64(0), lis.LChecksum("k"))
+	lis.RPush("k", []byte("a"), []byte("b"), []byte("c"))
+	lis.RPush("k2", []byte("c"), []byte("b"), []byte("a"))
+	// the order of the elements changes the checksum.
+	assert.NotEqual(t, lis.LChecksum("k"), lis.LChecksum("k2"))
+
+	// the same elements built by other operations have the same checksum.
+	lis.LPush("k3", []byte("c"), []byte("x"), []byte("a"))
+	lis.LSet("k3", 1, []byte("b"))
+	assert.Equal(t, lis.LChecksum("k"), lis.LChecksum("k3"))
+
+	// a difference in the middle of a long list is detected.
+	for i := 0; i < 3*chunkSize; i++ {
+		lis.RPush("l1", []byte(fmt.Sprint(i)))
+		lis.RPush("l2", []byte(fmt.Sprint(i)))
+	}
+	assert.Equal(t, lis.LChecksum("l1"), lis.LChecksum("l2"))
+	lis.LSet("l2", chunkSize, []byte("x"))
+	assert.NotEqual(t, lis.LChecksum("l1"), lis.LChecksum("l2"))
+	lis.LInsert("l1", After, []byte("10"), []byte("y"))
+	lis.LRem("l1", []byte("y"), 0)
+	lis.LSet("l1", chunkSize, []byte("x"))
+	assert.Equal(t, lis.LChecksum("l1"), lis.LChecksum("l2"))
+	assert.Equal(t, checksumOf(lis.LRange("l1", 0, -1)), lis.LChecksum("l1"))
+
+	d := newDeque()
+	var vals [][]byte
+	for i := 0; i < 10; i++ {
+		d.PushBack([]byte(fmt.Sprint(i)))
+		vals = append(vals, []byte(fmt.Sprint(i)))
+	}
+	d.Remove(2)
+	d.Remove(6)
+	vals = append(vals[:2], vals[3:]...)
+	vals = append(vals[:6], vals[7:]...)
+	assert.Equal(t, checksumOf(vals), d.Checksum())
+}
+
+// FuzzList runs the same operations on the deque and the legacy container/list implementation,
+// and verifies they return the same results and hold the same lists.
+func FuzzList(f *testing.F) {
+	f.Add([]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11})
+	f.Add([]byte{1, 3, 1, 4, 1, 5, 9, 2, 6, 5, 3, 5, 8, 9, 7, 9, 3, 2, 3, 8, 4, 6, 2, 6, 4, 3, 3, 8})
+	f.Add(make([]byte, 200))
+
+	f.Fuzz(func(t *testing.T, program []byte) {
+		lis, legacy := New(), newLegacy()
+		keys := []string{"k1", "k2"}
+		for len(program) >= 4 {
+			op, key, a, b, n := program[0]%12, keys[program[1]%2], int(int8(program[2])), int(int8(program[3])), int(program[3])
+			val := []byte{byte('a' + program[2]%4)}
+			program = program[4:]
+
+			var got, want interface{}
+			switch op {
+			case 0:
+				got, want = lis.LPush(key, val), legacy.LPush(key, val)
+			case 1:
+				// push a batch so the deque spans several chunks.
+				vals := make([][]byte, chunkSize/2+n%chunkSize)
+				for i := range vals {
+					vals[i] = []byte{byte('a' + i%4)}
+				}
+				got, want = lis.RPush(key, vals...), legacy.RPush(key, vals...)
+			case 2:
+				got, want = lis.LPop(key), legacy.LPop(key)
+			case 3:
+				got, want = lis.RPop(key), legacy.RPop(key)
+			case 4:
+				got, want = lis.LIndex(key, a), legacy.LIndex(key, a)
+			case 5:
+				got, want = lis.LRem(key, val, b%4), legacy.LRem(key, val, b%4)
+			case 6:
+				pivot := []byte{byte('a' + uint8(b)%4)}
+				got, want = lis.LInsert(key, InsertOption(a&1), pivot, val), legacy.LInsert(key, InsertOption(a&1), pivot, val)
+			case 7:
+				got, want = lis.LSet(key, a, val), legacy.LSet(key, a, val)
+			case 8:
+				got, want = lis.LRange(key, a, b), legacy.LRange(key, a, b)
+			case 9:
+				got, want = lis.LTrim(key, a, b), legacy.LTrim(key, a, b)
+			case 10:
+				rank := a
+				if rank == 0 {
+					rank = 1
+				}
+				got, want = lis.LPos(key, val, rank, b&3, b>>4&7), legacy.LPos(key, val, rank, b&3, b>>4&7)
+			case 11:
+				if a&7 == 0 {
+					lis.LClear(key)
+					legacy.LClear(key)
+				}
+				got, want = lis.LValExists(key, val), legacy.LValExists(key, val)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("op %d on %s: got %v, want %v", op, key, got, want)
+			}
+
+			for _, k := range keys {
+				if lis.LLen(k) != legacy.LLen(k) || lis.LKeyExists(k) != legacy.LKeyExists(k) {
+					t.Fatalf("op %d: the length of %s is %d, want %d", op, k, lis.LLen(k), legacy.LLen(k))
+				}
+				if got, want := lis.LRange(k, 0, -1), legacy.LRange(k, 0, -1); !reflect.DeepEqual(got, want) {
+					t.Fatalf("op %d: %s is %q, want %q", op, k, got, want)
+				}
+				if lis.LChecksum(k) != checksumOf(lis.LRange(k, 0, -1)) {
+					t.Fatalf("op %d: the checksum of %s is not updated", op, k)
+				}
+			}
+		}
+	})
+}
+
+func benchmarkListMemory(b *testing.B, push func(key string, val ...[]byte) int) {
+	const n = 1 << 16
+	val := []byte("value")
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	for i := 0; i < n; i++ {
+		push("k", val)
+	}
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	runtime.KeepAlive(push)
+	b.ReportMetric(float64(after.HeapAlloc-before.HeapAlloc)/n, "bytes/elem")
+}
+
+// benchmarkSmallListsMemory measures the memory of many lists of a single element.
+func benchmarkSmallListsMemory(b *testing.B, push func(key string, val ...[]byte) int) {
+	const n = 1 << 14
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = fmt.Sprint("k", i)
+	}
+	val := []byte("value")
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	for _, key := range keys {
+		push(key, val)
+	}
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	runtime.KeepAlive(push)
+	b.ReportMetric(float64(after.HeapAlloc-before.HeapAlloc)/n, "bytes/list")
+}
+
+func BenchmarkList_RPush(b *testing.B) {
+	val := []byte("value")
+	lis := New()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		lis.RPush("k", val)
+	}
+}
+
+func BenchmarkList_LIndex(b *testing.B) {
+	lis := New()
+	for i := 0; i < 1<<16; i++ {
+		lis.RPush("k", []byte("value"))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		lis.LIndex("k", i&(1<<16-1))
+	}
+}
+
+func BenchmarkList_Memory(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		benchmarkListMemory(b, New().RPush)
+	}
+}
+
+func BenchmarkLegacyList_Memory(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		benchmarkListMemory(b, newLegacy().RPush)
+	}
+}
+
+func BenchmarkList_SmallListsMemory(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		benchmarkSmallListsMemory(b, New().RPush)
+	}
+}
+
+func BenchmarkLegacyList_SmallListsMemory(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		benchmarkSmallListsMemory(b, newLegacy().RPush)
+	}
+}
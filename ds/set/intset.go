@@ -0,0 +1,126 @@
+package set
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// maxIntsetEntries the max number of members of a set encoded as an intset, it is converted to a map when exceeded.
+var maxIntsetEntries = 512
+
+// intset is the compact encoding of a small set of integers.
+// The integers are sorted and stored in little endian with the same width, which is the smallest of 2, 4 and 8 bytes that fits all of them.
+type intset struct {
+	width int
+	data  []byte
+}
+
+func newIntset() *intset {
+	return &intset{width: 2}
+}
+
+// parseInt returns the integer of a member, ok is false if the member is not the canonical decimal form of an int64.
+func parseInt(member string) (v int64, ok bool) {
+	v, err := strconv.ParseInt(member, 10, 64)
+	if err != nil || strconv.FormatInt(v, 10) != member {
+		return 0, false
+	}
+	return v, true
+}
+
+func intWidth(v int64) int {
+	switch {
+	case v >= math.MinInt16 && v <= math.MaxInt16:
+		return 2
+	case v >= math.MinInt32 && v <= math.MaxInt32:
+		return 4
+	default:
+		return 8
+	}
+}
+
+func (is *intset) len() int {
+	return len(is.data) / is.width
+}
+
+func (is *intset) get(i int) int64 {
+	b := is.data[i*is.width:]
+	switch is.width {
+	case 2:
+		return int64(int16(binary.LittleEndian.Uint16(b)))
+	case 4:
+		return int64(int32(binary.LittleEndian.Uint32(b)))
+	default:
+		return int64(binary.LittleEndian.Uint64(b))
+	}
+}
+
+func (is *intset) put(i int, v int64) {
+	b := is.data[i*is.width:]
+	switch is.width {
+	case 2:
+		binary.LittleEndian.PutUint16(b, uint16(v))
+	case 4:
+		binary.LittleEndian.PutUint32(b, uint32(v))
+	default:
+		binary.LittleEndian.PutUint64(b, uint64(v))
+	}
+}
+
+// search returns the position of v, or the position to insert it if it does not exist.
+func (is *intset) search(v int64) (int, bool) {
+	n := is.len()
+	i := sort.Search(n, func(i int) bool {
+		return is.get(i) >= v
+	})
+	return i, i < n && is.get(i) == v
+}
+
+func (is *intset) has(v int64) bool {
+	_, ok := is.search(v)
+	return ok
+}
+
+// add returns true if v is new.
+func (is *intset) add(v int64) bool {
+	if w := intWidth(v); w > is.width {
+		is.upgrade(w)
+	}
+
+	i, ok := is.search(v)
+	if ok {
+		return false
+	}
+	is.data = append(is.data, make([]byte, is.width)...)
+	copy(is.data[(i+1)*is.width:], is.data[i*is.width:])
+	is.put(i, v)
+	return true
+}
+
+func (is *intset) remove(v int64) bool {
+	if intWidth(v) > is.width {
+		return false
+	}
+
+	i, ok := is.search(v)
+	if !ok {
+		return false
+	}
+	is.data = append(is.data[:i*is.width], is.data[(i+1)*is.width:]...)
+	return true
+}
+
+// upgrade re-encodes all the integers with a larger width.
+func (is *intset) upgrade(width int) {
+	old := *is
+	is.width, is.data = width, make([]byte, old.len()*width, (old.len()+1)*width)
+	for i := 0; i < old.len(); i++ {
+		is.put(i, old.get(i))
+	}
+}
+
+func (is *intset) member(i int) string {
+	return strconv.FormatInt(is.get(i), 10)
+}
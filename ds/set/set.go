@@ -1,8 +1,12 @@
 package set
 
-// Set is the implementation of set data structure.
+import (
+	"math/rand"
+
+	"opendb/util"
+)
 
-var existFlag = struct{}{}
+// Set is the implementation of set data structure.
 
 type (
 	// Set set index.
@@ -11,7 +15,15 @@ type (
 	}
 
 	// Record records in set to save.
-	Record map[string]map[string]struct{}
+	Record map[string]*members
+
+	// members the members of a set. A small set of integers is encoded as an intset, and it is converted to a map when it grows
+	// or a member is not an integer. Besides the map, the members are kept in a slice, so the set can be scanned by the positions in the slice.
+	members struct {
+		ints  *intset
+		index map[string]int
+		slots []string
+	}
 )
 
 // New create a new set idx.
@@ -24,11 +36,11 @@ func New() *Set {
 // If key does not exist, a new set is created before adding the specified members.
 func (s *Set) SAdd(key string, member []byte) int {
 	if !s.exist(key) {
-		s.record[key] = make(map[string]struct{})
+		s.record[key] = newMembers()
 	}
 
-	s.record[key][string(member)] = existFlag
-	return len(s.record[key])
+	s.record[key].add(string(member))
+	return s.record[key].len()
 }
 
 // SPop Removes and returns one or more random members from the set value store at key.
@@ -38,14 +50,12 @@ func (s *Set) SPop(key string, count int) [][]byte {
 		return val
 	}
 
-	for k := range s.record[key] {
-		delete(s.record[key], k)
-		val = append(val, []byte(k))
-
+	m := s.record[key]
+	for count > 0 && m.len() > 0 {
+		member := m.at(rand.Intn(m.len()))
+		m.remove(member)
+		val = append(val, []byte(member))
 		count--
-		if count == 0 {
-			break
-		}
 	}
 	return val
 }
@@ -62,25 +72,15 @@ func (s *Set) SRandMember(key string, count int) [][]byte {
 		return val
 	}
 
+	// the members are sampled in place, so it takes O(count) instead of O(n).
+	m := s.record[key]
 	if count > 0 {
-		for k := range s.record[key] {
-			val = append(val, []byte(k))
-			if len(val) == count {
-				break
-			}
-		}
-	} else {
-		count = -count
-		randomVal := func() []byte {
-			for k := range s.record[key] {
-				return []byte(k)
-			}
-			return nil
+		for _, i := range util.RandDistinct(m.len(), count) {
+			val = append(val, []byte(m.at(i)))
 		}
-
-		for count > 0 {
-			val = append(val, randomVal())
-			count--
+	} else if m.len() > 0 {
+		for count = -count; count > 0; count-- {
+			val = append(val, []byte(m.at(rand.Intn(m.len()))))
 		}
 	}
 	return val
@@ -94,12 +94,7 @@ func (s *Set) SRem(key string, member []byte) bool {
 		return false
 	}
 
-	if _, ok := s.record[key][string(member)]; ok {
-		delete(s.record[key], string(member))
-		return true
-	}
-
-	return false
+	return s.record[key].remove(string(member))
 }
 
 // SMove Move member from the set at source to the set at destination.
@@ -110,11 +105,11 @@ func (s *Set) SMove(src, dst string, member []byte) bool {
 	}
 
 	if !s.exist(dst) {
-		s.record[dst] = make(map[string]struct{})
+		s.record[dst] = newMembers()
 	}
 
-	delete(s.record[src], string(member))
-	s.record[dst][string(member)] = existFlag
+	s.record[src].remove(string(member))
+	s.record[dst].add(string(member))
 
 	return true
 }
@@ -125,7 +120,7 @@ func (s *Set) SCard(key string) int {
 		return 0
 	}
 
-	return len(s.record[key])
+	return s.record[key].len()
 }
 
 // SMembers Returns all the members of the set value stored at key.
@@ -134,7 +129,7 @@ func (s *Set) SMembers(key string) (val [][]byte) {
 		return
 	}
 
-	for k := range s.record[key] {
+	for _, k := range s.record[key].list() {
 		val = append(val, []byte(k))
 	}
 	return
@@ -145,15 +140,14 @@ func (s *Set) SUnion(keys ...string) (val [][]byte) {
 	m := make(map[string]bool)
 	for _, k := range keys {
 		if s.exist(k) {
-			for v := range s.record[k] {
-				m[v] = true
+			for _, v := range s.record[k].list() {
+				if !m[v] {
+					m[v] = true
+					val = append(val, []byte(v))
+				}
 			}
 		}
 	}
-
-	for v := range m {
-		val = append(val, []byte(v))
-	}
 	return
 }
 
@@ -163,7 +157,7 @@ func (s *Set) SDiff(keys ...string) (val [][]byte) {
 		return
 	}
 
-	for v := range s.record[keys[0]] {
+	for _, v := range s.record[keys[0]].list() {
 		flag := true
 		for i := 1; i < len(keys); i++ {
 			if s.SIsMember(keys[i], []byte(v)) {
@@ -178,6 +172,65 @@ func (s *Set) SDiff(keys ...string) (val [][]byte) {
 	return
 }
 
+// SInter Returns the members of the set resulting from the intersection of all the given sets.
+// Keys that do not exist are considered to be empty sets.
+func (s *Set) SInter(keys ...string) (val [][]byte) {
+	s.inter(keys, 0, func(member string) {
+		val = append(val, []byte(member))
+	})
+	return
+}
+
+// SInterCard Returns the cardinality of the set resulting from the intersection of all the given sets.
+// If limit is positive, the counting stops when the cardinality reaches limit.
+func (s *Set) SInterCard(limit int, keys ...string) (n int) {
+	s.inter(keys, limit, func(string) {
+		n++
+	})
+	return
+}
+
+// SMIsMember Returns whether each member is a member of the set stored at key.
+func (s *Set) SMIsMember(key string, members ...[]byte) []bool {
+	res := make([]bool, len(members))
+	for i, m := range members {
+		res[i] = s.fieldExist(key, string(m))
+	}
+	return res
+}
+
+// SStore Replaces the set stored at key with the given members.
+// If there is no member, the key is removed.
+func (s *Set) SStore(key string, members [][]byte) {
+	delete(s.record, key)
+	for _, m := range members {
+		s.SAdd(key, m)
+	}
+}
+
+// SScan iterates the members of the set stored at key.
+// Start with cursor 0 and call SScan with the returned cursor until it returns 0.
+// count is the number of members examined in a call, only the members matching the glob-style pattern are returned, an empty pattern matches all.
+// A member present for the whole iteration is returned at least once, and it may be returned more than once.
+// A set encoded as an intset is returned in a single call.
+func (s *Set) SScan(key string, cursor int, match string, count int) (next int, val [][]byte) {
+	if !s.exist(key) {
+		return
+	}
+
+	slots := s.record[key].list()
+	start, end := util.ScanRange(len(slots), cursor, count)
+	if s.record[key].ints != nil {
+		start, end = 0, len(slots)
+	}
+	for i := end - 1; i >= start; i-- {
+		if match == "" || util.GlobMatch(match, slots[i]) {
+			val = append(val, []byte(slots[i]))
+		}
+	}
+	return start, val
+}
+
 // SKeyExists returns if the key exists.
 func (s *Set) SKeyExists(key string) (ok bool) {
 	return s.exist(key)
@@ -198,11 +251,131 @@ func (s *Set) exist(key string) bool {
 
 // check if a filed of a key exists.
 func (s *Set) fieldExist(key, filed string) bool {
-	fields, exist := s.record[key]
+	m, exist := s.record[key]
 	if !exist {
 		return false
 	}
-	_, ok := fields[filed]
+	return m.has(filed)
+}
+
+// inter calls fn with the members of the intersection, at most limit members if limit is positive.
+// The smallest set is iterated, and its members are checked in the others.
+func (s *Set) inter(keys []string, limit int, fn func(member string)) {
+	if len(keys) == 0 {
+		return
+	}
+
+	smallest := keys[0]
+	for _, k := range keys {
+		if !s.exist(k) {
+			return
+		}
+		if s.record[k].len() < s.record[smallest].len() {
+			smallest = k
+		}
+	}
+
+	n := 0
+	for _, v := range s.record[smallest].list() {
+		flag := true
+		for _, k := range keys {
+			if !s.fieldExist(k, v) {
+				flag = false
+				break
+			}
+		}
+		if flag {
+			fn(v)
+			if n++; n == limit {
+				return
+			}
+		}
+	}
+}
+
+func newMembers() *members {
+	return &members{ints: newIntset()}
+}
+
+func (m *members) len() int {
+	if m.ints != nil {
+		return m.ints.len()
+	}
+	return len(m.slots)
+}
+
+func (m *members) at(i int) string {
+	if m.ints != nil {
+		return m.ints.member(i)
+	}
+	return m.slots[i]
+}
+
+// list returns the members in order, the members of an intset are sorted.
+func (m *members) list() []string {
+	if m.ints == nil {
+		return m.slots
+	}
+	res := make([]string, m.ints.len())
+	for i := range res {
+		res[i] = m.ints.member(i)
+	}
+	return res
+}
+
+func (m *members) has(member string) bool {
+	if m.ints != nil {
+		v, ok := parseInt(member)
+		return ok && m.ints.has(v)
+	}
+	_, ok := m.index[member]
 	return ok
 }
 
+func (m *members) add(member string) {
+	if m.ints != nil {
+		v, ok := parseInt(member)
+		if ok && (m.ints.len() < maxIntsetEntries || m.ints.has(v)) {
+			m.ints.add(v)
+			return
+		}
+		m.convert()
+	}
+
+	if _, ok := m.index[member]; ok {
+		return
+	}
+	m.index[member] = len(m.slots)
+	m.slots = append(m.slots, member)
+}
+
+// remove removes the member, the last member is moved to its position.
+func (m *members) remove(member string) bool {
+	if m.ints != nil {
+		v, ok := parseInt(member)
+		return ok && m.ints.remove(v)
+	}
+	i, ok := m.index[member]
+	if !ok {
+		return false
+	}
+	last := len(m.slots) - 1
+	if i != last {
+		m.slots[i] = m.slots[last]
+		m.index[m.slots[i]] = i
+	}
+	m.slots[last] = ""
+	m.slots = m.slots[:last]
+	delete(m.index, member)
+	return true
+}
+
+// convert converts the intset to a map, the members keep their order.
+func (m *members) convert() {
+	m.slots = m.list()
+	m.index = make(map[string]int, len(m.slots))
+	for i, member := range m.slots {
+		m.index[member] = i
+	}
+	m.ints = nil
+}
@@ -0,0 +1,153 @@
+package set
+
+import (
+	"math"
+	"runtime"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIntset(t *testing.T) {
+	is := newIntset()
+	for _, v := range []int64{5, -3, 100, 5, 0} {
+		is.add(v)
+	}
+	assert.Equal(t, 2, is.width)
+	assert.Equal(t, 4, is.len())
+
+	// the width grows with the integers, and they are still sorted.
+	assert.True(t, is.add(math.MaxInt16+1))
+	assert.Equal(t, 4, is.width)
+	assert.True(t, is.add(math.MinInt64))
+	assert.Equal(t, 8, is.width)
+
+	var vals []int64
+	for i := 0; i < is.len(); i++ {
+		vals = append(vals, is.get(i))
+	}
+	assert.Equal(t, []int64{math.MinInt64, -3, 0, 5, 100, math.MaxInt16 + 1}, vals)
+
+	assert.True(t, is.remove(0))
+	assert.False(t, is.remove(0))
+	assert.False(t, is.has(0))
+	assert.True(t, is.has(100))
+	assert.Equal(t, 5, is.len())
+}
+
+func TestSet_Intset(t *testing.T) {
+	s := New()
+	s.SAdd("k", []byte("10"))
+	s.SAdd("k", []byte("-2"))
+	s.SAdd("k", []byte("10"))
+	assert.NotNil(t, s.record["k"].ints)
+	assert.Equal(t, 2, s.SCard("k"))
+	assert.True(t, s.SIsMember("k", []byte("10")))
+	// not the canonical form of an integer.
+	assert.False(t, s.SIsMember("k", []byte("010")))
+	assert.Equal(t, [][]byte{[]byte("-2"), []byte("10")}, s.SMembers("k"))
+
+	// converted to a map when a member is not an integer.
+	s.SAdd("k", []byte("010"))
+	assert.Nil(t, s.record["k"].ints)
+	assert.Equal(t, [][]byte{[]byte("-2"), []byte("10"), []byte("010")}, s.SMembers("k"))
+	assert.True(t, s.SRem("k", []byte("10")))
+	assert.Equal(t, 2, s.SCard("k"))
+
+	// or when there are too many members.
+	for i := 0; i < maxIntsetEntries; i++ {
+		s.SAdd("n", []byte(strconv.Itoa(i)))
+	}
+	assert.NotNil(t, s.record["n"].ints)
+	s.SAdd("n", []byte("0"))
+	assert.NotNil(t, s.record["n"].ints)
+	s.SAdd("n", []byte("-1"))
+	assert.Nil(t, s.record["n"].ints)
+	assert.Equal(t, maxIntsetEntries+1, s.SCard("n"))
+	assert.True(t, s.SIsMember("n", []byte("511")))
+
+	assert.Equal(t, 3, len(s.SPop("n", 3)))
+	assert.Equal(t, maxIntsetEntries-2, s.SCard("n"))
+}
+
+func TestSet_SRandMember(t *testing.T) {
+	s := New()
+	assert.Nil(t, s.SRandMember("k", 1))
+	for i := 0; i < 1000; i++ {
+		s.SAdd("k", []byte("m"+strconv.Itoa(i)))
+		s.SAdd("ints", []byte(strconv.Itoa(i)))
+	}
+
+	// the members are distinct and sampled from the whole set.
+	for _, key := range []string{"k", "ints"} {
+		seen := make(map[string]bool)
+		for _, m := range s.SRandMember(key, 2000) {
+			assert.True(t, s.SIsMember(key, m))
+			seen[string(m)] = true
+		}
+		assert.Equal(t, 1000, len(seen))
+	}
+	assert.Equal(t, 1, len(s.SRandMember("k", 1)))
+	assert.Equal(t, 5, len(s.SRandMember("k", -5)))
+	assert.Nil(t, s.SRandMember("k", 0))
+}
+
+func TestSet_SScan(t *testing.T) {
+	s := New()
+	for i := 0; i < 20; i++ {
+		s.SAdd("k", []byte(strconv.Itoa(i)))
+	}
+
+	// an intset is returned in a single call.
+	cursor, val := s.SScan("k", 0, "1?", 5)
+	assert.Equal(t, 0, cursor)
+	assert.Equal(t, 10, len(val))
+
+	s.SAdd("k", []byte("a"))
+	seen := make(map[string]bool)
+	for {
+		cursor, val = s.SScan("k", cursor, "", 5)
+		for _, m := range val {
+			seen[string(m)] = true
+		}
+		if cursor == 0 {
+			break
+		}
+	}
+	assert.Equal(t, 21, len(seen))
+}
+
+func benchmarkSmallSets(b *testing.B) {
+	const n = 1 << 14
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	s := New()
+	for i := 0; i < n; i++ {
+		k := "set:" + strconv.Itoa(i)
+		for j := 0; j < 16; j++ {
+			s.SAdd(k, []byte(strconv.Itoa(i*16+j)))
+		}
+	}
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	runtime.KeepAlive(s)
+	b.ReportMetric(float64(after.HeapAlloc-before.HeapAlloc)/n, "bytes/set")
+}
+
+// BenchmarkSet_SmallMemory reports the memory of sets with 16 integers, which are encoded as intsets.
+func BenchmarkSet_SmallMemory(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		benchmarkSmallSets(b)
+	}
+}
+
+// BenchmarkSet_SmallMemoryMap reports the memory of the same sets stored in maps.
+func BenchmarkSet_SmallMemoryMap(b *testing.B) {
+	defer func(n int) { maxIntsetEntries = n }(maxIntsetEntries)
+	maxIntsetEntries = 0
+	for i := 0; i < b.N; i++ {
+		benchmarkSmallSets(b)
+	}
+}
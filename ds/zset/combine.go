@@ -0,0 +1,154 @@
+package zset
+
+import (
+	"math"
+	"sort"
+)
+
+// Aggregate how the scores of a member in several sorted sets are combined.
+type Aggregate uint8
+
+const (
+	// AggregateSum the scores are summed.
+	AggregateSum Aggregate = iota
+	// AggregateMin the minimum score is kept.
+	AggregateMin
+	// AggregateMax the maximum score is kept.
+	AggregateMax
+)
+
+func (agg Aggregate) combine(a, b float64) float64 {
+	switch agg {
+	case AggregateMin:
+		return math.Min(a, b)
+	case AggregateMax:
+		return math.Max(a, b)
+	default:
+		return zeroNaN(a + b)
+	}
+}
+
+// zeroNaN returns 0 for NaN, which is the result of adding or multiplying infinities.
+func zeroNaN(score float64) float64 {
+	if math.IsNaN(score) {
+		return 0
+	}
+	return score
+}
+
+// weighted returns the score multiplied by the weight of the i-th sorted set, the weight is 1 if weights is nil.
+func weighted(weights []float64, i int, score float64) float64 {
+	if weights == nil {
+		return score
+	}
+	return zeroNaN(weights[i] * score)
+}
+
+// ZUnion returns the union of the sorted sets stored at keys, the members are followed by their scores and ordered as in a sorted set.
+// The scores of the i-th sorted set are multiplied by weights[i], and the scores of a member are combined by agg.
+// Keys that do not exist are considered to be empty sorted sets.
+func (z *SortedSet) ZUnion(keys []string, weights []float64, agg Aggregate) []interface{} {
+	scores := make(map[string]float64)
+	for i, k := range keys {
+		if !z.exist(k) {
+			continue
+		}
+		for p := z.record[k].skl.head.level[0].forward; p != nil; p = p.level[0].forward {
+			score := weighted(weights, i, p.score)
+			if old, ok := scores[p.member]; ok {
+				score = agg.combine(old, score)
+			}
+			scores[p.member] = score
+		}
+	}
+	return sortedPairs(scores)
+}
+
+// ZInter returns the intersection of the sorted sets stored at keys, the members are followed by their scores and ordered as in a sorted set.
+// The scores of the i-th sorted set are multiplied by weights[i], and the scores of a member are combined by agg.
+func (z *SortedSet) ZInter(keys []string, weights []float64, agg Aggregate) []interface{} {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	// iterate the smallest sorted set, and look up its members in the others.
+	smallest := keys[0]
+	for _, k := range keys {
+		if !z.exist(k) {
+			return nil
+		}
+		if z.ZCard(k) < z.ZCard(smallest) {
+			smallest = k
+		}
+	}
+
+	scores := make(map[string]float64)
+	for member := range z.record[smallest].dict {
+		var score float64
+		inAll := true
+		for i, k := range keys {
+			node, ok := z.record[k].dict[member]
+			if !ok {
+				inAll = false
+				break
+			}
+			if i == 0 {
+				score = weighted(weights, i, node.score)
+			} else {
+				score = agg.combine(score, weighted(weights, i, node.score))
+			}
+		}
+		if inAll {
+			scores[member] = score
+		}
+	}
+	return sortedPairs(scores)
+}
+
+// ZDiff returns the members of the first sorted set that are not in the successive ones, followed by their scores and ordered as in a sorted set.
+func (z *SortedSet) ZDiff(keys []string) (val []interface{}) {
+	if len(keys) == 0 || !z.exist(keys[0]) {
+		return
+	}
+
+	for p := z.record[keys[0]].skl.head.level[0].forward; p != nil; p = p.level[0].forward {
+		diff := true
+		for _, k := range keys[1:] {
+			if ok, _ := z.ZScore(k, p.member); ok {
+				diff = false
+				break
+			}
+		}
+		if diff {
+			val = append(val, p.member, p.score)
+		}
+	}
+	return
+}
+
+// ZStore replaces the sorted set stored at key with the members followed by their scores.
+// If there is no member, the key is removed.
+func (z *SortedSet) ZStore(key string, pairs []interface{}) {
+	z.ZClear(key)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		z.ZAdd(key, pairs[i+1].(float64), pairs[i].(string))
+	}
+}
+
+// sortedPairs returns the members followed by their scores, ordered by score and then by member.
+func sortedPairs(scores map[string]float64) []interface{} {
+	members := make([]string, 0, len(scores))
+	for m := range scores {
+		members = append(members, m)
+	}
+	sort.Slice(members, func(i, j int) bool {
+		si, sj := scores[members[i]], scores[members[j]]
+		return si < sj || (si == sj && members[i] < members[j])
+	})
+
+	var val []interface{}
+	for _, m := range members {
+		val = append(val, m, scores[m])
+	}
+	return val
+}
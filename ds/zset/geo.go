@@ -0,0 +1,273 @@
+package zset
+
+import (
+	"math"
+	"sort"
+)
+
+// The geohash of a position is the interleaved bits of its latitude and longitude, 26 bits each.
+// The 52 bits are exact in a float64, so the geohash is used as the score, and the positions near each other
+// are mostly in the same score range.
+const (
+	geoStep     = 26
+	geoLonMin   = -180.0
+	geoLonMax   = 180.0
+	geoLatMin   = -85.05112878
+	geoLatMax   = 85.05112878
+	mercatorMax = 20037726.37
+	// earthRadius the radius of the earth in meters, the same as Redis uses.
+	earthRadius = 6372797.560856
+)
+
+const geoAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+type (
+	// GeoShape the area of a search in meters, a circle if Radius is positive, otherwise a box of Width and Height.
+	GeoShape struct {
+		Radius float64
+		Width  float64
+		Height float64
+	}
+
+	// GeoMatch a member found by a search, with its score and distance in meters to the center.
+	GeoMatch struct {
+		Member   string
+		Score    float64
+		Distance float64
+	}
+)
+
+// GeoValid returns if the position can be encoded.
+func GeoValid(lon, lat float64) bool {
+	return lon >= geoLonMin && lon <= geoLonMax && lat >= geoLatMin && lat <= geoLatMax
+}
+
+// GeoEncode returns the geohash of the position as a score, the position must be valid.
+func GeoEncode(lon, lat float64) float64 {
+	return float64(geoEncode(lon, lat, geoLatMin, geoLatMax, geoStep))
+}
+
+// GeoDecode returns the center of the area of a geohash score.
+func GeoDecode(score float64) (lon, lat float64) {
+	lonMin, lonMax, latMin, latMax := geoDecode(uint64(score), geoStep)
+	lon = math.Max(geoLonMin, math.Min(geoLonMax, (lonMin+lonMax)/2))
+	lat = math.Max(geoLatMin, math.Min(geoLatMax, (latMin+latMax)/2))
+	return
+}
+
+// GeoHashString returns the standard 11 characters geohash of the position.
+func GeoHashString(lon, lat float64) string {
+	// the standard geohash covers the latitudes from -90 to 90.
+	bits := geoEncode(lon, lat, -90, 90, geoStep)
+	buf := make([]byte, 11)
+	for i := range buf {
+		// the last character has no bits left.
+		if i < 10 {
+			buf[i] = geoAlphabet[bits>>(52-(i+1)*5)&0x1f]
+		} else {
+			buf[i] = geoAlphabet[0]
+		}
+	}
+	return string(buf)
+}
+
+// GeoDistance returns the distance in meters between two positions by the haversine formula.
+func GeoDistance(lon1, lat1, lon2, lat2 float64) float64 {
+	lat1r, lat2r := degRad(lat1), degRad(lat2)
+	u := math.Sin((lat2r - lat1r) / 2)
+	v := math.Sin((degRad(lon2) - degRad(lon1)) / 2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(u*u+math.Cos(lat1r)*math.Cos(lat2r)*v*v))
+}
+
+// GeoSearch returns the members of the sorted set stored at key in the shape around the center, ordered by distance.
+// Only the score ranges of the geohash area of the center and its neighbors are searched, the areas are large enough to cover the shape.
+func (z *SortedSet) GeoSearch(key string, lon, lat float64, shape GeoShape) []GeoMatch {
+	if !z.exist(key) {
+		return nil
+	}
+
+	var matches []GeoMatch
+	skl := z.record[key].skl
+	for _, r := range geoSearchRanges(lon, lat, shape) {
+		skl.rangeByScore(r[0], r[1], func(p *sklNode) {
+			plon, plat := GeoDecode(p.score)
+			if d, ok := shape.contains(lon, lat, plon, plat); ok {
+				matches = append(matches, GeoMatch{Member: p.member, Score: p.score, Distance: d})
+			}
+		})
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Distance < matches[j].Distance
+	})
+	return matches
+}
+
+// contains returns the distance from the center to the position, ok is false if the position is out of the shape.
+func (shape GeoShape) contains(lon, lat, plon, plat float64) (float64, bool) {
+	if shape.Radius > 0 {
+		d := GeoDistance(lon, lat, plon, plat)
+		return d, d <= shape.Radius
+	}
+
+	if earthRadius*math.Abs(degRad(plat)-degRad(lat)) > shape.Height/2 ||
+		GeoDistance(lon, plat, plon, plat) > shape.Width/2 {
+		return 0, false
+	}
+	return GeoDistance(lon, lat, plon, plat), true
+}
+
+// geoSearchRanges returns the score ranges [min, max) of the area of the center and its 8 neighbors.
+// The step of the areas is estimated by the size of the shape, and decreased if the neighbors do not cover its bounding box.
+func geoSearchRanges(lon, lat float64, shape GeoShape) [][2]float64 {
+	halfWidth, halfHeight := shape.Radius, shape.Radius
+	if shape.Radius <= 0 {
+		halfWidth, halfHeight = shape.Width/2, shape.Height/2
+	}
+
+	// the bounding box of the shape.
+	latDelta := radDeg(halfHeight / earthRadius)
+	lonDeltaTop := radDeg(halfWidth / earthRadius / math.Cos(degRad(lat+latDelta)))
+	lonDeltaBottom := radDeg(halfWidth / earthRadius / math.Cos(degRad(lat-latDelta)))
+	lonDelta := lonDeltaTop
+	if lat < 0 {
+		lonDelta = lonDeltaBottom
+	}
+	minLon, maxLon := lon-lonDelta, lon+lonDelta
+	minLat, maxLat := lat-latDelta, lat+latDelta
+
+	step := geoEstimateStep(math.Sqrt(halfWidth*halfWidth+halfHeight*halfHeight), lat)
+	if step > 1 {
+		lonIdx, latIdx := geoCell(lon, lat, step)
+		lonMin, _, _, _ := geoCellArea(lonIdx-1, latIdx, step)
+		_, lonMax, _, _ := geoCellArea(lonIdx+1, latIdx, step)
+		_, _, latMin, _ := geoCellArea(lonIdx, latIdx-1, step)
+		_, _, _, latMax := geoCellArea(lonIdx, latIdx+1, step)
+		if lonMin > minLon || lonMax < maxLon || latMin > minLat || latMax < maxLat {
+			step--
+		}
+	}
+
+	lonIdx, latIdx := geoCell(lon, lat, step)
+	cells := uint64(1) << step
+	seen := make(map[uint64]bool)
+	var ranges [][2]float64
+	for dlat := -1; dlat <= 1; dlat++ {
+		y := int64(latIdx) + int64(dlat)
+		if y < 0 || y >= int64(cells) {
+			continue
+		}
+		for dlon := -1; dlon <= 1; dlon++ {
+			// the longitudes wrap around.
+			x := uint64((int64(lonIdx) + int64(dlon) + int64(cells)) % int64(cells))
+			bits := interleave(uint64(y), x)
+			if seen[bits] {
+				continue
+			}
+			seen[bits] = true
+			shift := 2 * (geoStep - step)
+			ranges = append(ranges, [2]float64{float64(bits << shift), float64((bits + 1) << shift)})
+		}
+	}
+	return ranges
+}
+
+// geoEstimateStep returns the step of the areas which are larger than the radius, the areas are smaller near the poles.
+func geoEstimateStep(radius, lat float64) uint {
+	if radius == 0 {
+		return geoStep
+	}
+
+	step := 1
+	for radius < mercatorMax {
+		radius *= 2
+		step++
+	}
+	step -= 2
+	if lat > 66 || lat < -66 {
+		step--
+		if lat > 80 || lat < -80 {
+			step--
+		}
+	}
+	if step < 1 {
+		step = 1
+	}
+	if step > geoStep {
+		step = geoStep
+	}
+	return uint(step)
+}
+
+// geoCell returns the indexes of the longitude and latitude of the area containing the position.
+func geoCell(lon, lat float64, step uint) (lonIdx, latIdx uint64) {
+	lat = math.Max(geoLatMin, math.Min(geoLatMax, lat))
+	return cellIndex(lon, geoLonMin, geoLonMax, step), cellIndex(lat, geoLatMin, geoLatMax, step)
+}
+
+// cellIndex returns the index of the area of v when [min, max] is divided into 2^step areas.
+func cellIndex(v, min, max float64, step uint) uint64 {
+	cells := uint64(1) << step
+	idx := uint64((v - min) / (max - min) * float64(cells))
+	// max is in the last area.
+	if idx >= cells {
+		idx = cells - 1
+	}
+	return idx
+}
+
+// geoCellArea returns the bounds of the area, the indexes may be out of range by one.
+func geoCellArea(lonIdx, latIdx uint64, step uint) (lonMin, lonMax, latMin, latMax float64) {
+	cells := float64(uint64(1) << step)
+	lonUnit, latUnit := (geoLonMax-geoLonMin)/cells, (geoLatMax-geoLatMin)/cells
+	lonMin = geoLonMin + float64(int64(lonIdx))*lonUnit
+	latMin = geoLatMin + float64(int64(latIdx))*latUnit
+	return lonMin, lonMin + lonUnit, latMin, latMin + latUnit
+}
+
+func geoEncode(lon, lat, latMin, latMax float64, step uint) uint64 {
+	return interleave(cellIndex(lat, latMin, latMax, step), cellIndex(lon, geoLonMin, geoLonMax, step))
+}
+
+func geoDecode(bits uint64, step uint) (lonMin, lonMax, latMin, latMax float64) {
+	latIdx, lonIdx := deinterleave(bits)
+	return geoCellArea(lonIdx, latIdx, step)
+}
+
+// interleave puts the bits of x at the even positions and the bits of y at the odd positions.
+func interleave(x, y uint64) (bits uint64) {
+	for i := 0; i < 32; i++ {
+		bits |= (x>>i&1)<<(2*i) | (y>>i&1)<<(2*i+1)
+	}
+	return
+}
+
+func deinterleave(bits uint64) (x, y uint64) {
+	for i := 0; i < 32; i++ {
+		x |= (bits >> (2 * i) & 1) << i
+		y |= (bits >> (2*i + 1) & 1) << i
+	}
+	return
+}
+
+func degRad(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func radDeg(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
+
+// rangeByScore calls fn with the nodes with a score in [min, max).
+func (skl *skipList) rangeByScore(min, max float64, fn func(p *sklNode)) {
+	p := skl.head
+	for i := skl.level - 1; i >= 0; i-- {
+		for p.level[i].forward != nil && p.level[i].forward.score < min {
+			p = p.level[i].forward
+		}
+	}
+
+	for p = p.level[0].forward; p != nil && p.score < max; p = p.level[0].forward {
+		fn(p)
+	}
+}
@@ -0,0 +1,179 @@
+package zset
+
+type (
+	// LexRange a lexicographical range of members, the members are compared bytewise.
+	// It is only meaningful if all the members of the sorted set have the same score.
+	LexRange struct {
+		min lexBound
+		max lexBound
+	}
+
+	lexBound struct {
+		member    string
+		exclusive bool
+		// -1 for "-", the smallest member, 1 for "+", the largest member.
+		inf int
+	}
+)
+
+// ParseLexRange parses the bounds of a lexicographical range.
+// A bound is "[member" for inclusive, "(member" for exclusive, "-" for negative infinity or "+" for positive infinity.
+func ParseLexRange(min, max string) (*LexRange, bool) {
+	minBound, ok := parseLexBound(min)
+	if !ok {
+		return nil, false
+	}
+	maxBound, ok := parseLexBound(max)
+	if !ok {
+		return nil, false
+	}
+	return &LexRange{min: minBound, max: maxBound}, true
+}
+
+func parseLexBound(s string) (b lexBound, ok bool) {
+	if len(s) == 0 {
+		return
+	}
+
+	switch s[0] {
+	case '-':
+		if len(s) == 1 {
+			return lexBound{inf: -1}, true
+		}
+	case '+':
+		if len(s) == 1 {
+			return lexBound{inf: 1}, true
+		}
+	case '[':
+		return lexBound{member: s[1:]}, true
+	case '(':
+		return lexBound{member: s[1:], exclusive: true}, true
+	}
+	return
+}
+
+// empty returns if no member can be in the range.
+func (r *LexRange) empty() bool {
+	if r.min.inf == 1 || r.max.inf == -1 {
+		return true
+	}
+	if r.min.inf != 0 || r.max.inf != 0 {
+		return false
+	}
+	return r.min.member > r.max.member ||
+		(r.min.member == r.max.member && (r.min.exclusive || r.max.exclusive))
+}
+
+// gteMin returns if the member is not less than the min bound.
+func (r *LexRange) gteMin(member string) bool {
+	switch {
+	case r.min.inf != 0:
+		return r.min.inf < 0
+	case r.min.exclusive:
+		return member > r.min.member
+	default:
+		return member >= r.min.member
+	}
+}
+
+// lteMax returns if the member is not greater than the max bound.
+func (r *LexRange) lteMax(member string) bool {
+	switch {
+	case r.max.inf != 0:
+		return r.max.inf > 0
+	case r.max.exclusive:
+		return member < r.max.member
+	default:
+		return member <= r.max.member
+	}
+}
+
+// ZRangeByLex returns the members in the lexicographical range, skipping offset members and returning at most count members.
+// A negative count means all the members after offset.
+func (z *SortedSet) ZRangeByLex(key string, r *LexRange, offset, count int) (val []string) {
+	if !z.exist(key) || offset < 0 {
+		return
+	}
+
+	skl := z.record[key].skl
+	p := skl.firstInLexRange(r)
+	if p != nil && offset > 0 {
+		p = skl.sklGetElementByRank(uint64(skl.sklGetRank(p.score, p.member)) + uint64(offset))
+	}
+
+	for ; p != nil && r.lteMax(p.member) && count != 0; p = p.level[0].forward {
+		val = append(val, p.member)
+		count--
+	}
+	return
+}
+
+// ZLexCount returns the number of members in the lexicographical range.
+func (z *SortedSet) ZLexCount(key string, r *LexRange) int {
+	if !z.exist(key) {
+		return 0
+	}
+
+	skl := z.record[key].skl
+	first := skl.firstInLexRange(r)
+	if first == nil {
+		return 0
+	}
+	last := skl.lastInLexRange(r)
+	return int(skl.sklGetRank(last.score, last.member)-skl.sklGetRank(first.score, first.member)) + 1
+}
+
+// ZRemRangeByLex removes the members in the lexicographical range, and returns the number of removed members.
+func (z *SortedSet) ZRemRangeByLex(key string, r *LexRange) int {
+	if !z.exist(key) {
+		return 0
+	}
+
+	var members []string
+	for p := z.record[key].skl.firstInLexRange(r); p != nil && r.lteMax(p.member); p = p.level[0].forward {
+		members = append(members, p.member)
+	}
+	for _, m := range members {
+		z.ZRem(key, m)
+	}
+	return len(members)
+}
+
+// firstInLexRange returns the first node in the range, or nil if there is none.
+func (skl *skipList) firstInLexRange(r *LexRange) *sklNode {
+	if r.empty() {
+		return nil
+	}
+
+	p := skl.head
+	for i := skl.level - 1; i >= 0; i-- {
+		for p.level[i].forward != nil && !r.gteMin(p.level[i].forward.member) {
+			p = p.level[i].forward
+		}
+	}
+
+	p = p.level[0].forward
+	if p == nil || !r.lteMax(p.member) {
+		return nil
+	}
+	return p
+}
+
+// lastInLexRange returns the last node in the range, or nil if there is none.
+func (skl *skipList) lastInLexRange(r *LexRange) *sklNode {
+	if r.empty() {
+		return nil
+	}
+
+	p := skl.head
+	for i := skl.level - 1; i >= 0; i-- {
+		for p.level[i].forward != nil && r.lteMax(p.level[i].forward.member) {
+			p = p.level[i].forward
+		}
+	}
+
+	if p == skl.head || !r.gteMin(p.member) {
+		return nil
+	}
+	return p
+}
@@ -3,6 +3,8 @@ package zset
 import (
 	"math"
 	"math/rand"
+
+	"opendb/util"
 )
 
 // zset is the implementation of sorted set
@@ -22,6 +24,8 @@ type (
 	SortedSetNode struct {
 		dict map[string]*sklNode
 		skl  *skipList
+		// the members are also kept in a slice, so the sorted set can be scanned by the positions in the slice.
+		slots []string
 	}
 
 	sklLevel struct {
@@ -32,6 +36,7 @@ type (
 	sklNode struct {
 		member   string
 		score    float64
+		slot     int
 		backward *sklNode
 		level    []*sklLevel
 	}
@@ -70,9 +75,12 @@ func (z *SortedSet) ZAdd(key string, score float64, member string) {
 		if score != v.score {
 			item.skl.sklDelete(v.score, member)
 			node = item.skl.sklInsert(score, member)
+			node.slot = v.slot
 		}
 	} else {
 		node = item.skl.sklInsert(score, member)
+		node.slot = len(item.slots)
+		item.slots = append(item.slots, member)
 	}
 
 	if node != nil {
@@ -200,16 +208,114 @@ func (z *SortedSet) ZRem(key, member string) bool {
 		return false
 	}
 
-	v, exist := z.record[key].dict[member]
+	item := z.record[key]
+	v, exist := item.dict[member]
 	if exist {
-		z.record[key].skl.sklDelete(v.score, member)
-		delete(z.record[key].dict, member)
+		item.skl.sklDelete(v.score, member)
+		delete(item.dict, member)
+
+		// move the last member to the slot of the removed one.
+		last := len(item.slots) - 1
+		if v.slot != last {
+			item.slots[v.slot] = item.slots[last]
+			item.dict[item.slots[v.slot]].slot = v.slot
+		}
+		item.slots[last] = ""
+		item.slots = item.slots[:last]
 		return true
 	}
 
 	return false
 }
 
+// ZCount returns the number of members in the sorted set stored at key with a score between min and max (inclusive).
+func (z *SortedSet) ZCount(key string, min, max float64) int {
+	if !z.exist(key) || min > max {
+		return 0
+	}
+
+	skl := z.record[key].skl
+	// the number of nodes with a score less than min, and the number of nodes with a score not greater than max.
+	var less, lte uint64
+	p := skl.head
+	for i := skl.level - 1; i >= 0; i-- {
+		for p.level[i].forward != nil && p.level[i].forward.score < min {
+			less += p.level[i].span
+			p = p.level[i].forward
+		}
+	}
+	p = skl.head
+	for i := skl.level - 1; i >= 0; i-- {
+		for p.level[i].forward != nil && p.level[i].forward.score <= max {
+			lte += p.level[i].span
+			p = p.level[i].forward
+		}
+	}
+	return int(lte - less)
+}
+
+// ZRemRangeByScore removes all the members in the sorted set stored at key with a score between min and max (inclusive),
+// and returns the number of removed members.
+func (z *SortedSet) ZRemRangeByScore(key string, min, max float64) int {
+	if !z.exist(key) || min > max {
+		return 0
+	}
+
+	skl := z.record[key].skl
+	p := skl.head
+	for i := skl.level - 1; i >= 0; i-- {
+		for p.level[i].forward != nil && p.level[i].forward.score < min {
+			p = p.level[i].forward
+		}
+	}
+
+	var members []string
+	for p = p.level[0].forward; p != nil && p.score <= max; p = p.level[0].forward {
+		members = append(members, p.member)
+	}
+	for _, m := range members {
+		z.ZRem(key, m)
+	}
+	return len(members)
+}
+
+// ZRemRangeByRank removes all the members in the sorted set stored at key with a rank between start and stop (inclusive),
+// and returns the number of removed members. The ranks are 0-based, and negative ranks count from the highest score.
+func (z *SortedSet) ZRemRangeByRank(key string, start, stop int) int {
+	if !z.exist(key) {
+		return 0
+	}
+
+	var members []string
+	for _, m := range z.findRange(key, int64(start), int64(stop), false, false) {
+		members = append(members, m.(string))
+	}
+	for _, m := range members {
+		z.ZRem(key, m)
+	}
+	return len(members)
+}
+
+// ZScan iterates the members of the sorted set stored at key, every member in val is followed by its score.
+// Start with cursor 0 and call ZScan with the returned cursor until it returns 0.
+// count is the number of members examined in a call, only the members matching the glob-style pattern are returned, an empty pattern matches all.
+// A member present for the whole iteration is returned at least once, and it may be returned more than once.
+func (z *SortedSet) ZScan(key string, cursor int, match string, count int) (next int, val []interface{}) {
+	if !z.exist(key) {
+		return
+	}
+
+	item := z.record[key]
+	start, end := util.ScanRange(len(item.slots), cursor, count)
+	for i := end - 1; i >= start; i-- {
+		member := item.slots[i]
+		if match == "" || util.GlobMatch(match, member) {
+			val = append(val, member, item.dict[member].score)
+		}
+	}
+	return start, val
+}
+
 // ZGetByRank 根据排名获取member及分值信息，从小到大排列遍历，即分值最低排名为0，依次类推
 // Get the member at key by rank, the rank is ordered from lowest to highest.
 // The rank of lowest is 0 and so on.
@@ -243,6 +349,9 @@ func (z *SortedSet) ZScoreRange(key string, min, max float64) (val []interface{}
 	}
 
 	item := z.record[key].skl
+	if item.length == 0 {
+		return
+	}
 	minScore := item.head.level[0].forward.score
 	if min < minScore {
 		min = minScore
@@ -281,6 +390,9 @@ func (z *SortedSet) ZRevScoreRange(key string, max, min float64) (val []interfac
 	}
 
 	item := z.record[key].skl
+	if item.length == 0 {
+		return
+	}
 	minScore := item.head.level[0].forward.score
 	if min < minScore {
 		min = minScore
@@ -0,0 +1,210 @@
+package opendb
+
+import (
+	"opendb/ds/zset"
+)
+
+// The positions are stored in sorted sets, the score of a member is the 52 bits geohash of its position,
+// so they are logged, replayed and can be read like any other sorted set.
+
+// GeoUnit the unit of a distance.
+type GeoUnit string
+
+const (
+	// GeoMeters meters, it is the default.
+	GeoMeters GeoUnit = "m"
+	// GeoKilometers kilometers.
+	GeoKilometers GeoUnit = "km"
+	// GeoFeet feet.
+	GeoFeet GeoUnit = "ft"
+	// GeoMiles miles.
+	GeoMiles GeoUnit = "mi"
+)
+
+var geoUnitMeters = map[GeoUnit]float64{
+	"":            1,
+	GeoMeters:     1,
+	GeoKilometers: 1000,
+	GeoFeet:       0.3048,
+	GeoMiles:      1609.34,
+}
+
+type (
+	// GeoPoint a position on the earth.
+	GeoPoint struct {
+		Longitude float64
+		Latitude  float64
+	}
+
+	// GeoLocation a member with its position.
+	GeoLocation struct {
+		Longitude float64
+		Latitude  float64
+		Member    []byte
+	}
+
+	// GeoSearchOptions the center, area and order of GeoSearch.
+	GeoSearchOptions struct {
+		// FromMember the center is the position of the member, or Longitude and Latitude if it is nil.
+		FromMember []byte
+		Longitude  float64
+		Latitude   float64
+		// Radius searches in a circle if it is positive, otherwise in a box of Width and Height.
+		Radius float64
+		Width  float64
+		Height float64
+		// Unit the unit of Radius, Width, Height and the distances of the results.
+		Unit GeoUnit
+		// Count returns at most Count members if it is positive.
+		Count int
+		// Desc returns the farthest members first.
+		Desc bool
+	}
+
+	// GeoResult a member found by GeoSearch, with its distance to the center.
+	GeoResult struct {
+		Member   []byte
+		Distance float64
+		GeoPoint
+	}
+)
+
+// GeoAdd adds the members with their positions to the sorted set stored at key, and returns the number of added members.
+// The position of an existing member is updated.
+func (db *OpenDB) GeoAdd(key []byte, locations ...GeoLocation) (int, error) {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return 0, err
+	}
+	if len(locations) == 0 {
+		return 0, ErrWrongNumberOfArgs
+	}
+	// check all the positions first, so no member is added if any of them is invalid.
+	for _, l := range locations {
+		if !zset.GeoValid(l.Longitude, l.Latitude) {
+			return 0, ErrInvalidCoordinates
+		}
+	}
+
+	db.zsetIndex.mu.Lock()
+	defer db.zsetIndex.mu.Unlock()
+
+	var n int
+	for _, l := range locations {
+		res, err := db.zAdd(key, zset.GeoEncode(l.Longitude, l.Latitude), l.Member, ZAddOptions{}, false)
+		if err != nil {
+			return n, err
+		}
+		if res == zAddAdded {
+			n++
+		}
+	}
+	db.wakeZSetWaiters(key)
+	return n, nil
+}
+
+// GeoPos returns the positions of the members in the sorted set stored at key, the position of a member that does not exist is nil.
+func (db *OpenDB) GeoPos(key []byte, members ...[]byte) []*GeoPoint {
+	db.zsetIndex.mu.RLock()
+	defer db.zsetIndex.mu.RUnlock()
+
+	points := make([]*GeoPoint, len(members))
+	if db.checkExpired(key, ZSet) {
+		return points
+	}
+	for i, m := range members {
+		if ok, score := db.zsetIndex.indexes.ZScore(string(key), string(m)); ok {
+			lon, lat := zset.GeoDecode(score)
+			points[i] = &GeoPoint{Longitude: lon, Latitude: lat}
+		}
+	}
+	return points
+}
+
+// GeoDist returns the distance between two members in the sorted set stored at key in unit.
+// ok is false if any of the members does not exist.
+func (db *OpenDB) GeoDist(key, member1, member2 []byte, unit GeoUnit) (dist float64, ok bool, err error) {
+	factor, valid := geoUnitMeters[unit]
+	if !valid {
+		err = ErrInvalidGeoUnit
+		return
+	}
+
+	points := db.GeoPos(key, member1, member2)
+	if points[0] == nil || points[1] == nil {
+		return
+	}
+	d := zset.GeoDistance(points[0].Longitude, points[0].Latitude, points[1].Longitude, points[1].Latitude)
+	return d / factor, true, nil
+}
+
+// GeoHash returns the standard 11 characters geohash of the members in the sorted set stored at key,
+// the geohash of a member that does not exist is empty.
+func (db *OpenDB) GeoHash(key []byte, members ...[]byte) []string {
+	hashes := make([]string, len(members))
+	for i, p := range db.GeoPos(key, members...) {
+		if p != nil {
+			hashes[i] = zset.GeoHashString(p.Longitude, p.Latitude)
+		}
+	}
+	return hashes
+}
+
+// GeoSearch returns the members of the sorted set stored at key in the area of opts, ordered by their distance to the center.
+func (db *OpenDB) GeoSearch(key []byte, opts GeoSearchOptions) ([]GeoResult, error) {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return nil, err
+	}
+	factor, ok := geoUnitMeters[opts.Unit]
+	if !ok {
+		return nil, ErrInvalidGeoUnit
+	}
+	shape := zset.GeoShape{Radius: opts.Radius * factor}
+	if opts.Radius <= 0 {
+		if opts.Width <= 0 || opts.Height <= 0 {
+			return nil, ErrInvalidGeoShape
+		}
+		shape.Width, shape.Height = opts.Width*factor, opts.Height*factor
+	}
+
+	db.zsetIndex.mu.RLock()
+	defer db.zsetIndex.mu.RUnlock()
+
+	if db.checkExpired(key, ZSet) {
+		if opts.FromMember != nil {
+			return nil, ErrMemberNotExist
+		}
+		return nil, nil
+	}
+
+	lon, lat := opts.Longitude, opts.Latitude
+	if opts.FromMember != nil {
+		ok, score := db.zsetIndex.indexes.ZScore(string(key), string(opts.FromMember))
+		if !ok {
+			return nil, ErrMemberNotExist
+		}
+		lon, lat = zset.GeoDecode(score)
+	} else if !zset.GeoValid(lon, lat) {
+		return nil, ErrInvalidCoordinates
+	}
+
+	matches := db.zsetIndex.indexes.GeoSearch(string(key), lon, lat, shape)
+	if opts.Desc {
+		for i, j := 0, len(matches)-1; i < j; i, j = i+1, j-1 {
+			matches[i], matches[j] = matches[j], matches[i]
+		}
+	}
+	if opts.Count > 0 && len(matches) > opts.Count {
+		matches = matches[:opts.Count]
+	}
+
+	results := make([]GeoResult, len(matches))
+	for i, m := range matches {
+		plon, plat := zset.GeoDecode(m.Score)
+		results[i] = GeoResult{
+			Member:   []byte(m.Member),
+			Distance: m.Distance / factor,
+			GeoPoint: GeoPoint{Longitude: plon, Latitude: plat},
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,194 @@
+package opendb
+
+import (
+	"math/rand"
+	"sort"
+	"strconv"
+	"testing"
+
+	"opendb/ds/zset"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func addSicily(t *testing.T, db *OpenDB) {
+	n, err := db.GeoAdd([]byte("sicily"),
+		GeoLocation{Longitude: 13.361389, Latitude: 38.115556, Member: []byte("Palermo")},
+		GeoLocation{Longitude: 15.087269, Latitude: 37.502669, Member: []byte("Catania")},
+	)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+}
+
+func TestOpenDB_GeoAdd(t *testing.T) {
+	path := t.TempDir()
+	db, err := Open(DefaultOptions(path))
+	assert.Nil(t, err)
+	addSicily(t, db)
+
+	// an invalid position adds nothing.
+	_, err = db.GeoAdd([]byte("sicily"),
+		GeoLocation{Longitude: 12.758489, Latitude: 38.788135, Member: []byte("edge1")},
+		GeoLocation{Longitude: 0, Latitude: 86, Member: []byte("north")},
+	)
+	assert.Equal(t, ErrInvalidCoordinates, err)
+	_, err = db.GeoAdd([]byte("sicily"))
+	assert.Equal(t, ErrWrongNumberOfArgs, err)
+	assert.Equal(t, 2, db.ZCard([]byte("sicily")))
+
+	// the score is the geohash of the position.
+	_, score := db.ZScore([]byte("sicily"), []byte("Palermo"))
+	assert.Equal(t, float64(3479099956230698), score)
+
+	// moving a member does not add it.
+	n, err := db.GeoAdd([]byte("sicily"), GeoLocation{Longitude: 13.5, Latitude: 38, Member: []byte("Palermo")})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, n)
+
+	db, err = Open(DefaultOptions(path))
+	assert.Nil(t, err)
+	pos := db.GeoPos([]byte("sicily"), []byte("Palermo"), []byte("Catania"))
+	assert.InDelta(t, 13.5, pos[0].Longitude, 1e-5)
+	assert.InDelta(t, 38, pos[0].Latitude, 1e-5)
+	assert.InDelta(t, 15.087269, pos[1].Longitude, 1e-5)
+	assert.InDelta(t, 37.502669, pos[1].Latitude, 1e-5)
+}
+
+func TestOpenDB_GeoPos(t *testing.T) {
+	db := openTestDB(t)
+	addSicily(t, db)
+
+	pos := db.GeoPos([]byte("sicily"), []byte("Palermo"), []byte("none"))
+	assert.InDelta(t, 13.36138933897018433, pos[0].Longitude, 1e-9)
+	assert.InDelta(t, 38.11555639549629859, pos[0].Latitude, 1e-9)
+	assert.Nil(t, pos[1])
+	assert.Equal(t, []*GeoPoint{nil}, db.GeoPos([]byte("none"), []byte("Palermo")))
+}
+
+func TestOpenDB_GeoDist(t *testing.T) {
+	db := openTestDB(t)
+	addSicily(t, db)
+
+	dist, ok, err := db.GeoDist([]byte("sicily"), []byte("Palermo"), []byte("Catania"), "")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.InDelta(t, 166274.1516, dist, 1e-4)
+
+	dist, _, _ = db.GeoDist([]byte("sicily"), []byte("Palermo"), []byte("Catania"), GeoKilometers)
+	assert.InDelta(t, 166.2742, dist, 1e-4)
+	dist, _, _ = db.GeoDist([]byte("sicily"), []byte("Palermo"), []byte("Catania"), GeoMiles)
+	assert.InDelta(t, 103.3182, dist, 1e-4)
+
+	_, ok, err = db.GeoDist([]byte("sicily"), []byte("Palermo"), []byte("none"), GeoMeters)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	_, _, err = db.GeoDist([]byte("sicily"), []byte("Palermo"), []byte("Catania"), "yd")
+	assert.Equal(t, ErrInvalidGeoUnit, err)
+}
+
+func TestOpenDB_GeoHash(t *testing.T) {
+	db := openTestDB(t)
+	addSicily(t, db)
+
+	hashes := db.GeoHash([]byte("sicily"), []byte("Palermo"), []byte("Catania"), []byte("none"))
+	assert.Equal(t, []string{"sqc8b49rny0", "sqdtr74hyu0", ""}, hashes)
+}
+
+func TestOpenDB_GeoSearch(t *testing.T) {
+	db := openTestDB(t)
+	addSicily(t, db)
+	_, err := db.GeoAdd([]byte("sicily"),
+		GeoLocation{Longitude: 12.758489, Latitude: 38.788135, Member: []byte("edge1")},
+		GeoLocation{Longitude: 17.241510, Latitude: 38.788135, Member: []byte("edge2")},
+	)
+	assert.Nil(t, err)
+
+	members := func(res []GeoResult) (val []string) {
+		for _, r := range res {
+			val = append(val, string(r.Member))
+		}
+		return
+	}
+
+	res, err := db.GeoSearch([]byte("sicily"), GeoSearchOptions{Longitude: 15, Latitude: 37, Radius: 200, Unit: GeoKilometers})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Catania", "Palermo"}, members(res))
+	assert.InDelta(t, 56.4413, res[0].Distance, 1e-4)
+	assert.InDelta(t, 190.4424, res[1].Distance, 1e-4)
+	assert.InDelta(t, 15.087269, res[0].Longitude, 1e-5)
+
+	res, err = db.GeoSearch([]byte("sicily"), GeoSearchOptions{Longitude: 15, Latitude: 37, Width: 400, Height: 400, Unit: GeoKilometers})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Catania", "Palermo", "edge2", "edge1"}, members(res))
+	assert.InDelta(t, 279.7403, res[2].Distance, 1e-4)
+
+	res, err = db.GeoSearch([]byte("sicily"), GeoSearchOptions{Longitude: 15, Latitude: 37, Width: 400, Height: 400, Unit: GeoKilometers, Count: 2, Desc: true})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"edge1", "edge2"}, members(res))
+
+	res, err = db.GeoSearch([]byte("sicily"), GeoSearchOptions{FromMember: []byte("Palermo"), Radius: 200, Unit: GeoKilometers})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Palermo", "edge1", "Catania"}, members(res))
+	assert.Equal(t, float64(0), res[0].Distance)
+
+	_, err = db.GeoSearch([]byte("sicily"), GeoSearchOptions{FromMember: []byte("none"), Radius: 200})
+	assert.Equal(t, ErrMemberNotExist, err)
+	_, err = db.GeoSearch([]byte("sicily"), GeoSearchOptions{Longitude: 15, Latitude: 37, Width: 400})
+	assert.Equal(t, ErrInvalidGeoShape, err)
+	_, err = db.GeoSearch([]byte("sicily"), GeoSearchOptions{Longitude: 15, Latitude: 90, Radius: 200})
+	assert.Equal(t, ErrInvalidCoordinates, err)
+	res, err = db.GeoSearch([]byte("none"), GeoSearchOptions{Longitude: 15, Latitude: 37, Radius: 200})
+	assert.Nil(t, err)
+	assert.Empty(t, res)
+}
+
+// TestOpenDB_GeoSearchNeighbors checks that searching the neighbor areas finds the same members as comparing the distances of all of them.
+func TestOpenDB_GeoSearchNeighbors(t *testing.T) {
+	db := openTestDB(t)
+	r := rand.New(rand.NewSource(1))
+	var locations []GeoLocation
+	for i := 0; i < 2000; i++ {
+		locations = append(locations, GeoLocation{
+			Longitude: r.Float64()*360 - 180,
+			Latitude:  r.Float64()*170 - 85,
+			Member:    []byte(strconv.Itoa(i)),
+		})
+	}
+	_, err := db.GeoAdd([]byte("points"), locations...)
+	assert.Nil(t, err)
+
+	for i := 0; i < 50; i++ {
+		lon, lat := r.Float64()*360-180, r.Float64()*170-85
+		opts := GeoSearchOptions{Longitude: lon, Latitude: lat, Radius: r.Float64() * 3000, Unit: GeoKilometers}
+		if i%2 == 1 {
+			opts.Radius, opts.Width, opts.Height = 0, r.Float64()*3000+1, r.Float64()*3000+1
+		}
+
+		var expected []string
+		for _, p := range db.GeoPos([]byte("points"), memberNames(locations)...) {
+			d := zset.GeoDistance(lon, lat, p.Longitude, p.Latitude) / 1000
+			inBox := zset.GeoDistance(lon, p.Latitude, p.Longitude, p.Latitude)/1000 <= opts.Width/2 &&
+				zset.GeoDistance(lon, lat, lon, p.Latitude)/1000 <= opts.Height/2
+			if (opts.Radius > 0 && d <= opts.Radius) || (opts.Radius == 0 && inBox) {
+				expected = append(expected, strconv.FormatFloat(p.Longitude, 'f', -1, 64))
+			}
+		}
+
+		res, err := db.GeoSearch([]byte("points"), opts)
+		assert.Nil(t, err)
+		var found []string
+		for _, p := range res {
+			found = append(found, strconv.FormatFloat(p.Longitude, 'f', -1, 64))
+		}
+		sort.Strings(expected)
+		sort.Strings(found)
+		assert.Equal(t, expected, found)
+	}
+}
+
+func memberNames(locations []GeoLocation) (val [][]byte) {
+	for _, l := range locations {
+		val = append(val, l.Member)
+	}
+	return
+}
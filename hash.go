@@ -2,8 +2,10 @@ package opendb
 
 import (
 	"bytes"
+	"math"
 	"opendb/ds/hash"
 	"opendb/logfile"
+	"strconv"
 	"sync"
 	"time"
 )
@@ -12,6 +14,8 @@ const (
 	HashHDel
 	HashHClear
 	HashHExpire
+	HashHIncrBy
+	HashHIncrByFloat
 )
 // HashIdx hash index.
 type HashIdx struct {
@@ -33,8 +37,7 @@ func (db *OpenDB) HSet(key []byte, field []byte, value []byte) (res int, err err
 	}
 
 	// If the existed value is the same as the set value, nothing will be done.
-	oldVal := db.HGet(key, field)
-	if bytes.Compare(oldVal, value) == 0 {
+	if oldVal, ok := db.hGet(key, field); ok && bytes.Equal(oldVal, value) {
 		return
 	}
 
@@ -70,8 +73,93 @@ func (db *OpenDB) HSetNx(key, field, value []byte) (res int, err error) {
 	return
 }
 
-// HGet returns the value associated with field in the hash stored at key.
-func (db *OpenDB) HGet(key, field []byte) []byte {
+// HIncrBy increments the number stored at field in the hash stored at key by increment.
+// If key does not exist, a new key holding a hash is created. If field does not exist the value is set to 0 before the operation is performed.
+// Returns the value at field after the increment operation.
+func (db *OpenDB) HIncrBy(key, field []byte, increment int64) (int64, error) {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return 0, err
+	}
+
+	db.hashIndex.mu.Lock()
+	defer db.hashIndex.mu.Unlock()
+
+	var val int64
+	if oldVal := db.hashIndex.indexes.HGet(string(key), string(field)); oldVal != nil {
+		var err error
+		if val, err = strconv.ParseInt(string(oldVal), 10, 64); err != nil {
+			return 0, ErrValueNotInteger
+		}
+	}
+	if (increment > 0 && val > math.MaxInt64-increment) || (increment < 0 && val < math.MinInt64-increment) {
+		return 0, ErrIncrOverflow
+	}
+	val += increment
+
+	// the value after the increment is logged, so replay does not depend on the old value.
+	newVal := []byte(strconv.FormatInt(val, 10))
+	entry := logfile.NewEntry(key, newVal, field, Hash, HashHIncrBy)
+	if err := db.store(entry); err != nil {
+		return 0, err
+	}
+	db.hashIndex.indexes.HSet(string(key), string(field), newVal)
+	return val, nil
+}
+
+// HIncrByFloat increment the float number stored at field in the hash stored at key by increment.
+// If the field does not exist, it is set to 0 before performing the operation.
+// Returns the value at field after the increment operation.
+func (db *OpenDB) HIncrByFloat(key, field []byte, increment float64) (float64, error) {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return 0, err
+	}
+
+	db.hashIndex.mu.Lock()
+	defer db.hashIndex.mu.Unlock()
+
+	var val float64
+	if oldVal := db.hashIndex.indexes.HGet(string(key), string(field)); oldVal != nil {
+		var err error
+		if val, err = strconv.ParseFloat(string(oldVal), 64); err != nil || math.IsNaN(val) || math.IsInf(val, 0) {
+			return 0, ErrValueNotFloat
+		}
+	}
+	val += increment
+	if math.IsNaN(val) || math.IsInf(val, 0) {
+		return 0, ErrIncrOverflow
+	}
+
+	newVal := []byte(strconv.FormatFloat(val, 'f', -1, 64))
+	entry := logfile.NewEntry(key, newVal, field, Hash, HashHIncrByFloat)
+	if err := db.store(entry); err != nil {
+		return 0, err
+	}
+	db.hashIndex.indexes.HSet(string(key), string(field), newVal)
+	return val, nil
+}
+
+// HStrLen returns the length of the value associated with field in the hash stored at key.
+// If the key or the field do not exist, 0 is returned.
+func (db *OpenDB) HStrLen(key, field []byte) int {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return 0
+	}
+
+	db.hashIndex.mu.RLock()
+	defer db.hashIndex.mu.RUnlock()
+
+	if db.checkExpired(key, Hash) {
+		return 0
+	}
+
+	return db.hashIndex.indexes.HStrLen(string(key), string(field))
+}
+
+// HRandField returns random fields from the hash stored at key.
+// If count is positive, at most count distinct fields are returned.
+// If count is negative, -count fields are returned, and the same field may be returned multiple times.
+// If withValues is true, every field is followed by its value, same as the WITHVALUES option of redis.
+func (db *OpenDB) HRandField(key []byte, count int, withValues bool) [][]byte {
 	if err := db.checkKeyValue(key, nil); err != nil {
 		return nil
 	}
@@ -83,7 +171,48 @@ func (db *OpenDB) HGet(key, field []byte) []byte {
 		return nil
 	}
 
-	return db.hashIndex.indexes.HGet(string(key), string(field))
+	return db.hashIndex.indexes.HRandField(string(key), count, withValues)
+}
+
+// HScan iterates the fields of the hash stored at key, every field is followed by its value.
+// Start with cursor 0 and call HScan with the returned cursor until it returns 0, the lock is released between the calls.
+// count is the number of fields examined in a call, only the fields matching the glob-style pattern match are returned.
+// A field present for the whole iteration is returned at least once, and it may be returned more than once.
+func (db *OpenDB) HScan(key []byte, cursor int, match string, count int) (int, [][]byte) {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return 0, nil
+	}
+
+	db.hashIndex.mu.RLock()
+	defer db.hashIndex.mu.RUnlock()
+
+	if db.checkExpired(key, Hash) {
+		return 0, nil
+	}
+
+	return db.hashIndex.indexes.HScan(string(key), cursor, match, count)
+}
+
+// HGet returns the value associated with field in the hash stored at key.
+func (db *OpenDB) HGet(key, field []byte) []byte {
+	val, _ := db.hGet(key, field)
+	return val
+}
+
+// hGet returns the value associated with field, ok is false if the key or the field does not exist,
+// so an empty value can be told from a missing field.
+func (db *OpenDB) hGet(key, field []byte) (val []byte, ok bool) {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return
+	}
+
+	db.hashIndex.mu.RLock()
+	defer db.hashIndex.mu.RUnlock()
+
+	if db.checkExpired(key, Hash) || !db.hashIndex.indexes.HExists(string(key), string(field)) {
+		return
+	}
+	return db.hashIndex.indexes.HGet(string(key), string(field)), true
 }
 
 // HGetAll returns all fields and values of the hash stored at key.
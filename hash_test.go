@@ -1,7 +1,12 @@
 package opendb
 
 import (
+	"fmt"
+	"math"
+	"strings"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 
@@ -25,3 +30,120 @@ func TestRoseDB_HSet(t *testing.T) {
 	getVal([]byte(key), []byte("my_name"))
 }
 
+
+func TestOpenDB_HIncrBy(t *testing.T) {
+	path := t.TempDir()
+	db, err := Open(DefaultOptions(path))
+	assert.Nil(t, err)
+	key := []byte("counters")
+
+	val, err := db.HIncrBy(key, []byte("u1"), 5)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(5), val)
+	val, err = db.HIncrBy(key, []byte("u1"), -8)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(-3), val)
+
+	_, err = db.HSet(key, []byte("name"), []byte("lily"))
+	assert.Nil(t, err)
+	_, err = db.HIncrBy(key, []byte("name"), 1)
+	assert.Equal(t, ErrValueNotInteger, err)
+
+	_, err = db.HIncrBy(key, []byte("max"), math.MaxInt64)
+	assert.Nil(t, err)
+	_, err = db.HIncrBy(key, []byte("max"), 1)
+	assert.Equal(t, ErrIncrOverflow, err)
+
+	f, err := db.HIncrByFloat(key, []byte("f"), 10.5)
+	assert.Nil(t, err)
+	assert.Equal(t, 10.5, f)
+	f, err = db.HIncrByFloat(key, []byte("f"), 0.1)
+	assert.Nil(t, err)
+	assert.Equal(t, 10.6, f)
+	f, err = db.HIncrByFloat(key, []byte("u1"), 1.5)
+	assert.Nil(t, err)
+	assert.Equal(t, -1.5, f)
+	_, err = db.HIncrByFloat(key, []byte("name"), 1)
+	assert.Equal(t, ErrValueNotFloat, err)
+	_, err = db.HIncrByFloat(key, []byte("f"), math.Inf(1))
+	assert.Equal(t, ErrIncrOverflow, err)
+
+	// the counters are replayed.
+	db2, err := Open(DefaultOptions(path))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("-1.5"), db2.HGet(key, []byte("u1")))
+	assert.Equal(t, []byte("10.6"), db2.HGet(key, []byte("f")))
+	assert.Equal(t, []byte("lily"), db2.HGet(key, []byte("name")))
+}
+
+func TestOpenDB_HStrLen(t *testing.T) {
+	db := openTestDB(t)
+
+	_, err := db.HSet([]byte("h"), []byte("f"), []byte("hello"))
+	assert.Nil(t, err)
+	assert.Equal(t, 5, db.HStrLen([]byte("h"), []byte("f")))
+	assert.Equal(t, 0, db.HStrLen([]byte("h"), []byte("none")))
+	assert.Equal(t, 0, db.HStrLen([]byte("none"), []byte("f")))
+}
+
+func TestOpenDB_HRandField(t *testing.T) {
+	db := openTestDB(t)
+
+	key := []byte("h")
+	assert.Nil(t, db.HMSet(key, []byte("a"), []byte("1"), []byte("b"), []byte("2"), []byte("c"), []byte("3")))
+
+	fields := db.HRandField(key, 2, false)
+	assert.Equal(t, 2, len(fields))
+	assert.True(t, db.HExists(key, fields[0]))
+	assert.Equal(t, 5, len(db.HRandField(key, -5, false)))
+
+	pairs := db.HRandField(key, 5, true)
+	assert.Equal(t, 6, len(pairs))
+	for i := 0; i < len(pairs); i += 2 {
+		assert.Equal(t, db.HGet(key, pairs[i]), pairs[i+1])
+	}
+	assert.Nil(t, db.HRandField([]byte("none"), 1, false))
+}
+
+func TestOpenDB_HScan(t *testing.T) {
+	db := openTestDB(t)
+
+	key := []byte("h")
+	// more fields than a listpack holds, so they are returned in pages.
+	for i := 0; i < 200; i++ {
+		_, err := db.HSet(key, []byte(fmt.Sprintf("f%d", i)), []byte(fmt.Sprint(i)))
+		assert.Nil(t, err)
+	}
+
+	// delete and add fields between the pages, the fields never deleted must all be returned.
+	seen := make(map[string]bool)
+	cursor, pages := 0, 0
+	for {
+		var res [][]byte
+		cursor, res = db.HScan(key, cursor, "f*", 7)
+		for i := 0; i < len(res); i += 2 {
+			assert.Equal(t, db.HGet(key, res[i]), res[i+1])
+			seen[string(res[i])] = true
+		}
+		pages++
+		_, err := db.HDel(key, []byte(fmt.Sprintf("f%d", pages*3)))
+		assert.Nil(t, err)
+		_, err = db.HSet(key, []byte(fmt.Sprintf("g%d", pages)), []byte("new"))
+		assert.Nil(t, err)
+		if cursor == 0 {
+			break
+		}
+	}
+	for i := 0; i < 200; i++ {
+		if i%3 != 0 || i/3 > pages {
+			assert.True(t, seen[fmt.Sprintf("f%d", i)], "f%d is missed", i)
+		}
+	}
+	for f := range seen {
+		assert.True(t, strings.HasPrefix(f, "f"))
+	}
+
+	cursor, res := db.HScan([]byte("none"), 0, "", 0)
+	assert.Equal(t, 0, cursor)
+	assert.Nil(t, res)
+}
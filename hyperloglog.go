@@ -0,0 +1,113 @@
+package opendb
+
+import (
+	"opendb/ds/hll"
+)
+
+// PFAdd adds all the members to the HyperLogLog stored at key, the HyperLogLog is saved as a string value.
+// If key does not exist, an empty HyperLogLog is created before adding the members.
+// Returns true if the HyperLogLog is created or its estimated cardinality is changed.
+// Only the changed registers of a dense HyperLogLog are written like SetRange, so the whole value is not logged for every add.
+func (db *OpenDB) PFAdd(key interface{}, members ...[]byte) (bool, error) {
+	encKey, err := db.encodeKey(key)
+	if err != nil {
+		return false, err
+	}
+	if err := db.checkKeyValue(encKey, nil); err != nil {
+		return false, err
+	}
+
+	db.strIndex.mu.Lock()
+	defer db.strIndex.mu.Unlock()
+
+	h, val, exist, err := db.getHLL(encKey)
+	if err != nil {
+		return false, err
+	}
+
+	changed := !exist
+	for _, m := range members {
+		if h.Add(m) {
+			changed = true
+		}
+	}
+	if !changed {
+		return false, nil
+	}
+	if offset, patch, ok := h.EncodeChanged(); ok {
+		_, err = db.writeRange(encKey, val, offset, patch)
+		return true, err
+	}
+	return true, db.replaceVal(encKey, h.Encode())
+}
+
+// PFCount returns the estimated cardinality of the HyperLogLog stored at key.
+// When called with multiple keys, returns the estimated cardinality of the union of them.
+// Keys that do not exist are considered as empty HyperLogLogs.
+func (db *OpenDB) PFCount(keys ...interface{}) (uint64, error) {
+	if len(keys) == 0 {
+		return 0, ErrWrongNumberOfArgs
+	}
+	encKeys, err := db.encodeKeys(keys)
+	if err != nil {
+		return 0, err
+	}
+
+	db.strIndex.mu.RLock()
+	defer db.strIndex.mu.RUnlock()
+
+	h, err := db.mergeHLL(encKeys)
+	if err != nil {
+		return 0, err
+	}
+	return h.Count(), nil
+}
+
+// PFMerge merges the HyperLogLogs stored at srcKeys and destKey into destKey.
+// If destKey does not exist, it is created.
+func (db *OpenDB) PFMerge(destKey interface{}, srcKeys ...interface{}) error {
+	encKeys, err := db.encodeKeys(append([]interface{}{destKey}, srcKeys...))
+	if err != nil {
+		return err
+	}
+
+	db.strIndex.mu.Lock()
+	defer db.strIndex.mu.Unlock()
+
+	h, err := db.mergeHLL(encKeys)
+	if err != nil {
+		return err
+	}
+	return db.replaceVal(encKeys[0], h.Encode())
+}
+
+// get the HyperLogLog stored at key and its encoded value, an empty one is returned if the key does not exist.
+func (db *OpenDB) getHLL(key []byte) (h *hll.HLL, val []byte, exist bool, err error) {
+	val, err = db.getVal(key)
+	if err == ErrKeyNotExist || err == ErrKeyExpired {
+		return hll.New(), nil, false, nil
+	}
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	if h, err = hll.Decode(val); err != nil {
+		return nil, nil, false, ErrInvalidHLL
+	}
+	return h, val, true, nil
+}
+
+// merge the HyperLogLogs stored at keys to a new one.
+func (db *OpenDB) mergeHLL(keys [][]byte) (*hll.HLL, error) {
+	res := hll.New()
+	for _, key := range keys {
+		h, _, exist, err := db.getHLL(key)
+		if err != nil {
+			return nil, err
+		}
+		if exist {
+			res.Merge(h)
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,116 @@
+package opendb
+
+import (
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenDB_PFAdd(t *testing.T) {
+	path := t.TempDir()
+	db, err := Open(DefaultOptions(path))
+	assert.Nil(t, err)
+
+	ok, err := db.PFAdd("hll_key")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, err = db.PFAdd("hll_key", []byte("a"), []byte("b"), []byte("c"))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, err = db.PFAdd("hll_key", []byte("a"))
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	assert.Nil(t, db.Set("str_key", "not a hll"))
+	_, err = db.PFAdd("str_key", []byte("a"))
+	assert.Equal(t, ErrInvalidHLL, err)
+
+	db, err = Open(DefaultOptions(path))
+	assert.Nil(t, err)
+	count, err := db.PFCount("hll_key")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(3), count)
+}
+
+func TestOpenDB_PFAddDense(t *testing.T) {
+	for _, mode := range []DataIndexMode{KeyValueMemMode, KeyOnlyMemMode} {
+		path := t.TempDir()
+		opts := DefaultOptions(path)
+		opts.IdxMode = mode
+		db, err := Open(opts)
+		assert.Nil(t, err)
+
+		members := make([][]byte, 0, 10000)
+		for i := 0; i < 10000; i++ {
+			members = append(members, []byte("user_"+strconv.Itoa(i)))
+		}
+		_, err = db.PFAdd("hll_key", members...)
+		assert.Nil(t, err)
+
+		// add the members one by one, only the changed registers are written in KeyValueMemMode.
+		activeFile, err := db.getActiveFile(String)
+		assert.Nil(t, err)
+		offset := activeFile.Offset
+		var changed int
+		for i := 10000; i < 10100; i++ {
+			ok, err := db.PFAdd("hll_key", []byte("user_"+strconv.Itoa(i)))
+			assert.Nil(t, err)
+			if ok {
+				changed++
+			}
+		}
+		assert.True(t, changed > 0)
+		if mode == KeyValueMemMode {
+			assert.True(t, activeFile.Offset-offset < int64(changed*100), "written: %d", activeFile.Offset-offset)
+		}
+
+		count, err := db.PFCount("hll_key")
+		assert.Nil(t, err)
+		db, err = Open(opts)
+		assert.Nil(t, err)
+		reopened, err := db.PFCount("hll_key")
+		assert.Nil(t, err)
+		assert.Equal(t, count, reopened)
+	}
+}
+
+func TestOpenDB_PFCount(t *testing.T) {
+	db := openTestDB(t)
+
+	n := 50000
+	members := make([][]byte, 0, n)
+	for i := 0; i < n; i++ {
+		members = append(members, []byte("user_"+strconv.Itoa(i)))
+	}
+	_, err := db.PFAdd("hll_key1", members[:n/2+1000]...)
+	assert.Nil(t, err)
+	_, err = db.PFAdd("hll_key2", members[n/2:]...)
+	assert.Nil(t, err)
+
+	count, err := db.PFCount("hll_key1", "hll_key2", "not_exist")
+	assert.Nil(t, err)
+	relErr := math.Abs(float64(count)-float64(n)) / float64(n)
+	assert.True(t, relErr < 0.0325, "count: %d, err: %v", count, relErr)
+
+	count, err = db.PFCount("not_exist")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(0), count)
+}
+
+func TestOpenDB_PFMerge(t *testing.T) {
+	db := openTestDB(t)
+
+	_, err := db.PFAdd("hll_key1", []byte("a"), []byte("b"), []byte("c"))
+	assert.Nil(t, err)
+	_, err = db.PFAdd("hll_key2", []byte("c"), []byte("d"))
+	assert.Nil(t, err)
+	_, err = db.PFAdd("hll_dest", []byte("e"))
+	assert.Nil(t, err)
+
+	assert.Nil(t, db.PFMerge("hll_dest", "hll_key1", "hll_key2"))
+	count, err := db.PFCount("hll_dest")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(5), count)
+}
@@ -3,11 +3,11 @@ package opendb
 	对于不同的存储类型，设置其对应的索引，5种
  */
 import (
-	"opendb/ds/list"
+	"math"
+	"opendb/ds/zset"
 	"opendb/logfile"
-	"opendb/util"
 	"strconv"
-	"strings"
+	"time"
 )
 //type DataType = uint16
 type Index struct {
@@ -25,18 +25,38 @@ func (db *OpenDB) buildStringIndex(idx *Index, entry *logfile.Entry) {
 		return
 	}
 
-	switch entry.GetMark() {
+	switch entry.GetType() {
 	case StringSet:
 		db.strIndex.idxList.Put(idx.Meta.Key, idx)
+		delete(db.expires[String], string(idx.Meta.Key))
 	case StringRem:
 		db.strIndex.idxList.Remove(idx.Meta.Key)
+		delete(db.expires[String], string(idx.Meta.Key))
 	case StringExpire:
-		//if entry.Timestamp < uint64(time.Now().Unix()) {
-		//	db.strIndex.idxList.Remove(idx.Meta.Key)
-		//} else {
-		//	db.expires[String][string(idx.Meta.Key)] = int64(entry.Timestamp)
-		//	db.strIndex.idxList.Put(idx.Meta.Key, idx)
-		//}
+		deadline, err := entry.Deadline()
+		if err != nil {
+			return
+		}
+		if deadline < time.Now().Unix() {
+			db.strIndex.idxList.Remove(idx.Meta.Key)
+			delete(db.expires[String], string(idx.Meta.Key))
+		} else {
+			db.expires[String][string(idx.Meta.Key)] = deadline
+			db.strIndex.idxList.Put(idx.Meta.Key, idx)
+		}
+	case StringSetRange:
+		offset, err := strconv.Atoi(string(entry.Extra))
+		if err != nil || offset < 0 || offset > maxStringLength-len(entry.Value) {
+			return
+		}
+		// the entry only holds the overwritten part, apply it to the current value.
+		// It is only written for an existing value, so there is nothing to apply if the value has expired.
+		node := db.strIndex.idxList.Get(idx.Meta.Key)
+		if node == nil {
+			return
+		}
+		idx.Meta.Value = setRange(node.Value().(*Index).Meta.Value, offset, entry.Value)
+		db.strIndex.idxList.Put(idx.Meta.Key, idx)
 	case StringPersist:
 		db.strIndex.idxList.Put(idx.Meta.Key, idx)
 		delete(db.expires[String], string(idx.Meta.Key))
@@ -44,56 +64,11 @@ func (db *OpenDB) buildStringIndex(idx *Index, entry *logfile.Entry) {
 }
 
 // build list indexes.
-func (db *OpenDB) buildListIndex(entry *logfile.Entry) {
+func (db *OpenDB) buildListIndex(entry *logfile.Entry) error {
 	if db.listIndex == nil || entry == nil {
-		return
-	}
-
-	key := string(entry.Key)
-	switch entry.GetMark() {
-	case ListLPush:
-		db.listIndex.indexes.LPush(key, entry.Value)
-	case ListLPop:
-		db.listIndex.indexes.LPop(key)
-	case ListRPush:
-		db.listIndex.indexes.RPush(key, entry.Value)
-	case ListRPop:
-		db.listIndex.indexes.RPop(key)
-	case ListLRem:
-		if count, err := strconv.Atoi(string(entry .Extra)); err == nil {
-			db.listIndex.indexes.LRem(key, entry.Value, count)
-		}
-	case ListLInsert:
-		extra := string(entry.Extra)
-		s := strings.Split(extra, ExtraSeparator)
-		if len(s) == 2 {
-			pivot := []byte(s[0])
-			if opt, err := strconv.Atoi(s[1]); err == nil {
-				db.listIndex.indexes.LInsert(string(entry.Key), list.InsertOption(opt), pivot, entry.Value)
-			}
-		}
-	case ListLSet:
-		if i, err := strconv.Atoi(string(entry.Extra)); err == nil {
-			db.listIndex.indexes.LSet(key, i, entry.Value)
-		}
-	case ListLTrim:
-		extra := string(entry.Extra)
-		s := strings.Split(extra, ExtraSeparator)
-		if len(s) == 2 {
-			start, _ := strconv.Atoi(s[0])
-			end, _ := strconv.Atoi(s[1])
-
-			db.listIndex.indexes.LTrim(string(entry.Key), start, end)
-		}
-	//case ListLExpire:
-	//	if entry.Timestamp < uint64(time.Now().Unix()) {
-	//		db.listIndex.indexes.LClear(key)
-	//	} else {
-	//		db.expires[List][key] = int64(entry.Timestamp)
-	//	}
-	case ListLClear:
-		db.listIndex.indexes.LClear(key)
+		return nil
 	}
+	return replayListEntry(db.listIndex.indexes, entry, db.opts.ValidateListReplay)
 }
 
 // build hash indexes.
@@ -103,8 +78,8 @@ func (db *OpenDB) buildHashIndex(entry *logfile.Entry) {
 	}
 
 	key := string(entry.Key)
-	switch entry.GetMark() {
-	case HashHSet:
+	switch entry.GetType() {
+	case HashHSet, HashHIncrBy, HashHIncrByFloat:
 		db.hashIndex.indexes.HSet(key, string(entry.Extra), entry.Value)
 	case HashHDel:
 		db.hashIndex.indexes.HDel(key, string(entry.Extra))
@@ -120,12 +95,12 @@ func (db *OpenDB) buildHashIndex(entry *logfile.Entry) {
 	}
 }
 // build set indexes.
-func (db *OpenDB) buildSetIndex(entry *logfile.Entry){
-	if db.hashIndex == nil || entry == nil {
-		return
+func (db *OpenDB) buildSetIndex(entry *logfile.Entry) error {
+	if db.setIndex == nil || entry == nil {
+		return nil
 	}
 	key := string(entry.Key)
-	switch entry.GetMark() {
+	switch entry.GetType() {
 	case SetSAdd:
 		db.setIndex.indexes.SAdd(key, entry.Value)
 	case SetSRem:
@@ -135,6 +110,13 @@ func (db *OpenDB) buildSetIndex(entry *logfile.Entry){
 		db.setIndex.indexes.SMove(key, string(extra), entry.Value)
 	case SetSClear:
 		db.setIndex.indexes.SClear(key)
+	case SetSStore:
+		members, err := decodeSetMembers(entry.Value)
+		if err != nil {
+			return err
+		}
+		db.setIndex.indexes.SStore(key, members)
+		delete(db.expires[Set], key)
 	//case SetSExpire:
 	//	if entry.Timestamp < uint64(time.Now().Unix()) {
 	//		db.setIndex.indexes.SClear(key)
@@ -142,28 +124,66 @@ func (db *OpenDB) buildSetIndex(entry *logfile.Entry){
 	//		db.expires[Set][key] = int64(entry.Timestamp)
 	//	}
 	//}
-}}
-	// build sorted set indexes.
-func (db *OpenDB) buildZsetIndex(entry *logfile.Entry){
-		if db.hashIndex == nil || entry == nil {
-			return
+	}
+	return nil
+}
+// build sorted set indexes.
+func (db *OpenDB) buildZsetIndex(entry *logfile.Entry) error {
+	if db.zsetIndex == nil || entry == nil {
+		return nil
+	}
+	key := string(entry.Key)
+	switch entry.GetType() {
+	case ZSetZAdd:
+		// NaN is not a valid score, it may only be written by the former versions.
+		if score, err := decodeScore(entry.Extra); err == nil && !math.IsNaN(score) {
+			db.zsetIndex.indexes.ZAdd(key, score, string(entry.Value))
+		}
+	case ZSetZRem:
+		db.zsetIndex.indexes.ZRem(key, string(entry.Value))
+	case ZSetZClear:
+		db.zsetIndex.indexes.ZClear(key)
+		delete(db.expires[ZSet], key)
+	case ZSetZRemRangeByLex:
+		r, ok := zset.ParseLexRange(string(entry.Value), string(entry.Extra))
+		if !ok {
+			return ErrInvalidLexRange
 		}
-		key := string(entry.Key)
-		switch entry.GetType() {
-		case ZSetZAdd:
-			if score, err := util.StrToFloat64(string(entry.Extra)); err == nil {
-				db.zsetIndex.indexes.ZAdd(key, score, string(entry.Value))
-			}
-		case ZSetZRem:
-			db.zsetIndex.indexes.ZRem(key, string(entry.Value))
-		case ZSetZClear:
-			db.zsetIndex.indexes.ZClear(key)
-			//case ZSetZExpire:
-			//	if entry.Timestamp < uint64(time.Now().Unix()) {
-			//		db.zsetIndex.indexes.ZClear(key)
-			//	} else {
-			//		db.expires[ZSet][key] = int64(entry.Timestamp)
-			//	}
-			//}
+		db.zsetIndex.indexes.ZRemRangeByLex(key, r)
+	case ZSetZRemRangeByScore:
+		min, err := decodeScore(entry.Value)
+		if err != nil {
+			return err
 		}
-}
\ No newline at end of file
+		max, err := decodeScore(entry.Extra)
+		if err != nil {
+			return err
+		}
+		db.zsetIndex.indexes.ZRemRangeByScore(key, min, max)
+	case ZSetZRemRangeByRank:
+		start, err := strconv.Atoi(string(entry.Value))
+		if err != nil {
+			return err
+		}
+		stop, err := strconv.Atoi(string(entry.Extra))
+		if err != nil {
+			return err
+		}
+		db.zsetIndex.indexes.ZRemRangeByRank(key, start, stop)
+	case ZSetZStore:
+		pairs, err := decodeZSetMembers(entry.Value)
+		if err != nil {
+			return err
+		}
+		db.zsetIndex.indexes.ZStore(key, pairs)
+		delete(db.expires[ZSet], key)
+		//case ZSetZExpire:
+		//	if entry.Timestamp < uint64(time.Now().Unix()) {
+		//		db.zsetIndex.indexes.ZClear(key)
+		//	} else {
+		//		db.expires[ZSet][key] = int64(entry.Timestamp)
+		//	}
+		//}
+	}
+	return nil
+}
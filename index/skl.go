@@ -31,6 +31,8 @@ type (
 		Node
 		key   []byte
 		value interface{}
+		// prev the previous element of the first-level index, nil if it is the first one.
+		prev *Element
 	}
 
 	// SkipList define the skip list.
@@ -38,6 +40,7 @@ type (
 		Node
 		maxLevel       int
 		Len            int
+		tail           *Element
 		randSource     rand.Source
 		probability    float64
 		probTable      []float64
@@ -78,6 +81,11 @@ func (e *Element) Next() *Element {
 	return e.next[0]
 }
 
+// Prev the previous element of the first-level index, returns nil if it is the first element.
+func (e *Element) Prev() *Element {
+	return e.prev
+}
+
 // Front first element.
 // Get the head element of skl, and get all data by traversing backward.
 //	e := list.Front()
@@ -88,6 +96,16 @@ func (t *SkipList) Front() *Element {
 	return t.next[0]
 }
 
+// Back last element.
+// Get the tail element of skl, and get all data by traversing forward.
+//	e := list.Back()
+//	for p := e; p != nil; p = p.Prev() {
+//		//do something with Element p
+//	}
+func (t *SkipList) Back() *Element {
+	return t.tail
+}
+
 // Put an element into skip list, replace the value if key already exists.
 func (t *SkipList) Put(key []byte, value interface{}) *Element {
 	var element *Element
@@ -111,6 +129,14 @@ func (t *SkipList) Put(key []byte, value interface{}) *Element {
 		prev[i].next[i] = element
 	}
 
+	if next := element.next[0]; next != nil {
+		element.prev = next.prev
+		next.prev = element
+	} else {
+		element.prev = t.tail
+		t.tail = element
+	}
+
 	t.Len++
 	return element
 }
@@ -150,6 +176,12 @@ func (t *SkipList) Remove(key []byte) *Element {
 			prev[k].next[k] = v
 		}
 
+		if next := element.next[0]; next != nil {
+			next.prev = element.prev
+		} else {
+			t.tail = element.prev
+		}
+
 		t.Len--
 		return element
 	}
@@ -207,6 +239,22 @@ func (t *SkipList) FindPrefix(prefix []byte) *Element {
 	return next
 }
 
+// Seek find the first element whose key is greater than or equal to the key, returns nil if not found.
+func (t *SkipList) Seek(key []byte) *Element {
+	var prev = &t.Node
+	var next *Element
+
+	for i := t.maxLevel - 1; i >= 0; i-- {
+		next = prev.next[i]
+
+		for next != nil && bytes.Compare(key, next.key) > 0 {
+			prev = &next.Node
+			next = next.next[i]
+		}
+	}
+	return next
+}
+
 // generate random index level.
 func (t *SkipList) randomLevel() (level int) {
 	r := float64(t.randSource.Int63()) / (1 << 63)
@@ -225,3 +273,68 @@ func probabilityTable(probability float64, maxLevel int) (table []float64) {
 	}
 	return table
 }
+
+// Iterator iterates the skip list in both directions.
+// It is not safe to modify the skip list while iterating.
+type Iterator struct {
+	list *SkipList
+	e    *Element
+}
+
+// NewIterator create a new iterator, it is invalid until it is positioned by one of the Seek methods.
+func (t *SkipList) NewIterator() *Iterator {
+	return &Iterator{list: t}
+}
+
+// Valid returns if the iterator is positioned at an element.
+func (it *Iterator) Valid() bool {
+	return it.e != nil
+}
+
+// Key the key of the current element, the iterator must be valid.
+func (it *Iterator) Key() []byte {
+	return it.e.key
+}
+
+// Value the value of the current element, the iterator must be valid.
+func (it *Iterator) Value() interface{} {
+	return it.e.value
+}
+
+// Next move to the next element, the iterator must be valid.
+func (it *Iterator) Next() {
+	it.e = it.e.Next()
+}
+
+// Prev move to the previous element, the iterator must be valid.
+func (it *Iterator) Prev() {
+	it.e = it.e.prev
+}
+
+// SeekToFirst move to the first element.
+func (it *Iterator) SeekToFirst() {
+	it.e = it.list.Front()
+}
+
+// SeekToLast move to the last element.
+func (it *Iterator) SeekToLast() {
+	it.e = it.list.Back()
+}
+
+// Seek move to the first element whose key is greater than or equal to the key.
+func (it *Iterator) Seek(key []byte) {
+	it.e = it.list.Seek(key)
+}
+
+// SeekForPrev move to the last element whose key is less than or equal to the key.
+func (it *Iterator) SeekForPrev(key []byte) {
+	e := it.list.Seek(key)
+	switch {
+	case e == nil:
+		it.e = it.list.tail
+	case bytes.Equal(e.key, key):
+		it.e = e
+	default:
+		it.e = e.prev
+	}
+}
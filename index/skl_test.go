@@ -0,0 +1,84 @@
+package index
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func initSkipList(n int) *SkipList {
+	skl := NewSkipList()
+	for _, i := range rand.Perm(n) {
+		skl.Put([]byte(fmt.Sprintf("key_%03d", i*2)), i*2)
+	}
+	return skl
+}
+
+func TestSkipList_Prev(t *testing.T) {
+	skl := initSkipList(100)
+	for i := 0; i < 100; i += 3 {
+		skl.Remove([]byte(fmt.Sprintf("key_%03d", i*2)))
+	}
+	skl.Remove([]byte("key_198"))
+
+	var forward, backward [][]byte
+	for e := skl.Front(); e != nil; e = e.Next() {
+		forward = append(forward, e.Key())
+	}
+	for e := skl.Back(); e != nil; e = e.Prev() {
+		backward = append([][]byte{e.Key()}, backward...)
+	}
+	assert.Equal(t, skl.Len, len(forward))
+	assert.Equal(t, forward, backward)
+}
+
+func TestIterator_Seek(t *testing.T) {
+	skl := initSkipList(10)
+	it := skl.NewIterator()
+	assert.False(t, it.Valid())
+
+	it.Seek([]byte("key_004"))
+	assert.Equal(t, "key_004", string(it.Key()))
+	it.Seek([]byte("key_005"))
+	assert.Equal(t, "key_006", string(it.Key()))
+	assert.Equal(t, 6, it.Value())
+	it.Seek([]byte("key_019"))
+	assert.False(t, it.Valid())
+
+	it.SeekForPrev([]byte("key_004"))
+	assert.Equal(t, "key_004", string(it.Key()))
+	it.SeekForPrev([]byte("key_005"))
+	assert.Equal(t, "key_004", string(it.Key()))
+	it.SeekForPrev([]byte("key_999"))
+	assert.Equal(t, "key_018", string(it.Key()))
+	it.SeekForPrev([]byte("a"))
+	assert.False(t, it.Valid())
+}
+
+func TestIterator_NextPrev(t *testing.T) {
+	skl := initSkipList(10)
+	it := skl.NewIterator()
+
+	var keys []string
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		keys = append(keys, string(it.Key()))
+	}
+	assert.Equal(t, 10, len(keys))
+
+	var reversed []string
+	for it.SeekToLast(); it.Valid(); it.Prev() {
+		reversed = append(reversed, string(it.Key()))
+	}
+	for i := range keys {
+		assert.Equal(t, keys[i], reversed[len(keys)-1-i])
+	}
+
+	// last 3 keys before key_011.
+	var last []string
+	for it.SeekForPrev([]byte("key_011")); it.Valid() && len(last) < 3; it.Prev() {
+		last = append(last, string(it.Key()))
+	}
+	assert.Equal(t, []string{"key_010", "key_008", "key_006"}, last)
+}
@@ -0,0 +1,139 @@
+package opendb
+
+import (
+	"bytes"
+	"opendb/index"
+)
+
+// IteratorOptions options of the String iterator.
+type IteratorOptions struct {
+	// LowerBound the smallest key to iterate(inclusive), nil means no lower bound.
+	LowerBound []byte
+	// UpperBound the key where iteration stops(exclusive), nil means no upper bound.
+	UpperBound []byte
+	// Reverse iterate from the largest key to the smallest one.
+	Reverse bool
+}
+
+// StrIterator iterates the keys and values of String in order of keys.
+// The lock of String is only held while moving the iterator, so writes are not blocked during iteration.
+// Every move seeks from the current key again, so the iterator is still correct if keys are inserted or removed.
+//	it := db.NewStrIterator(IteratorOptions{})
+//	for it.Rewind(); it.Valid(); it.Next() {
+//		//do something with it.Key() and it.Value()
+//	}
+//	if err := it.Err(); err != nil {
+//		//handle the error
+//	}
+type StrIterator struct {
+	db    *OpenDB
+	opts  IteratorOptions
+	key   []byte
+	value []byte
+	valid bool
+	err   error
+}
+
+// NewStrIterator create a new String iterator, it is invalid until it is positioned by Rewind or Seek.
+func (db *OpenDB) NewStrIterator(opts IteratorOptions) *StrIterator {
+	return &StrIterator{db: db, opts: opts}
+}
+
+// Rewind move to the first key in the iteration order, it is the largest key if Reverse.
+func (it *StrIterator) Rewind() {
+	it.find(nil, false)
+}
+
+// Seek move to the first key greater than or equal to the key,
+// or the last key less than or equal to the key if Reverse.
+func (it *StrIterator) Seek(key []byte) {
+	it.find(key, false)
+}
+
+// Next move to the next key in the iteration order, the iterator must be valid.
+func (it *StrIterator) Next() {
+	it.find(it.key, true)
+}
+
+// Valid returns if the iterator is positioned at a key.
+func (it *StrIterator) Valid() bool {
+	return it.valid
+}
+
+// Key the current key, the iterator must be valid.
+func (it *StrIterator) Key() []byte {
+	return it.key
+}
+
+// Value the value of the current key, the iterator must be valid.
+func (it *StrIterator) Value() []byte {
+	return it.value
+}
+
+// Err returns the error occurred while reading values, the iterator is invalid if there is an error.
+func (it *StrIterator) Err() error {
+	return it.err
+}
+
+// find positions the iterator at the first valid key from the key in the iteration order.
+// A nil key means from the beginning, if exclusive is true, the key itself is skipped.
+func (it *StrIterator) find(key []byte, exclusive bool) {
+	it.valid, it.key, it.value = false, nil, nil
+	if it.err != nil {
+		return
+	}
+	lower, upper := it.opts.LowerBound, it.opts.UpperBound
+
+	it.db.strIndex.mu.RLock()
+	defer it.db.strIndex.mu.RUnlock()
+
+	iter := it.db.strIndex.idxList.NewIterator()
+	if !it.opts.Reverse {
+		if key == nil || (lower != nil && bytes.Compare(key, lower) < 0) {
+			key, exclusive = lower, false
+		}
+		if key == nil {
+			iter.SeekToFirst()
+		} else if iter.Seek(key); exclusive && iter.Valid() && bytes.Equal(iter.Key(), key) {
+			iter.Next()
+		}
+	} else {
+		// the upper bound is exclusive.
+		if key == nil || (upper != nil && bytes.Compare(key, upper) >= 0) {
+			key, exclusive = upper, true
+		}
+		if key == nil {
+			iter.SeekToLast()
+		} else if iter.SeekForPrev(key); exclusive && iter.Valid() && bytes.Equal(iter.Key(), key) {
+			iter.Prev()
+		}
+	}
+
+	for ; iter.Valid(); it.step(iter) {
+		if !it.opts.Reverse && upper != nil && bytes.Compare(iter.Key(), upper) >= 0 {
+			return
+		}
+		if it.opts.Reverse && lower != nil && bytes.Compare(iter.Key(), lower) < 0 {
+			return
+		}
+
+		val, err := it.db.getVal(iter.Key())
+		if err == ErrKeyNotExist || err == ErrKeyExpired {
+			continue
+		}
+		if err != nil {
+			it.err = err
+			return
+		}
+		it.valid, it.key, it.value = true, iter.Key(), val
+		return
+	}
+}
+
+func (it *StrIterator) step(iter *index.Iterator) {
+	if it.opts.Reverse {
+		iter.Prev()
+	} else {
+		iter.Next()
+	}
+}
@@ -0,0 +1,80 @@
+package opendb
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func collectKeys(it *StrIterator) (keys []string) {
+	for ; it.Valid(); it.Next() {
+		keys = append(keys, string(it.Key()))
+	}
+	return
+}
+
+func TestStrIterator(t *testing.T) {
+	db := openTestDB(t)
+	for i := 0; i < 10; i++ {
+		assert.Nil(t, db.Set(fmt.Sprintf("key_%d", i), fmt.Sprintf("val_%d", i)))
+	}
+
+	it := db.NewStrIterator(IteratorOptions{})
+	it.Rewind()
+	assert.True(t, it.Valid())
+	assert.Equal(t, "key_0", string(it.Key()))
+	assert.Equal(t, "val_0", string(it.Value()))
+	assert.Equal(t, 10, len(collectKeys(it)))
+	assert.Nil(t, it.Err())
+
+	it = db.NewStrIterator(IteratorOptions{Reverse: true})
+	it.Rewind()
+	keys := collectKeys(it)
+	assert.Equal(t, 10, len(keys))
+	assert.Equal(t, "key_9", keys[0])
+
+	tests := []struct {
+		opts IteratorOptions
+		seek []byte
+		want []string
+	}{
+		{IteratorOptions{LowerBound: []byte("key_3"), UpperBound: []byte("key_6")}, nil, []string{"key_3", "key_4", "key_5"}},
+		{IteratorOptions{LowerBound: []byte("key_3"), UpperBound: []byte("key_6"), Reverse: true}, nil, []string{"key_5", "key_4", "key_3"}},
+		{IteratorOptions{UpperBound: []byte("key_2")}, nil, []string{"key_0", "key_1"}},
+		{IteratorOptions{LowerBound: []byte("key_8"), Reverse: true}, nil, []string{"key_9", "key_8"}},
+		{IteratorOptions{}, []byte("key_75"), []string{"key_8", "key_9"}},
+		{IteratorOptions{Reverse: true}, []byte("key_15"), []string{"key_1", "key_0"}},
+		{IteratorOptions{UpperBound: []byte("key_5"), Reverse: true}, []byte("key_7"), []string{"key_4", "key_3", "key_2", "key_1", "key_0"}},
+		{IteratorOptions{LowerBound: []byte("key_5")}, []byte("key_1"), []string{"key_5", "key_6", "key_7", "key_8", "key_9"}},
+	}
+	for _, tt := range tests {
+		it := db.NewStrIterator(tt.opts)
+		if tt.seek == nil {
+			it.Rewind()
+		} else {
+			it.Seek(tt.seek)
+		}
+		assert.Equal(t, tt.want, collectKeys(it))
+	}
+}
+
+func TestStrIterator_Modify(t *testing.T) {
+	db := openTestDB(t)
+	for i := 0; i < 10; i++ {
+		assert.Nil(t, db.Set(fmt.Sprintf("key_%d", i), "v"))
+	}
+
+	it := db.NewStrIterator(IteratorOptions{})
+	it.Rewind()
+	var keys []string
+	for ; it.Valid(); it.Next() {
+		keys = append(keys, string(it.Key()))
+		if string(it.Key()) == "key_2" {
+			assert.Nil(t, db.Remove("key_2"))
+			assert.Nil(t, db.Remove("key_3"))
+			assert.Nil(t, db.Set("key_25", "v"))
+		}
+	}
+	assert.Equal(t, []string{"key_0", "key_1", "key_2", "key_25", "key_4", "key_5", "key_6", "key_7", "key_8", "key_9"}, keys)
+}
@@ -4,15 +4,16 @@ package opendb
 
 import (
 "bytes"
+"context"
 "opendb/ds/list"
 "opendb/logfile"
-"strconv"
-"strings"
 "sync"
 "time"
 )
-// The operations of List.
+// ExtraSeparator the separator of the extra of List entries in the legacy text format.
 var ExtraSeparator = "\\0"
+
+// The operations of List.
 const (
 	ListLPush uint16 = iota
 	ListRPush
@@ -24,11 +25,40 @@ const (
 	ListLTrim
 	ListLClear
 	ListLExpire
+	ListLMove
+)
+// ListDirection the side of a list to pop from or push to.
+type ListDirection uint8
+
+const (
+	// ListLeft the head of a list.
+	ListLeft ListDirection = iota
+	// ListRight the tail of a list.
+	ListRight
 )
+
 // ListIdx the list index.
 type ListIdx struct {
 	mu      *sync.RWMutex
 	indexes *list.List
+	// waiters the clients blocked by BLPop, BRPop and BLMove, in the order they are blocked.
+	waiters []*listWaiter
+}
+
+// listWaiter a client blocked on some lists.
+type listWaiter struct {
+	keys [][]byte
+	from ListDirection
+	// dest is not nil if the popped value is moved to dest.
+	dest []byte
+	to   ListDirection
+	// result receives the popped value once the waiter is served.
+	result chan listPopResult
+}
+
+type listPopResult struct {
+	key, val []byte
+	err      error
 }
 
 func newListIdx() *ListIdx {
@@ -48,13 +78,11 @@ func (db *OpenDB) LPush(key []byte, values ...[]byte) (res int, err error) {
 	defer db.listIndex.mu.Unlock()
 
 	for _, val := range values {
-		e := logfile.NewEntryNoExtra(key, val, List, ListLPush)
-		if err = db.store(e); err != nil {
+		if res, err = db.pushList(key, val, ListLeft); err != nil {
 			return
 		}
-
-		res = db.listIndex.indexes.LPush(string(key), val)
 	}
+	db.wakeListWaiters(key)
 	return
 }
 
@@ -69,13 +97,11 @@ func (db *OpenDB) RPush(key []byte, values ...[]byte) (res int, err error) {
 	defer db.listIndex.mu.Unlock()
 
 	for _, val := range values {
-		e := logfile.NewEntryNoExtra(key, val, List, ListRPush)
-		if err = db.store(e); err != nil {
+		if res, err = db.pushList(key, val, ListRight); err != nil {
 			return
 		}
-
-		res = db.listIndex.indexes.RPush(string(key), val)
 	}
+	db.wakeListWaiters(key)
 	return
 }
 
@@ -92,14 +118,7 @@ func (db *OpenDB) LPop(key []byte) ([]byte, error) {
 		return nil, ErrKeyExpired
 	}
 
-	val := db.listIndex.indexes.LPop(string(key))
-	if val != nil {
-		e := logfile.NewEntryNoExtra(key, val, List, ListLPop)
-		if err := db.store(e); err != nil {
-			return nil, err
-		}
-	}
-	return val, nil
+	return db.popList(key, ListLeft)
 }
 
 // Removes and returns the last elements of the list stored at key.
@@ -115,16 +134,105 @@ func (db *OpenDB) RPop(key []byte) ([]byte, error) {
 		return nil, ErrKeyExpired
 	}
 
-	val := db.listIndex.indexes.RPop(string(key))
-	if val != nil {
-		e := logfile.NewEntryNoExtra(key, val, List, ListRPop)
-		if err := db.store(e); err != nil {
-			return nil, err
-		}
+	return db.popList(key, ListRight)
+}
+
+// BLPop is the blocking version of LPop, it pops from the first non-empty list in keys.
+// If all the lists are empty, it blocks until one of them is pushed by LPush, RPush or LInsert,
+// the clients blocked on the same list are served in the order they are blocked.
+// A timeout of zero blocks indefinitely. When the timeout is reached nil key and value are returned,
+// and when ctx is done its error is returned.
+func (db *OpenDB) BLPop(ctx context.Context, timeout time.Duration, keys ...[]byte) (key, val []byte, err error) {
+	return db.blockingPop(ctx, timeout, keys, ListLeft, nil, ListLeft)
+}
+
+// BRPop is the blocking version of RPop, see BLPop.
+func (db *OpenDB) BRPop(ctx context.Context, timeout time.Duration, keys ...[]byte) (key, val []byte, err error) {
+	return db.blockingPop(ctx, timeout, keys, ListRight, nil, ListLeft)
+}
+
+// BLMove atomically pops an element from the from side of the list stored at src,
+// and pushes it to the to side of the list stored at dst. Returns the moved element.
+// If src is empty, it blocks until src is pushed or the timeout is reached, see BLPop.
+func (db *OpenDB) BLMove(ctx context.Context, timeout time.Duration, src, dst []byte, from, to ListDirection) ([]byte, error) {
+	if err := db.checkKeyValue(dst, nil); err != nil {
+		return nil, err
 	}
+	_, val, err := db.blockingPop(ctx, timeout, [][]byte{src}, from, dst, to)
+	return val, err
+}
+
+// LMove atomically pops an element from the from side of the list stored at src,
+// and pushes it to the to side of the list stored at dst. src and dst can be the same list to rotate it.
+// Returns the moved element, nil is returned if src does not exist.
+func (db *OpenDB) LMove(src, dst []byte, from, to ListDirection) ([]byte, error) {
+	if err := db.checkKeyValue(src, nil); err != nil {
+		return nil, err
+	}
+	if err := db.checkKeyValue(dst, nil); err != nil {
+		return nil, err
+	}
+
+	db.listIndex.mu.Lock()
+	defer db.listIndex.mu.Unlock()
+
+	if db.listLen(src) == 0 {
+		return nil, nil
+	}
+	val, err := db.moveList(src, dst, from, to)
+	if err != nil {
+		return nil, err
+	}
+	db.wakeListWaiters(dst)
 	return val, nil
 }
 
+// RPopLPush atomically pops the last element of the list stored at src, and pushes it to the head of the list stored at dst.
+// Returns the moved element, nil is returned if src does not exist.
+func (db *OpenDB) RPopLPush(src, dst []byte) ([]byte, error) {
+	return db.LMove(src, dst, ListRight, ListLeft)
+}
+
+// LPosOptions options of LPos.
+type LPosOptions struct {
+	// Rank the first match to return, 1 means the first match, 2 the second and so on.
+	// A negative rank searches from the tail, -1 means the last match. Zero is the same as 1.
+	Rank int
+	// MaxLen compare at most MaxLen elements, zero means the whole list.
+	MaxLen int
+}
+
+// LPos returns the index of the first element equal to element in the list stored at key, -1 is returned if there is no match.
+func (db *OpenDB) LPos(key, element []byte, opts LPosOptions) (int, error) {
+	pos, err := db.LPosCount(key, element, 1, opts)
+	if err != nil || len(pos) == 0 {
+		return -1, err
+	}
+	return pos[0], nil
+}
+
+// LPosCount returns the indexes of at most count elements equal to element in the list stored at key, zero count means all the matches.
+// The indexes are in the order they are found, so they are descending if the rank is negative.
+func (db *OpenDB) LPosCount(key, element []byte, count int, opts LPosOptions) ([]int, error) {
+	if err := db.checkKeyValue(key, element); err != nil {
+		return nil, err
+	}
+	if count < 0 || opts.MaxLen < 0 {
+		return nil, ErrWrongNumberOfArgs
+	}
+	if opts.Rank == 0 {
+		opts.Rank = 1
+	}
+
+	db.listIndex.mu.RLock()
+	defer db.listIndex.mu.RUnlock()
+
+	if db.checkExpired(key, List) {
+		return nil, nil
+	}
+	return db.listIndex.indexes.LPos(string(key), element, opts.Rank, count, opts.MaxLen), nil
+}
+
 // LIndex returns the element at index index in the list stored at key.
 // The index is zero-based, so 0 means the first element, 1 the second element and so on.
 // Negative indices can be used to designate elements starting at the tail of the list. Here, -1 means the last element, -2 means the penultimate and so forth.
@@ -155,10 +263,9 @@ func (db *OpenDB) LRem(key, value []byte, count int) (int, error) {
 		return 0, ErrKeyExpired
 	}
 
-	res := db.listIndex.indexes.LRem(string(key), value, count)
+	res, extra := listRem(db.listIndex.indexes, key, value, count)
 	if res > 0 {
-		c := strconv.Itoa(count)
-		e := logfile.NewEntry(key, value, []byte(c), List, ListLRem)
+		e := logfile.NewEntry(key, value, extra, List, ListLRem)
 		if err := db.store(e); err != nil {
 			return res, err
 		}
@@ -172,25 +279,17 @@ func (db *OpenDB) LInsert(key string, option list.InsertOption, pivot, val []byt
 		return
 	}
 
-	if strings.Contains(string(pivot), ExtraSeparator) {
-		return 0, ErrExtraContainsSeparator
-	}
-
 	db.listIndex.mu.Lock()
 	defer db.listIndex.mu.Unlock()
 
-	count = db.listIndex.indexes.LInsert(key, option, pivot, val)
+	var extra []byte
+	count, extra = listInsert(db.listIndex.indexes, []byte(key), option, pivot, val)
 	if count != -1 {
-		var buf bytes.Buffer
-		buf.Write(pivot)
-		buf.Write([]byte(ExtraSeparator))
-		opt := strconv.Itoa(int(option))
-		buf.Write([]byte(opt))
-
-		e := logfile.NewEntry([]byte(key), val, buf.Bytes(), List, ListLInsert)
+		e := logfile.NewEntry([]byte(key), val, extra, List, ListLInsert)
 		if err = db.store(e); err != nil {
 			return
 		}
+		db.wakeListWaiters([]byte(key))
 	}
 	return
 }
@@ -205,9 +304,9 @@ func (db *OpenDB) LSet(key []byte, idx int, val []byte) (ok bool, err error) {
 	db.listIndex.mu.Lock()
 	defer db.listIndex.mu.Unlock()
 
-	if ok = db.listIndex.indexes.LSet(string(key), idx, val); ok {
-		i := strconv.Itoa(idx)
-		e := logfile.NewEntry(key, val, []byte(i), List, ListLSet)
+	var extra []byte
+	if ok, extra = listSet(db.listIndex.indexes, key, idx, val); ok {
+		e := logfile.NewEntry(key, val, extra, List, ListLSet)
 		if err := db.store(e); err != nil {
 			return false, err
 		}
@@ -229,13 +328,8 @@ func (db *OpenDB) LTrim(key []byte, start, end int) error {
 		return ErrKeyExpired
 	}
 
-	if res := db.listIndex.indexes.LTrim(string(key), start, end); res {
-		var buf bytes.Buffer
-		buf.Write([]byte(strconv.Itoa(start)))
-		buf.Write([]byte(ExtraSeparator))
-		buf.Write([]byte(strconv.Itoa(end)))
-
-		e := logfile.NewEntry(key, nil, buf.Bytes(), List, ListLTrim)
+	if res, extra := listTrim(db.listIndex.indexes, key, start, end); res {
+		e := logfile.NewEntry(key, nil, extra, List, ListLTrim)
 		if err := db.store(e); err != nil {
 			return err
 		}
@@ -318,12 +412,10 @@ func (db *OpenDB) LClear(key []byte) (err error) {
 	db.listIndex.mu.Lock()
 	defer db.listIndex.mu.Unlock()
 
-	e := logfile.NewEntryNoExtra(key, nil, List, ListLClear)
+	e := logfile.NewEntry(key, nil, listClear(db.listIndex.indexes, key), List, ListLClear)
 	if err = db.store(e); err != nil {
 		return err
 	}
-
-	db.listIndex.indexes.LClear(string(key))
 	delete(db.expires[List], string(key))
 	return
 }
@@ -350,6 +442,169 @@ func (db *OpenDB) LClear(key []byte) (err error) {
 //	return
 //}
 
+// blockingPop pops from the first non-empty list in keys, or blocks until one of them is pushed.
+// If dest is not nil, the popped value is pushed to dest.
+func (db *OpenDB) blockingPop(ctx context.Context, timeout time.Duration, keys [][]byte, from ListDirection, dest []byte, to ListDirection) ([]byte, []byte, error) {
+	if len(keys) == 0 {
+		return nil, nil, ErrWrongNumberOfArgs
+	}
+	if timeout < 0 {
+		return nil, nil, ErrInvalidTTL
+	}
+	for _, key := range keys {
+		if err := db.checkKeyValue(key, nil); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	w := &listWaiter{keys: keys, from: from, dest: dest, to: to, result: make(chan listPopResult, 1)}
+	db.listIndex.mu.Lock()
+	for _, key := range keys {
+		// there is no waiter on a non-empty list, so it is fair to pop directly.
+		if db.listLen(key) > 0 {
+			res := db.serveListWaiter(w, key)
+			if res.err == nil && dest != nil {
+				db.wakeListWaiters(dest)
+			}
+			db.listIndex.mu.Unlock()
+			return res.key, res.val, res.err
+		}
+	}
+	db.listIndex.waiters = append(db.listIndex.waiters, w)
+	db.listIndex.mu.Unlock()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	var err error
+	select {
+	case res := <-w.result:
+		return res.key, res.val, res.err
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-expired:
+	}
+
+	db.listIndex.mu.Lock()
+	removed := db.removeListWaiter(w)
+	db.listIndex.mu.Unlock()
+	// the waiter is served before it is removed, the pop is done and must be returned.
+	if !removed {
+		res := <-w.result
+		return res.key, res.val, res.err
+	}
+	return nil, nil, err
+}
+
+// wakeListWaiters serve the waiters blocked on the key while the list is not empty, the lock of List must be held.
+// Values moved by BLMove may wake the waiters blocked on the destination too.
+func (db *OpenDB) wakeListWaiters(key []byte) {
+	keys := [][]byte{key}
+	for len(keys) > 0 {
+		key, keys = keys[0], keys[1:]
+		for i := 0; i < len(db.listIndex.waiters) && db.listLen(key) > 0; {
+			w := db.listIndex.waiters[i]
+			if !w.waitsOn(key) {
+				i++
+				continue
+			}
+			db.listIndex.waiters = append(db.listIndex.waiters[:i], db.listIndex.waiters[i+1:]...)
+			if res := db.serveListWaiter(w, key); res.err == nil && w.dest != nil {
+				keys = append(keys, w.dest)
+			}
+		}
+	}
+}
+
+// serveListWaiter pops from the key for the waiter and sends the result to it, the lock of List must be held.
+// The pop is logged here, so it is persisted exactly once even if the waiter gives up at the same time.
+func (db *OpenDB) serveListWaiter(w *listWaiter, key []byte) listPopResult {
+	res := listPopResult{key: key}
+	if w.dest != nil {
+		res.val, res.err = db.moveList(key, w.dest, w.from, w.to)
+	} else {
+		res.val, res.err = db.popList(key, w.from)
+	}
+	w.result <- res
+	return res
+}
+
+// removeListWaiter returns false if the waiter is not blocked anymore, the lock of List must be held.
+func (db *OpenDB) removeListWaiter(w *listWaiter) bool {
+	for i, waiter := range db.listIndex.waiters {
+		if waiter == w {
+			db.listIndex.waiters = append(db.listIndex.waiters[:i], db.listIndex.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (w *listWaiter) waitsOn(key []byte) bool {
+	for _, k := range w.keys {
+		if bytes.Equal(k, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// pushList push the value to the side of the list and log it, the lock of List must be held.
+func (db *OpenDB) pushList(key, val []byte, where ListDirection) (int, error) {
+	typ := ListLPush
+	if where == ListRight {
+		typ = ListRPush
+	}
+	n, extra := listPush(db.listIndex.indexes, key, val, where)
+	e := logfile.NewEntry(key, val, extra, List, typ)
+	if err := db.store(e); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// moveList pop a value from src and push it to dst, it is logged as one entry,
+// so the move is never partially replayed. The lock of List must be held.
+func (db *OpenDB) moveList(src, dst []byte, from, to ListDirection) ([]byte, error) {
+	val, ok, extra := listMove(db.listIndex.indexes, src, dst, from, to)
+	if !ok {
+		return nil, nil
+	}
+	e := logfile.NewEntry(src, val, extra, List, ListLMove)
+	if err := db.store(e); err != nil {
+		return nil, err
+	}
+	return val, nil
+}
+
+// popList pop a value from the side of the list and log it, the lock of List must be held.
+func (db *OpenDB) popList(key []byte, where ListDirection) ([]byte, error) {
+	typ := ListLPop
+	if where == ListRight {
+		typ = ListRPop
+	}
+	val, ok, extra := listPop(db.listIndex.indexes, key, where)
+	if ok {
+		e := logfile.NewEntry(key, val, extra, List, typ)
+		if err := db.store(e); err != nil {
+			return nil, err
+		}
+	}
+	return val, nil
+}
+
+// the length of the list, an expired list is empty.
+func (db *OpenDB) listLen(key []byte) int {
+	if db.checkExpired(key, List) {
+		return 0
+	}
+	return db.listIndex.indexes.LLen(string(key))
+}
+
 // LTTL return time to live.
 func (db *OpenDB) LTTL(key []byte) (ttl int64) {
 	db.listIndex.mu.RLock()
@@ -0,0 +1,555 @@
+package opendb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"opendb/ds/list"
+	"opendb/logfile"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The extra of a List entry is in binary, the arguments of the operation come first,
+// the integers are varints and the byte strings are prefixed by their lengths.
+// Indexes are absolute positions resolved when the operation is done, so replay never searches the list or counts from the tail.
+// Every extra ends with a trailer, which is the length of the list after the operation and the checksum of all its elements,
+// the checksum is maintained by the list as it is modified, so it is cheap to write.
+//	LPush, RPush, LPop, RPop, LClear: trailer
+//	LRem:    count(varint) trailer
+//	LInsert: index(uvarint) pivot(bytes) option(byte) trailer
+//	LSet:    index(uvarint) trailer
+//	LTrim:   start(uvarint) kept count(uvarint) trailer
+//	LMove:   from(byte) to(byte) dst(bytes) trailer, the trailer is of src.
+
+// listFormat the format of the extra of List entries, it is recorded in the db,
+// the log files written in the legacy text format are migrated when the db is opened.
+const (
+	listFormat         = "binary"
+	listFormatFileName = "opendb.list"
+	listMigrateDir     = "opendb_list_migrate"
+	listMigrateDone    = "done"
+)
+
+// errInvalidListExtra the extra of a List entry can not be decoded.
+var errInvalidListExtra = errors.New("opendb: invalid extra of list entry")
+
+// listExtra build the extra of a List entry.
+type listExtra []byte
+
+func (b listExtra) uvarint(v int) listExtra {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], uint64(v))]...)
+}
+
+func (b listExtra) varint(v int) listExtra {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutVarint(buf[:], int64(v))]...)
+}
+
+func (b listExtra) bytes(p []byte) listExtra {
+	return append(b.uvarint(len(p)), p...)
+}
+
+// trailer append the length and the checksum of the list after the operation.
+func (b listExtra) trailer(lis *list.List, key []byte) []byte {
+	length, sum := listChecksum(lis, key)
+	b = b.uvarint(length)
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], sum)
+	return append(b, buf[:]...)
+}
+
+// listExtraReader read the extra of a List entry, err is set if the extra is truncated.
+type listExtraReader struct {
+	buf []byte
+	err error
+}
+
+func (r *listExtraReader) uvarint() int {
+	v, n := binary.Uvarint(r.buf)
+	if n <= 0 {
+		r.fail()
+		return 0
+	}
+	r.buf = r.buf[n:]
+	return int(v)
+}
+
+func (r *listExtraReader) varint() int {
+	v, n := binary.Varint(r.buf)
+	if n <= 0 {
+		r.fail()
+		return 0
+	}
+	r.buf = r.buf[n:]
+	return int(v)
+}
+
+func (r *listExtraReader) byte() byte {
+	if len(r.buf) < 1 {
+		r.fail()
+		return 0
+	}
+	v := r.buf[0]
+	r.buf = r.buf[1:]
+	return v
+}
+
+func (r *listExtraReader) bytes() []byte {
+	n := r.uvarint()
+	if r.err != nil || n > len(r.buf) {
+		r.fail()
+		return nil
+	}
+	v := r.buf[:n]
+	r.buf = r.buf[n:]
+	return v
+}
+
+func (r *listExtraReader) checksum() uint32 {
+	if len(r.buf) < 4 {
+		r.fail()
+		return 0
+	}
+	v := binary.BigEndian.Uint32(r.buf)
+	r.buf = r.buf[4:]
+	return v
+}
+
+func (r *listExtraReader) fail() {
+	r.buf, r.err = nil, errInvalidListExtra
+}
+
+// listChecksum returns the length and the checksum of the list stored at key, the checksum is folded into 32 bits.
+func listChecksum(lis *list.List, key []byte) (int, uint32) {
+	sum := lis.LChecksum(string(key))
+	return lis.LLen(string(key)), uint32(sum>>32 ^ sum)
+}
+
+// The operations of List, they modify the list and return the extra of the entry.
+
+func listPush(lis *list.List, key, val []byte, where ListDirection) (int, []byte) {
+	var n int
+	if where == ListRight {
+		n = lis.RPush(string(key), val)
+	} else {
+		n = lis.LPush(string(key), val)
+	}
+	return n, listExtra(nil).trailer(lis, key)
+}
+
+// listPop returns false if the list is empty, the popped value may be empty, so it is not used to tell that.
+func listPop(lis *list.List, key []byte, where ListDirection) ([]byte, bool, []byte) {
+	if lis.LLen(string(key)) == 0 {
+		return nil, false, nil
+	}
+	var val []byte
+	if where == ListRight {
+		val = lis.RPop(string(key))
+	} else {
+		val = lis.LPop(string(key))
+	}
+	return val, true, listExtra(nil).trailer(lis, key)
+}
+
+func listRem(lis *list.List, key, val []byte, count int) (int, []byte) {
+	n := lis.LRem(string(key), val, count)
+	return n, listExtra(nil).varint(count).trailer(lis, key)
+}
+
+// listInsert returns -1 if the pivot is not found.
+func listInsert(lis *list.List, key []byte, option list.InsertOption, pivot, val []byte) (int, []byte) {
+	pos := lis.LPos(string(key), pivot, 1, 1, 0)
+	if len(pos) == 0 {
+		return -1, nil
+	}
+	idx := pos[0]
+	if option == list.After {
+		idx++
+	}
+	n := lis.LInsertAt(string(key), idx, val)
+	extra := append(listExtra(nil).uvarint(idx).bytes(pivot), byte(option))
+	return n, extra.trailer(lis, key)
+}
+
+func listSet(lis *list.List, key []byte, idx int, val []byte) (bool, []byte) {
+	if idx < 0 {
+		idx += lis.LLen(string(key))
+	}
+	// the index is resolved once, so it is not counted from the tail again by the list.
+	if idx < 0 || !lis.LSet(string(key), idx, val) {
+		return false, nil
+	}
+	return true, listExtra(nil).uvarint(idx).trailer(lis, key)
+}
+
+func listTrim(lis *list.List, key []byte, start, end int) (bool, []byte) {
+	// resolve the kept range before trimming, same as the list does.
+	length := lis.LLen(string(key))
+	if start < 0 {
+		start += length
+	}
+	if end < 0 {
+		end += length
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end >= length {
+		end = length - 1
+	}
+	kept := end - start + 1
+	if start >= length || kept < 0 {
+		start, kept = 0, 0
+	}
+
+	if !lis.LTrim(string(key), start, start+kept-1) {
+		return false, nil
+	}
+	return true, listExtra(nil).uvarint(start).uvarint(kept).trailer(lis, key)
+}
+
+// listMove returns false if src is empty, the moved value may be empty, so it is not used to tell that.
+func listMove(lis *list.List, src, dst []byte, from, to ListDirection) ([]byte, bool, []byte) {
+	if lis.LLen(string(src)) == 0 {
+		return nil, false, nil
+	}
+	var val []byte
+	if from == ListRight {
+		val = lis.RPop(string(src))
+	} else {
+		val = lis.LPop(string(src))
+	}
+	if to == ListRight {
+		lis.RPush(string(dst), val)
+	} else {
+		lis.LPush(string(dst), val)
+	}
+	return val, true, listExtra{byte(from), byte(to)}.bytes(dst).trailer(lis, src)
+}
+
+func listClear(lis *list.List, key []byte) []byte {
+	lis.LClear(string(key))
+	return listExtra(nil).trailer(lis, key)
+}
+
+// replayListEntry apply the entry to the list, if validate is true, the length and the checksum of the list
+// are checked against the trailer of the entry.
+func replayListEntry(lis *list.List, e *logfile.Entry, validate bool) error {
+	key := string(e.Key)
+	r := &listExtraReader{buf: e.Extra}
+	switch e.GetType() {
+	case ListLPush:
+		lis.LPush(key, e.Value)
+	case ListRPush:
+		lis.RPush(key, e.Value)
+	case ListLPop:
+		lis.LPop(key)
+	case ListRPop:
+		lis.RPop(key)
+	case ListLRem:
+		count := r.varint()
+		if r.err == nil {
+			lis.LRem(key, e.Value, count)
+		}
+	case ListLInsert:
+		idx, pivot, option := r.uvarint(), r.bytes(), list.InsertOption(r.byte())
+		if r.err == nil {
+			lis.LInsertAt(key, idx, e.Value)
+			// the pivot is next to the inserted element.
+			pivotIdx := idx + 1
+			if option == list.After {
+				pivotIdx = idx - 1
+			}
+			if validate && string(lis.LIndex(key, pivotIdx)) != string(pivot) {
+				return ErrListReplayMismatch
+			}
+		}
+	case ListLSet:
+		idx := r.uvarint()
+		if r.err == nil {
+			lis.LSet(key, idx, e.Value)
+		}
+	case ListLTrim:
+		start, kept := r.uvarint(), r.uvarint()
+		if r.err == nil {
+			if kept == 0 {
+				// start is greater than end, the list is emptied.
+				lis.LTrim(key, 1, 0)
+			} else {
+				lis.LTrim(key, start, start+kept-1)
+			}
+		}
+	case ListLMove:
+		from, to, dst := ListDirection(r.byte()), ListDirection(r.byte()), r.bytes()
+		if r.err == nil {
+			listMove(lis, e.Key, dst, from, to)
+		}
+	case ListLClear:
+		lis.LClear(key)
+	}
+	if !validate {
+		return nil
+	}
+
+	length, sum := r.uvarint(), r.checksum()
+	if r.err != nil {
+		return r.err
+	}
+	if l, s := listChecksum(lis, e.Key); l != length || s != sum {
+		return ErrListReplayMismatch
+	}
+	return nil
+}
+
+// legacyListOp returns the operation of the entry written in the legacy text format.
+// The legacy entries without extra were created with the mark and the type swapped, so their operation is in the mark.
+func legacyListOp(e *logfile.Entry) uint16 {
+	if e.GetMark() != List {
+		return e.GetMark()
+	}
+	return e.GetType()
+}
+
+// convertLegacyListEntry apply the entry written in the legacy text format to the list,
+// and returns the entry in the binary format, nil is returned if the entry changes nothing.
+func convertLegacyListEntry(lis *list.List, e *logfile.Entry) *logfile.Entry {
+	key := e.Key
+	op := legacyListOp(e)
+	var extra []byte
+	switch op {
+	case ListLPush, ListRPush:
+		where := ListLeft
+		if op == ListRPush {
+			where = ListRight
+		}
+		_, extra = listPush(lis, key, e.Value, where)
+	case ListLPop, ListRPop:
+		where := ListLeft
+		if op == ListRPop {
+			where = ListRight
+		}
+		var ok bool
+		if _, ok, extra = listPop(lis, key, where); !ok {
+			return nil
+		}
+	case ListLRem:
+		count, err := strconv.Atoi(string(e.Extra))
+		if err != nil {
+			return nil
+		}
+		_, extra = listRem(lis, key, e.Value, count)
+	case ListLInsert:
+		s := strings.Split(string(e.Extra), ExtraSeparator)
+		if len(s) != 2 {
+			return nil
+		}
+		opt, err := strconv.Atoi(s[1])
+		if err != nil {
+			return nil
+		}
+		var n int
+		if n, extra = listInsert(lis, key, list.InsertOption(opt), []byte(s[0]), e.Value); n == -1 {
+			return nil
+		}
+	case ListLSet:
+		idx, err := strconv.Atoi(string(e.Extra))
+		if err != nil {
+			return nil
+		}
+		var ok bool
+		if ok, extra = listSet(lis, key, idx, e.Value); !ok {
+			return nil
+		}
+	case ListLTrim:
+		s := strings.Split(string(e.Extra), ExtraSeparator)
+		if len(s) != 2 {
+			return nil
+		}
+		start, _ := strconv.Atoi(s[0])
+		end, _ := strconv.Atoi(s[1])
+		var ok bool
+		if ok, extra = listTrim(lis, key, start, end); !ok {
+			return nil
+		}
+	case ListLMove:
+		// from, to and dst joined by the separator, dst is the last one.
+		s := strings.SplitN(string(e.Extra), ExtraSeparator, 3)
+		if len(s) != 3 {
+			return nil
+		}
+		from, err1 := strconv.Atoi(s[0])
+		to, err2 := strconv.Atoi(s[1])
+		if err1 != nil || err2 != nil {
+			return nil
+		}
+		var ok bool
+		if _, ok, extra = listMove(lis, key, []byte(s[2]), ListDirection(from), ListDirection(to)); !ok {
+			return nil
+		}
+	case ListLClear:
+		extra = listClear(lis, key)
+	default:
+		return nil
+	}
+	return logfile.NewEntry(key, e.Value, extra, List, op)
+}
+
+// migrateListFiles rewrite the List log files written in the legacy text format to the binary format.
+// The new files are written to a sub directory first, and they replace the old files after all of them are written,
+// so the migration is resumed if the db is crashed while replacing the files.
+func migrateListFiles(path string, blockSize int64) error {
+	formatFile := filepath.Join(path, listFormatFileName)
+	if format, err := ioutil.ReadFile(formatFile); err == nil && string(format) == listFormat {
+		return nil
+	}
+	migratePath := filepath.Join(path, listMigrateDir)
+	doneFile := filepath.Join(migratePath, listMigrateDone)
+	if _, err := os.Stat(doneFile); err != nil {
+		if err := os.RemoveAll(migratePath); err != nil {
+			return err
+		}
+		fileIds, err := listFileIds(path)
+		if err != nil {
+			return err
+		}
+		if len(fileIds) > 0 {
+			if err := convertListFiles(path, migratePath, fileIds, blockSize); err != nil {
+				return err
+			}
+		}
+	}
+
+	// replace the old files by the new ones, the done file records the number of the new files.
+	if done, err := ioutil.ReadFile(doneFile); err == nil {
+		count, err := strconv.Atoi(string(done))
+		if err != nil {
+			return err
+		}
+		newIds, err := listFileIds(migratePath)
+		if err != nil {
+			return err
+		}
+		for _, id := range newIds {
+			if err := os.Rename(listFileName(migratePath, id), listFileName(path, id)); err != nil {
+				return err
+			}
+		}
+		oldIds, err := listFileIds(path)
+		if err != nil {
+			return err
+		}
+		for _, id := range oldIds {
+			if int(id) >= count {
+				if err := os.Remove(listFileName(path, id)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if err := ioutil.WriteFile(formatFile, []byte(listFormat), 0644); err != nil {
+		return err
+	}
+	return os.RemoveAll(migratePath)
+}
+
+// convert the List log files in path, and write the new files to migratePath.
+func convertListFiles(path, migratePath string, fileIds []uint32, blockSize int64) error {
+	if err := os.MkdirAll(migratePath, os.ModePerm); err != nil {
+		return err
+	}
+	lis := list.New()
+	var newFile *logfile.DBFile
+	var newId uint32
+	defer func() {
+		if newFile != nil {
+			newFile.File.Close()
+		}
+	}()
+
+	for _, id := range fileIds {
+		oldFile, err := logfile.NewDBFile(path, id, List)
+		if err != nil {
+			return err
+		}
+		for offset := int64(0); ; {
+			e, err := oldFile.Read(offset)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				oldFile.File.Close()
+				return err
+			}
+			offset += e.GetSize()
+
+			if len(e.Key) == 0 {
+				continue
+			}
+			ne := convertLegacyListEntry(lis, e)
+			if ne == nil {
+				continue
+			}
+			if newFile == nil || newFile.Offset+ne.GetSize() > blockSize {
+				if newFile != nil {
+					if err := newFile.File.Sync(); err != nil {
+						oldFile.File.Close()
+						return err
+					}
+					newFile.File.Close()
+					newId++
+				}
+				if newFile, err = logfile.NewDBFile(migratePath, newId, List); err != nil {
+					oldFile.File.Close()
+					return err
+				}
+			}
+			if err := newFile.Write(ne); err != nil {
+				oldFile.File.Close()
+				return err
+			}
+		}
+		oldFile.File.Close()
+	}
+	count := 0
+	if newFile != nil {
+		if err := newFile.File.Sync(); err != nil {
+			return err
+		}
+		count = int(newId) + 1
+	}
+	return ioutil.WriteFile(filepath.Join(migratePath, listMigrateDone), []byte(strconv.Itoa(count)), 0644)
+}
+
+// the sorted ids of List log files in the directory.
+func listFileIds(path string) ([]uint32, error) {
+	dir, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int
+	suffix := ".data." + logfile.DBFileSuffixName[List]
+	for _, d := range dir {
+		if !d.IsDir() && strings.HasSuffix(d.Name(), suffix) {
+			if id, err := strconv.Atoi(strings.TrimSuffix(d.Name(), suffix)); err == nil {
+				ids = append(ids, id)
+			}
+		}
+	}
+	sort.Ints(ids)
+	fileIds := make([]uint32, 0, len(ids))
+	for _, id := range ids {
+		fileIds = append(fileIds, uint32(id))
+	}
+	return fileIds, nil
+}
+
+func listFileName(path string, id uint32) string {
+	return filepath.Join(path, fmt.Sprintf(logfile.DBFileFormatNames[List], id))
+}
@@ -1,7 +1,16 @@
 package opendb
 
 import (
+"context"
+"opendb/ds/list"
+"opendb/logfile"
+"opendb/util"
+"os"
+"path/filepath"
 "testing"
+"time"
+
+"github.com/stretchr/testify/assert"
 )
 
 var key = "myhash"
@@ -24,3 +33,496 @@ func TestRoseDB_LPush(t *testing.T) {
 
 	getVal([]byte(key), []byte("my_name"))
 }
+
+// wait until n clients are blocked on lists.
+func waitListWaiters(t *testing.T, db *OpenDB, n int) {
+	for i := 0; i < 1000; i++ {
+		db.listIndex.mu.RLock()
+		cnt := len(db.listIndex.waiters)
+		db.listIndex.mu.RUnlock()
+		if cnt == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("%d clients are not blocked", n)
+}
+
+func TestOpenDB_BLPop(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	_, err := db.RPush([]byte("l2"), []byte("a"), []byte("b"))
+	assert.Nil(t, err)
+	// the first non-empty list is popped without blocking.
+	key, val, err := db.BLPop(ctx, time.Second, []byte("l1"), []byte("l2"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("l2"), key)
+	assert.Equal(t, []byte("a"), val)
+	key, val, err = db.BRPop(ctx, time.Second, []byte("l1"), []byte("l2"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("l2"), key)
+	assert.Equal(t, []byte("b"), val)
+
+	done := make(chan listPopResult)
+	go func() {
+		key, val, err := db.BLPop(ctx, 0, []byte("l1"), []byte("l2"))
+		done <- listPopResult{key, val, err}
+	}()
+	waitListWaiters(t, db, 1)
+	_, err = db.LPush([]byte("l1"), []byte("c"))
+	assert.Nil(t, err)
+	res := <-done
+	assert.Nil(t, res.err)
+	assert.Equal(t, []byte("l1"), res.key)
+	assert.Equal(t, []byte("c"), res.val)
+	assert.Equal(t, 0, db.LLen([]byte("l1")))
+}
+
+func TestOpenDB_BLPop_Timeout(t *testing.T) {
+	db := openTestDB(t)
+
+	key, val, err := db.BLPop(context.Background(), 10*time.Millisecond, []byte("l1"))
+	assert.Nil(t, err)
+	assert.Nil(t, key)
+	assert.Nil(t, val)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		waitListWaiters(t, db, 1)
+		cancel()
+	}()
+	_, _, err = db.BRPop(ctx, 0, []byte("l1"))
+	assert.Equal(t, context.Canceled, err)
+	waitListWaiters(t, db, 0)
+
+	_, _, err = db.BLPop(context.Background(), -1, []byte("l1"))
+	assert.Equal(t, ErrInvalidTTL, err)
+	_, _, err = db.BLPop(context.Background(), 0)
+	assert.Equal(t, ErrWrongNumberOfArgs, err)
+}
+
+func TestOpenDB_BLPop_Fairness(t *testing.T) {
+	db := openTestDB(t)
+
+	results := make([]chan []byte, 3)
+	for i := range results {
+		results[i] = make(chan []byte, 1)
+		go func(ch chan []byte) {
+			_, val, err := db.BLPop(context.Background(), 0, []byte("queue"))
+			assert.Nil(t, err)
+			ch <- val
+		}(results[i])
+		waitListWaiters(t, db, i+1)
+	}
+
+	_, err := db.RPush([]byte("queue"), []byte("1"), []byte("2"), []byte("3"), []byte("4"))
+	assert.Nil(t, err)
+	// the clients are served in the order they are blocked.
+	assert.Equal(t, []byte("1"), <-results[0])
+	assert.Equal(t, []byte("2"), <-results[1])
+	assert.Equal(t, []byte("3"), <-results[2])
+	assert.Equal(t, 1, db.LLen([]byte("queue")))
+}
+
+func TestOpenDB_BLMove(t *testing.T) {
+	path := t.TempDir()
+	db, err := Open(DefaultOptions(path))
+	assert.Nil(t, err)
+	ctx := context.Background()
+
+	moved := make(chan []byte, 1)
+	go func() {
+		val, err := db.BLMove(ctx, 0, []byte("src"), []byte("dst"), ListRight, ListLeft)
+		assert.Nil(t, err)
+		moved <- val
+	}()
+	waitListWaiters(t, db, 1)
+	// the client blocked on dst is woken by the move.
+	popped := make(chan []byte, 1)
+	go func() {
+		_, val, err := db.BRPop(ctx, 0, []byte("dst"))
+		assert.Nil(t, err)
+		popped <- val
+	}()
+	waitListWaiters(t, db, 2)
+
+	_, err = db.RPush([]byte("src"), []byte("a"), []byte("b"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("b"), <-moved)
+	assert.Equal(t, []byte("b"), <-popped)
+
+	val, err := db.BLMove(ctx, time.Second, []byte("src"), []byte("dst"), ListLeft, ListRight)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("a"), val)
+
+	// every pop is persisted exactly once.
+	db2, err := Open(DefaultOptions(path))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, db2.LLen([]byte("src")))
+	vals, err := db2.LRange([]byte("dst"), 0, -1)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]byte{[]byte("a")}, vals)
+}
+
+func TestOpenDB_LMove(t *testing.T) {
+	path := t.TempDir()
+	db, err := Open(DefaultOptions(path))
+	assert.Nil(t, err)
+
+	_, err = db.RPush([]byte("pending"), []byte("a"), []byte("b"), []byte("c"))
+	assert.Nil(t, err)
+
+	val, err := db.LMove([]byte("pending"), []byte("processing"), ListLeft, ListRight)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("a"), val)
+	val, err = db.RPopLPush([]byte("pending"), []byte("processing"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("c"), val)
+	// rotate the list.
+	_, err = db.RPush([]byte("processing"), []byte("d"))
+	assert.Nil(t, err)
+	val, err = db.LMove([]byte("processing"), []byte("processing"), ListRight, ListLeft)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("d"), val)
+
+	val, err = db.LMove([]byte("missing"), []byte("processing"), ListLeft, ListLeft)
+	assert.Nil(t, err)
+	assert.Nil(t, val)
+
+	expect := func(db *OpenDB) {
+		vals, err := db.LRange([]byte("pending"), 0, -1)
+		assert.Nil(t, err)
+		assert.Equal(t, [][]byte{[]byte("b")}, vals)
+		vals, err = db.LRange([]byte("processing"), 0, -1)
+		assert.Nil(t, err)
+		assert.Equal(t, [][]byte{[]byte("d"), []byte("c"), []byte("a")}, vals)
+	}
+	expect(db)
+
+	// the moves are replayed exactly.
+	db2, err := Open(DefaultOptions(path))
+	assert.Nil(t, err)
+	expect(db2)
+}
+
+func TestOpenDB_LMoveEmptyValue(t *testing.T) {
+	path := t.TempDir()
+	db, err := Open(DefaultOptions(path))
+	assert.Nil(t, err)
+
+	_, err = db.RPush([]byte("src"), []byte(""), []byte(""), []byte("a"))
+	assert.Nil(t, err)
+	_, err = db.LMove([]byte("src"), []byte("dst"), ListLeft, ListRight)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, db.LLen([]byte("dst")))
+
+	// the empty values are moved and popped on replay, and after it.
+	db, err = Open(DefaultOptions(path))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, db.LLen([]byte("src")))
+	assert.Equal(t, 1, db.LLen([]byte("dst")))
+	_, err = db.LMove([]byte("src"), []byte("dst"), ListLeft, ListRight)
+	assert.Nil(t, err)
+	_, err = db.LPop([]byte("dst"))
+	assert.Nil(t, err)
+
+	db, err = Open(DefaultOptions(path))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, db.LLen([]byte("src")))
+	assert.Equal(t, 1, db.LLen([]byte("dst")))
+}
+
+func TestOpenDB_LMove_WakeWaiters(t *testing.T) {
+	db := openTestDB(t)
+
+	done := make(chan []byte, 1)
+	go func() {
+		_, val, err := db.BLPop(context.Background(), 0, []byte("dst"))
+		assert.Nil(t, err)
+		done <- val
+	}()
+	waitListWaiters(t, db, 1)
+
+	_, err := db.RPush([]byte("src"), []byte("a"))
+	assert.Nil(t, err)
+	val, err := db.LMove([]byte("src"), []byte("dst"), ListLeft, ListLeft)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("a"), val)
+	assert.Equal(t, []byte("a"), <-done)
+	assert.Equal(t, 0, db.LLen([]byte("dst")))
+}
+
+func TestOpenDB_LPos(t *testing.T) {
+	db := openTestDB(t)
+
+	key := []byte("lpos")
+	_, err := db.RPush(key, []byte("a"), []byte("b"), []byte("c"), []byte("1"), []byte("2"), []byte("3"), []byte("c"), []byte("c"))
+	assert.Nil(t, err)
+
+	pos, err := db.LPos(key, []byte("c"), LPosOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, pos)
+	pos, err = db.LPos(key, []byte("c"), LPosOptions{Rank: 2})
+	assert.Nil(t, err)
+	assert.Equal(t, 6, pos)
+	pos, err = db.LPos(key, []byte("c"), LPosOptions{Rank: -1})
+	assert.Nil(t, err)
+	assert.Equal(t, 7, pos)
+	pos, err = db.LPos(key, []byte("x"), LPosOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, -1, pos)
+	pos, err = db.LPos(key, []byte("c"), LPosOptions{MaxLen: 2})
+	assert.Nil(t, err)
+	assert.Equal(t, -1, pos)
+
+	all, err := db.LPosCount(key, []byte("c"), 0, LPosOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, []int{2, 6, 7}, all)
+	all, err = db.LPosCount(key, []byte("c"), 2, LPosOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, []int{2, 6}, all)
+	all, err = db.LPosCount(key, []byte("c"), 0, LPosOptions{Rank: -2})
+	assert.Nil(t, err)
+	assert.Equal(t, []int{6, 2}, all)
+	all, err = db.LPosCount(key, []byte("c"), 0, LPosOptions{Rank: -1, MaxLen: 2})
+	assert.Nil(t, err)
+	assert.Equal(t, []int{7, 6}, all)
+
+	_, err = db.LPosCount(key, []byte("c"), -1, LPosOptions{})
+	assert.Equal(t, ErrWrongNumberOfArgs, err)
+}
+
+func TestOpenDB_LSet(t *testing.T) {
+	path := t.TempDir()
+	db, err := Open(DefaultOptions(path))
+	assert.Nil(t, err)
+	_, err = db.RPush([]byte("l"), []byte("a"), []byte("b"), []byte("c"))
+	assert.Nil(t, err)
+
+	for _, idx := range []int{3, -4, -6} {
+		ok, err := db.LSet([]byte("l"), idx, []byte("x"))
+		assert.Nil(t, err)
+		assert.False(t, ok, "index %d", idx)
+	}
+	ok, err := db.LSet([]byte("l"), -3, []byte("A"))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	opts := DefaultOptions(path)
+	opts.ValidateListReplay = true
+	db, err = Open(opts)
+	assert.Nil(t, err)
+	vals, err := db.LRange([]byte("l"), 0, -1)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]byte{[]byte("A"), []byte("b"), []byte("c")}, vals)
+}
+
+// write the operations of every type of List entry.
+func writeListOps(t *testing.T, db *OpenDB) {
+	_, err := db.RPush([]byte("l"), []byte("a"), []byte("b\\0c"), []byte("d"), []byte("e"), []byte("f"))
+	assert.Nil(t, err)
+	_, err = db.LPush([]byte("l"), []byte("z"))
+	assert.Nil(t, err)
+	// the pivot contains the legacy separator.
+	n, err := db.LInsert("l", list.Before, []byte("b\\0c"), []byte("x"))
+	assert.Nil(t, err)
+	assert.Equal(t, 7, n)
+	_, err = db.LInsert("l", list.After, []byte("f"), []byte("y"))
+	assert.Nil(t, err)
+	ok, err := db.LSet([]byte("l"), -2, []byte("F"))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	_, err = db.LPop([]byte("l"))
+	assert.Nil(t, err)
+	_, err = db.RPush([]byte("l"), []byte("a"), []byte("a"))
+	assert.Nil(t, err)
+	_, err = db.LRem([]byte("l"), []byte("a"), -2)
+	assert.Nil(t, err)
+	assert.Nil(t, db.LTrim([]byte("l"), 1, -2))
+	_, err = db.LMove([]byte("l"), []byte("l2"), ListRight, ListLeft)
+	assert.Nil(t, err)
+	_, err = db.RPush([]byte("tmp"), []byte("t"))
+	assert.Nil(t, err)
+	assert.Nil(t, db.LClear([]byte("tmp")))
+}
+
+func assertListOps(t *testing.T, db *OpenDB) {
+	vals, err := db.LRange([]byte("l"), 0, -1)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]byte{[]byte("x"), []byte("b\\0c"), []byte("d"), []byte("e")}, vals)
+	vals, err = db.LRange([]byte("l2"), 0, -1)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]byte{[]byte("F")}, vals)
+	assert.False(t, db.LKeyExists([]byte("tmp")))
+}
+
+func TestOpenDB_ListReplay(t *testing.T) {
+	path := t.TempDir()
+	db, err := Open(DefaultOptions(path))
+	assert.Nil(t, err)
+	writeListOps(t, db)
+	assertListOps(t, db)
+
+	opts := DefaultOptions(path)
+	opts.ValidateListReplay = true
+	db2, err := Open(opts)
+	assert.Nil(t, err)
+	assertListOps(t, db2)
+}
+
+func TestOpenDB_ListReplayMismatch(t *testing.T) {
+	path := t.TempDir()
+	db, err := Open(DefaultOptions(path))
+	assert.Nil(t, err)
+	_, err = db.RPush([]byte("l"), []byte("a"), []byte("b"))
+	assert.Nil(t, err)
+
+	// the trailer is of another list, so the entry does not match the rebuilt list.
+	other := list.New()
+	other.RPush("l", []byte("x"), []byte("b"))
+	_, extra := listPush(other, []byte("l"), []byte("d"), ListRight)
+	assert.Nil(t, db.store(logfile.NewEntry([]byte("l"), []byte("d"), extra, List, ListRPush)))
+
+	_, err = Open(DefaultOptions(path))
+	assert.Nil(t, err)
+	opts := DefaultOptions(path)
+	opts.ValidateListReplay = true
+	_, err = Open(opts)
+	assert.Equal(t, ErrListReplayMismatch, err)
+}
+
+func TestOpenDB_ListReplayMismatchMiddle(t *testing.T) {
+	path := t.TempDir()
+	db, err := Open(DefaultOptions(path))
+	assert.Nil(t, err)
+	_, err = db.RPush([]byte("l"), []byte("a"), []byte("b"), []byte("c"), []byte("d"), []byte("e"))
+	assert.Nil(t, err)
+
+	// the lists differ only in the middle, away from the head, the tail and the set position.
+	other := list.New()
+	other.RPush("l", []byte("a"), []byte("x"), []byte("c"), []byte("d"), []byte("e"))
+	_, extra := listSet(other, []byte("l"), 3, []byte("D"))
+	assert.Nil(t, db.store(logfile.NewEntry([]byte("l"), []byte("D"), extra, List, ListLSet)))
+
+	opts := DefaultOptions(path)
+	opts.ValidateListReplay = true
+	_, err = Open(opts)
+	assert.Equal(t, ErrListReplayMismatch, err)
+}
+
+// write the List entries in the legacy text format, the entries without extra have the mark and the type swapped
+// like NewEntryNoExtra created them before.
+func writeLegacyListFile(t *testing.T, path string, id uint32) {
+	df, err := logfile.NewDBFile(path, id, List)
+	assert.Nil(t, err)
+	entries := []*logfile.Entry{
+		logfile.NewEntry([]byte("l"), []byte("a"), nil, ListRPush, List),
+		logfile.NewEntry([]byte("l"), []byte("b"), nil, ListRPush, List),
+		logfile.NewEntry([]byte("l"), []byte("c"), nil, ListRPush, List),
+		logfile.NewEntry([]byte("l"), []byte("a"), nil, ListRPush, List),
+		logfile.NewEntry([]byte("l"), []byte("x"), []byte("b\\01"), List, ListLInsert),
+		logfile.NewEntry([]byte("l"), []byte("C"), []byte("-2"), List, ListLSet),
+		logfile.NewEntry([]byte("l"), []byte("a"), []byte("-1"), List, ListLRem),
+		logfile.NewEntry([]byte("l"), []byte("a"), nil, ListLPop, List),
+		logfile.NewEntry([]byte("l"), []byte("z"), nil, ListLPush, List),
+		logfile.NewEntry([]byte("l"), nil, []byte("0\\0-2"), List, ListLTrim),
+		logfile.NewEntry([]byte("l"), []byte("C"), []byte("1\\00\\0l2"), List, ListLMove),
+		logfile.NewEntry([]byte("l3"), []byte("a"), nil, ListRPush, List),
+		logfile.NewEntry([]byte("l3"), []byte("b"), nil, ListRPush, List),
+		logfile.NewEntry([]byte("l3"), []byte("c"), nil, ListRPush, List),
+		logfile.NewEntry([]byte("l3"), nil, nil, ListLPop, List),
+		logfile.NewEntry([]byte("l3"), nil, nil, ListRPop, List),
+		logfile.NewEntry([]byte("l4"), []byte("a"), nil, ListRPush, List),
+		logfile.NewEntry([]byte("l4"), nil, nil, ListLClear, List),
+	}
+	for _, e := range entries {
+		assert.Nil(t, df.Write(e))
+	}
+	assert.Nil(t, df.File.Close())
+}
+
+func assertLegacyList(t *testing.T, db *OpenDB) {
+	vals, err := db.LRange([]byte("l"), 0, -1)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]byte{[]byte("z")}, vals)
+	vals, err = db.LRange([]byte("l2"), 0, -1)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]byte{[]byte("b"), []byte("x")}, vals)
+	vals, err = db.LRange([]byte("l3"), 0, -1)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]byte{[]byte("b")}, vals)
+	assert.Equal(t, 0, db.LLen([]byte("l4")))
+}
+
+func TestOpenDB_MigrateListFiles(t *testing.T) {
+	path := t.TempDir()
+	writeLegacyListFile(t, path, 0)
+	// move from the tail of l to the head of l2.
+	df, err := logfile.NewDBFile(path, 1, List)
+	assert.Nil(t, err)
+	assert.Nil(t, df.Write(logfile.NewEntry([]byte("l"), []byte("x"), []byte("1\\00\\0l2"), List, ListLMove)))
+	assert.Nil(t, df.File.Close())
+
+	opts := DefaultOptions(path)
+	opts.ValidateListReplay = true
+	db, err := Open(opts)
+	assert.Nil(t, err)
+	assertLegacyList(t, db)
+	assert.True(t, util.PathExist(filepath.Join(path, listFormatFileName)))
+	assert.False(t, util.PathExist(filepath.Join(path, listMigrateDir)))
+
+	// the migrated files are loaded as they are.
+	_, err = db.RPush([]byte("l"), []byte("n"))
+	assert.Nil(t, err)
+	db2, err := Open(opts)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, db2.LLen([]byte("l")))
+}
+
+func TestOpenDB_MigrateListFilesResume(t *testing.T) {
+	path := t.TempDir()
+	writeLegacyListFile(t, path, 0)
+	writeLegacyListFile(t, path, 1)
+
+	// crashed after the new files are written with a small block size, and one of them is moved.
+	migratePath := filepath.Join(path, listMigrateDir)
+	assert.Nil(t, convertListFiles(path, migratePath, []uint32{0, 1}, 64))
+	assert.Nil(t, os.Rename(listFileName(migratePath, 0), listFileName(path, 0)))
+
+	opts := DefaultOptions(path)
+	opts.ValidateListReplay = true
+	db, err := Open(opts)
+	assert.Nil(t, err)
+	vals, err := db.LRange([]byte("l"), 0, -1)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]byte{[]byte("z"), []byte("b"), []byte("x"), []byte("a")}, vals)
+	vals, err = db.LRange([]byte("l2"), 0, -1)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]byte{[]byte("b"), []byte("x")}, vals)
+}
+
+func TestOpenDB_BLMove_WakeDest(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	done := make(chan []byte)
+	go func() {
+		_, val, _ := db.BLPop(ctx, 0, []byte("dst"))
+		done <- val
+	}()
+	waitListWaiters(t, db, 1)
+
+	// the value moved without blocking wakes the waiter on the destination.
+	_, err := db.RPush([]byte("src"), []byte("a"))
+	assert.Nil(t, err)
+	val, err := db.BLMove(ctx, time.Second, []byte("src"), []byte("dst"), ListLeft, ListRight)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("a"), val)
+	select {
+	case val = <-done:
+		assert.Equal(t, []byte("a"), val)
+	case <-time.After(time.Second):
+		t.Fatal("the waiter on the destination is not woken")
+	}
+}
@@ -1,6 +1,9 @@
 package logfile
 
-import "encoding/binary"
+import (
+	"encoding/binary"
+	"strconv"
+)
 
 const entryHeaderSize = 16
 
@@ -34,7 +37,17 @@ func NewEntry(key, value, Extra []byte, mark, Type uint16) *Entry {
 	}
 }
 func NewEntryNoExtra(key, value []byte,  mark ,Type uint16) *Entry {
-	return NewEntry(key, value, nil, Type, mark)
+	return NewEntry(key, value, nil, mark, Type)
+}
+
+// NewEntryWithExpire create a new entry with an expire deadline(unix seconds), the deadline is saved in Extra.
+func NewEntryWithExpire(key, value []byte, deadline int64, mark, Type uint16) *Entry {
+	return NewEntry(key, value, []byte(strconv.FormatInt(deadline, 10)), mark, Type)
+}
+
+// Deadline returns the expire deadline saved by NewEntryWithExpire.
+func (e *Entry) Deadline() (int64, error) {
+	return strconv.ParseInt(string(e.Extra), 10, 64)
 }
 func (e *Entry) GetSize() int64 {
 	return int64(entryHeaderSize + e.KeySize + e.ValueSize + e.ExtraSize)
@@ -3,13 +3,16 @@ package opendb
 import (
 	"errors"
 	"io"
+	"io/ioutil"
 	"log"
 	"opendb/util"
 	"sort"
 	//"io/ioutil"
 	"opendb/logfile"
 	"os"
+	"path/filepath"
 	"sync"
+	"time"
 	//"opendb/log_entry"
 
 )
@@ -25,6 +28,7 @@ const (
 	StringRem
 	StringExpire
 	StringPersist
+	StringSetRange
 )
 var (
 	// ErrEmptyKey the key is empty
@@ -68,6 +72,54 @@ var (
 
 	// ErrWrongNumberOfArgs wrong number of arguments
 	ErrWrongNumberOfArgs = errors.New("opendb: wrong number of arguments")
+
+	// ErrInvalidOffset offset is out of range
+	ErrInvalidOffset = errors.New("opendb: offset is out of range")
+
+	// ErrInvalidBit bit is not 0 or 1
+	ErrInvalidBit = errors.New("opendb: bit is not 0 or 1")
+
+	// ErrInvalidHLL the value is not a valid hyperloglog
+	ErrInvalidHLL = errors.New("opendb: key is not a valid hyperloglog value")
+
+	// ErrCodecMismatch the db is written by another codec
+	ErrCodecMismatch = errors.New("opendb: codec mismatch, the db is written by another codec")
+
+	// ErrKeyEncodingMismatch the db is written by an unknown key encoding
+	ErrKeyEncodingMismatch = errors.New("opendb: key encoding mismatch, the db is written by an unknown key encoding")
+
+	// ErrValueNotInteger the value is not an integer
+	ErrValueNotInteger = errors.New("opendb: value is not an integer")
+
+	// ErrValueNotFloat the value is not a valid float
+	ErrValueNotFloat = errors.New("opendb: value is not a valid float")
+
+	// ErrIncrOverflow the increment would overflow or produce NaN or Infinity
+	ErrIncrOverflow = errors.New("opendb: increment would overflow or produce NaN or Infinity")
+
+	// ErrInvalidLexRange the lexicographical range is invalid
+	ErrInvalidLexRange = errors.New("opendb: min or max is not a valid lexicographical range bound")
+
+	// ErrZAddFlagsConflict the flags of ZAdd can not be used together
+	ErrZAddFlagsConflict = errors.New("opendb: NX and XX, GT or LT, or GT and LT can not be used together")
+
+	// ErrScoreIsNaN the score of a sorted set is NaN
+	ErrScoreIsNaN = errors.New("opendb: score is not a number (NaN)")
+
+	// ErrInvalidCoordinates the longitude or latitude is out of range
+	ErrInvalidCoordinates = errors.New("opendb: invalid longitude or latitude")
+
+	// ErrInvalidGeoUnit the unit of a distance is unknown
+	ErrInvalidGeoUnit = errors.New("opendb: unsupported unit, use m, km, ft or mi")
+
+	// ErrInvalidGeoShape the area of a geo search is neither a positive radius nor a positive box
+	ErrInvalidGeoShape = errors.New("opendb: geo search needs a positive radius or a positive width and height")
+
+	// ErrMemberNotExist the member does not exist in the sorted set
+	ErrMemberNotExist = errors.New("opendb: member not exist")
+
+	// ErrListReplayMismatch the list rebuilt from the log files is not the same as the one written
+	ErrListReplayMismatch = errors.New("opendb: list replay mismatch, the rebuilt list does not match the checksum")
 )
 var DataStructureNum = 5
 type (
@@ -86,6 +138,7 @@ type (
 		hashIndex       *HashIdx      // Hash indexes.
 		setIndex        *SetIdx       // Set indexes.
 		zsetIndex       *ZsetIdx      // Sorted set indexes.
+		legacyKeys      bool          // the keys are encoded as before they are order-preserving.
 
 	}
 	//map+map+dbfile 第一个map代表不同数据类型的归档文件，第二个代表归档文件的集合
@@ -101,8 +154,16 @@ func Open(opts Options) (*OpenDB, error) {
 			return nil, err
 		}
 	}
+	if opts.Codec == nil {
+		opts.Codec = util.MsgpackCodec
+	}
 	//2.获取文件锁，防止多线程操作同一个文件 TODO
 
+	// migrate the List log files written in the legacy format before loading them.
+	if err := migrateListFiles(opts.DBPath, opts.DefaultBlockSize); err != nil {
+		return nil, err
+	}
+
 	// 3.加载数据文件,构建数据库实例
 	archFiles, activeFileIds, err := logfile.Build(opts.DBPath, opts.DefaultBlockSize)
 	if err != nil {
@@ -116,6 +177,15 @@ func Open(opts Options) (*OpenDB, error) {
 		}
 		activeFiles.Store(dataType, file)
 	}
+	empty := isEmptyDB(archFiles, activeFiles)
+	// the key encoding is checked first, it tells the dbs written before the codec is recorded.
+	legacyKeys, err := checkKeyEncoding(opts.DBPath, empty)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCodec(opts.DBPath, opts.Codec, empty); err != nil {
+		return nil, err
+	}
 
 	db := &OpenDB{
 		//dbFile:  dbFile,
@@ -124,18 +194,104 @@ func Open(opts Options) (*OpenDB, error) {
 		indexes: make(map[string]int64),
 		dirPath: opts.DBPath,
 		opts: opts,
-		//strIndex:   newStrIdx(),
+		expires:    newExpires(),
+		strIndex:   newStrIdx(),
 		listIndex:  newListIdx(),
 		hashIndex:  newHashIdx(),
 		setIndex:   newSetIdx(),
 		zsetIndex:  newZsetIdx(),
+		legacyKeys: legacyKeys,
 	}
 
 	// 扫描文件，加载索引到内存。
-	db.loadIdxFromFiles()
+	if err := db.loadIdxFromFiles(); err != nil {
+		return nil, err
+	}
 	return db, nil
 }
 
+// the file recording the name of the codec which the db is written by.
+const codecFileName = "opendb.codec"
+
+// checkCodec make sure the db is always decoded by the codec it is written by.
+// The codec is recorded when the db is created, a db without the record is written before codecs are pluggable, so it is msgpack.
+func checkCodec(path string, codec util.Codec, empty bool) error {
+	codecFile := filepath.Join(path, codecFileName)
+	name, err := ioutil.ReadFile(codecFile)
+	if err == nil {
+		if string(name) != codec.Name() {
+			return ErrCodecMismatch
+		}
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+
+	if !empty && codec.Name() != util.MsgpackCodec.Name() {
+		return ErrCodecMismatch
+	}
+	recorded := codec.Name()
+	if !empty {
+		recorded = util.MsgpackCodec.Name()
+	}
+	return ioutil.WriteFile(codecFile, []byte(recorded), 0644)
+}
+
+// the file recording the version of the key encoding which the db is written by.
+const keyEncodingFileName = "opendb.keys"
+
+// the versions of the key encoding.
+const (
+	// keyEncodingLegacy numbers are big-endian in their two's complement or IEEE 754 bits, see util.EncodeKeyLegacy.
+	keyEncodingLegacy = "0"
+	// keyEncodingOrdered numbers are encoded in an order-preserving way, see util.EncodeKey.
+	keyEncodingOrdered = "1"
+)
+
+// checkKeyEncoding returns if the keys of the db are in the legacy encoding.
+// The encoded keys do not tell their types, so they can not be re-encoded, and a db keeps the encoding it is created with.
+// A db without the record is written before the keys are order-preserving, unless the codec is recorded, which is added later.
+func checkKeyEncoding(path string, empty bool) (legacy bool, err error) {
+	keysFile := filepath.Join(path, keyEncodingFileName)
+	version, err := ioutil.ReadFile(keysFile)
+	if err == nil {
+		switch string(version) {
+		case keyEncodingLegacy:
+			return true, nil
+		case keyEncodingOrdered:
+			return false, nil
+		}
+		return false, ErrKeyEncodingMismatch
+	}
+	if !os.IsNotExist(err) {
+		return false, err
+	}
+
+	recorded := keyEncodingOrdered
+	if !empty && !util.PathExist(filepath.Join(path, codecFileName)) {
+		recorded = keyEncodingLegacy
+	}
+	return recorded == keyEncodingLegacy, ioutil.WriteFile(keysFile, []byte(recorded), 0644)
+}
+
+// isEmptyDB returns if no entry is written to the db files.
+func isEmptyDB(archFiles ArchivedFiles, activeFiles *sync.Map) bool {
+	empty := true
+	for _, files := range archFiles {
+		if len(files) > 0 {
+			empty = false
+		}
+	}
+	activeFiles.Range(func(_, value interface{}) bool {
+		if value.(*logfile.DBFile).Offset > 0 {
+			empty = false
+		}
+		return empty
+	})
+	return empty
+}
+
 
 
 //// Put 写入数据
@@ -280,8 +436,6 @@ func (db *OpenDB) loadIdxFromFiles() error {
 			// archived files
 			var fileIds []int
 			dbFile := make(map[uint32]*logfile.DBFile)
-			v:=db.archFiles[uint16(dataType)]
-			println(v)
 			for k, v := range db.archFiles[uint16(dataType)] {
 				dbFile[k] = v
 				fileIds = append(fileIds, int(k))
@@ -318,7 +472,7 @@ func (db *OpenDB) loadIdxFromFiles() error {
 						//当有多个索引的时候，在加载时就需要对应不同类型的索引进行加载
 						if len(e.Key) > 0 {
 							if err := db.buildIndex(e, idx, true); err != nil {
-								log.Fatalf("a fatal err occurred, the db can not open.[%+v]", err)
+								return err
 							}
 						}
 					} else {
@@ -421,10 +575,21 @@ func (db *OpenDB) checkKeyValue(key []byte, value ...[]byte) error {
 
 	return nil
 }
-// TODO 对键值对进行过期检查
+// 对键值对进行过期检查，过期时间记录在expires当中
 func (db *OpenDB) checkExpired(key []byte, dType DataType) (expired bool) {
+	deadline, exist := db.expires[dType][string(key)]
+	if !exist {
+		return
+	}
+	return time.Now().Unix() > deadline
+}
 
-	return
+func newExpires() Expires {
+	expires := make(Expires)
+	for dataType := 0; dataType < DataStructureNum; dataType++ {
+		expires[uint16(dataType)] = make(map[string]int64)
+	}
+	return expires
 }
 // build the indexes for different data structures.
 func (db *OpenDB) buildIndex(entry *logfile.Entry, idx *Index, isOpen bool) (err error) {
@@ -439,22 +604,46 @@ func (db *OpenDB) buildIndex(entry *logfile.Entry, idx *Index, isOpen bool) (err
 	case String:
 		db.buildStringIndex(idx, entry)
 	case List:
-		db.buildListIndex(entry)
+		err = db.buildListIndex(entry)
 	case Hash:
 		db.buildHashIndex(entry)
 	case Set:
-		db.buildSetIndex(entry)
+		err = db.buildSetIndex(entry)
 	case ZSet:
-		db.buildZsetIndex(entry)
+		err = db.buildZsetIndex(entry)
 	}
 	return
 }
 func (db *OpenDB) encode(key, value interface{}) (encKey, encVal []byte, err error) {
-	if encKey, err = util.EncodeKey(key); err != nil {
+	if encKey, err = db.encodeKey(key); err != nil {
 		return
 	}
-	if encVal, err = util.EncodeValue(value); err != nil {
+	if encVal, err = util.EncodeValueWith(db.opts.Codec, value); err != nil {
 		return
 	}
 	return
-}
\ No newline at end of file
+}
+
+// encodeKey returns key in bytes by the key encoding of the db.
+func (db *OpenDB) encodeKey(key interface{}) ([]byte, error) {
+	if db.legacyKeys {
+		return util.EncodeKeyLegacy(key)
+	}
+	return util.EncodeKey(key)
+}
+
+// encode the keys and check if they are valid.
+func (db *OpenDB) encodeKeys(keys []interface{}) ([][]byte, error) {
+	encKeys := make([][]byte, 0, len(keys))
+	for _, key := range keys {
+		encKey, err := db.encodeKey(key)
+		if err != nil {
+			return nil, err
+		}
+		if err := db.checkKeyValue(encKey, nil); err != nil {
+			return nil, err
+		}
+		encKeys = append(encKeys, encKey)
+	}
+	return encKeys, nil
+}
@@ -1,6 +1,9 @@
 package opendb
 
-import "time"
+import (
+	"opendb/util"
+	"time"
+)
 
 // DataIndexMode the data index mode.
 type DataIndexMode int
@@ -28,6 +31,12 @@ type Options struct {
 	LogFileGCInterval time.Duration
 	LogFileGCRatio float64
 	DefaultBlockSize int64
+	// Codec encodes the values which are not []byte or string, msgpack is used if it is nil.
+	// The codec is recorded in the db, so a db must be reopened with the same codec.
+	Codec util.Codec
+	// ValidateListReplay check every List entry against the length and the checksum of the whole list while loading the db,
+	// Open fails with ErrListReplayMismatch if a rebuilt list is not the same as the one written.
+	ValidateListReplay bool
 }
 
 // 默认设置，如果用户没有自己定义则使用
@@ -40,6 +49,7 @@ func DefaultOptions(path string) Options {
 		LogFileGCInterval:    time.Hour * 8,
 		LogFileGCRatio:       0.5,
 		DefaultBlockSize: 32 << 20,//为32
+		Codec:            util.MsgpackCodec,
 		//DiscardBufferSize:    4 << 12,
 	}
 }
@@ -2,6 +2,8 @@ package opendb
 
 
 import (
+"encoding/binary"
+"errors"
 "opendb/ds/set"
 "opendb/logfile"
 "sync"
@@ -13,7 +15,11 @@ const (
 	SetSMove
 	SetSClear
 	SetSExpire
+	SetSStore
 )
+
+// errInvalidSetMembers the members of a SetSStore entry can not be decoded.
+var errInvalidSetMembers = errors.New("opendb: invalid set members in log entry")
 // SetIdx the set idx
 type SetIdx struct {
 	mu      *sync.RWMutex
@@ -96,6 +102,25 @@ func (db *OpenDB) SRandMember(key []byte, count int) [][]byte {
 	return db.setIndex.indexes.SRandMember(string(key), count)
 }
 
+// SScan iterates the members of the set stored at key.
+// Start with cursor 0 and call SScan with the returned cursor until it returns 0, the lock is released between the calls.
+// count is the number of members examined in a call, only the members matching the glob-style pattern match are returned.
+// A member present for the whole iteration is returned at least once, and it may be returned more than once.
+func (db *OpenDB) SScan(key []byte, cursor int, match string, count int) (int, [][]byte) {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return 0, nil
+	}
+
+	db.setIndex.mu.RLock()
+	defer db.setIndex.mu.RUnlock()
+
+	if db.checkExpired(key, Set) {
+		return 0, nil
+	}
+
+	return db.setIndex.indexes.SScan(string(key), cursor, match, count)
+}
+
 // SRem remove the specified members from the set stored at key.
 // Specified members that are not a member of this set are ignored.
 // If key does not exist, it is treated as an empty set and this command returns 0.
@@ -183,15 +208,7 @@ func (db *OpenDB) SUnion(keys ...[]byte) (val [][]byte) {
 	db.setIndex.mu.RLock()
 	defer db.setIndex.mu.RUnlock()
 
-	var validKeys []string
-	for _, k := range keys {
-		if db.checkExpired(k, Set) {
-			continue
-		}
-		validKeys = append(validKeys, string(k))
-	}
-
-	return db.setIndex.indexes.SUnion(validKeys...)
+	return db.sUnion(keys)
 }
 
 // SDiff returns the members of the set resulting from the difference between the first set and all the successive sets.
@@ -203,15 +220,89 @@ func (db *OpenDB) SDiff(keys ...[]byte) (val [][]byte) {
 	db.setIndex.mu.RLock()
 	defer db.setIndex.mu.RUnlock()
 
-	var validKeys []string
-	for _, k := range keys {
-		if db.checkExpired(k, Set) {
-			continue
-		}
-		validKeys = append(validKeys, string(k))
+	return db.sDiff(keys)
+}
+
+// SInter returns the members of the set resulting from the intersection of all the given sets.
+func (db *OpenDB) SInter(keys ...[]byte) (val [][]byte) {
+	if len(keys) == 0 {
+		return
 	}
 
-	return db.setIndex.indexes.SDiff(validKeys...)
+	db.setIndex.mu.RLock()
+	defer db.setIndex.mu.RUnlock()
+
+	return db.sInter(keys)
+}
+
+// SInterCard returns the cardinality of the set resulting from the intersection of all the given sets.
+// If limit is positive, the counting stops when the cardinality reaches limit.
+func (db *OpenDB) SInterCard(limit int, keys ...[]byte) int {
+	if len(keys) == 0 {
+		return 0
+	}
+
+	db.setIndex.mu.RLock()
+	defer db.setIndex.mu.RUnlock()
+
+	validKeys, ok := db.setInterKeys(keys)
+	if !ok {
+		return 0
+	}
+	return db.setIndex.indexes.SInterCard(limit, validKeys...)
+}
+
+// SMIsMember returns whether each member is a member of the set stored at key.
+func (db *OpenDB) SMIsMember(key []byte, members ...[]byte) []bool {
+	db.setIndex.mu.RLock()
+	defer db.setIndex.mu.RUnlock()
+
+	if db.checkExpired(key, Set) {
+		return make([]bool, len(members))
+	}
+	return db.setIndex.indexes.SMIsMember(string(key), members...)
+}
+
+// SUnionStore stores the union of all the given sets in dst, and returns the number of members in it.
+// If dst already exists, it is overwritten.
+func (db *OpenDB) SUnionStore(dst []byte, keys ...[]byte) (int, error) {
+	if err := db.checkSetStore(dst, keys); err != nil {
+		return 0, err
+	}
+
+	db.setIndex.mu.Lock()
+	defer db.setIndex.mu.Unlock()
+
+	members := db.sUnion(keys)
+	return len(members), db.sStore(dst, members)
+}
+
+// SInterStore stores the intersection of all the given sets in dst, and returns the number of members in it.
+// If dst already exists, it is overwritten.
+func (db *OpenDB) SInterStore(dst []byte, keys ...[]byte) (int, error) {
+	if err := db.checkSetStore(dst, keys); err != nil {
+		return 0, err
+	}
+
+	db.setIndex.mu.Lock()
+	defer db.setIndex.mu.Unlock()
+
+	members := db.sInter(keys)
+	return len(members), db.sStore(dst, members)
+}
+
+// SDiffStore stores the difference between the first set and all the successive sets in dst, and returns the number of members in it.
+// If dst already exists, it is overwritten.
+func (db *OpenDB) SDiffStore(dst []byte, keys ...[]byte) (int, error) {
+	if err := db.checkSetStore(dst, keys); err != nil {
+		return 0, err
+	}
+
+	db.setIndex.mu.Lock()
+	defer db.setIndex.mu.Unlock()
+
+	members := db.sDiff(keys)
+	return len(members), db.sStore(dst, members)
 }
 
 // SKeyExists returns if the key exists.
@@ -284,3 +375,118 @@ func (db *OpenDB) STTL(key []byte) (ttl int64) {
 	}
 	return deadline - time.Now().Unix()
 }
+
+func (db *OpenDB) sUnion(keys [][]byte) [][]byte {
+	var validKeys []string
+	for _, k := range keys {
+		if db.checkExpired(k, Set) {
+			continue
+		}
+		validKeys = append(validKeys, string(k))
+	}
+	return db.setIndex.indexes.SUnion(validKeys...)
+}
+
+// sDiff skips the expired keys, which are empty sets, an expired first key makes the difference empty.
+func (db *OpenDB) sDiff(keys [][]byte) [][]byte {
+	if len(keys) == 0 || db.checkExpired(keys[0], Set) {
+		return nil
+	}
+	validKeys := []string{string(keys[0])}
+	for _, k := range keys[1:] {
+		if db.checkExpired(k, Set) {
+			continue
+		}
+		validKeys = append(validKeys, string(k))
+	}
+	return db.setIndex.indexes.SDiff(validKeys...)
+}
+
+func (db *OpenDB) sInter(keys [][]byte) [][]byte {
+	validKeys, ok := db.setInterKeys(keys)
+	if !ok {
+		return nil
+	}
+	return db.setIndex.indexes.SInter(validKeys...)
+}
+
+// setInterKeys returns the keys of an intersection, ok is false if any of them is expired,
+// since an expired set is empty, and so is the intersection.
+func (db *OpenDB) setInterKeys(keys [][]byte) (validKeys []string, ok bool) {
+	for _, k := range keys {
+		if db.checkExpired(k, Set) {
+			return nil, false
+		}
+		validKeys = append(validKeys, string(k))
+	}
+	return validKeys, true
+}
+
+func (db *OpenDB) checkSetStore(dst []byte, keys [][]byte) error {
+	if err := db.checkKeyValue(dst, nil); err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return ErrWrongNumberOfArgs
+	}
+	return nil
+}
+
+// sStore replaces the set dst with members by a single log entry, so the result is written atomically.
+// dst is removed if there is no member.
+func (db *OpenDB) sStore(dst []byte, members [][]byte) error {
+	var e *logfile.Entry
+	if len(members) == 0 {
+		if !db.setIndex.indexes.SKeyExists(string(dst)) {
+			return nil
+		}
+		e = logfile.NewEntryNoExtra(dst, nil, Set, SetSClear)
+	} else {
+		e = logfile.NewEntryNoExtra(dst, encodeSetMembers(members), Set, SetSStore)
+	}
+	if err := db.store(e); err != nil {
+		return err
+	}
+
+	db.setIndex.indexes.SStore(string(dst), members)
+	delete(db.expires[Set], string(dst))
+	return nil
+}
+
+// encodeSetMembers encodes the members as the count followed by every member prefixed with its length, all in uvarint.
+func encodeSetMembers(members [][]byte) []byte {
+	size := binary.MaxVarintLen64
+	for _, m := range members {
+		size += binary.MaxVarintLen64 + len(m)
+	}
+
+	buf := make([]byte, size)
+	n := binary.PutUvarint(buf, uint64(len(members)))
+	for _, m := range members {
+		n += binary.PutUvarint(buf[n:], uint64(len(m)))
+		n += copy(buf[n:], m)
+	}
+	return buf[:n]
+}
+
+func decodeSetMembers(buf []byte) ([][]byte, error) {
+	count, n := binary.Uvarint(buf)
+	if n <= 0 || count > uint64(len(buf)) {
+		return nil, errInvalidSetMembers
+	}
+	buf = buf[n:]
+
+	members := make([][]byte, 0, count)
+	for i := uint64(0); i < count; i++ {
+		size, n := binary.Uvarint(buf)
+		if n <= 0 || size > uint64(len(buf)-n) {
+			return nil, errInvalidSetMembers
+		}
+		members = append(members, buf[n:n+int(size)])
+		buf = buf[n+int(size):]
+	}
+	if len(buf) != 0 {
+		return nil, errInvalidSetMembers
+	}
+	return members, nil
+}
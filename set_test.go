@@ -1,7 +1,11 @@
 package opendb
 
 import (
-"testing"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
 )
 
 
@@ -18,3 +22,116 @@ func TestOpenDB_set(t *testing.T) {
 		t.Log(string(v))
 	}
 }
+
+func TestOpenDB_SScan(t *testing.T) {
+	db := openTestDB(t)
+
+	key := []byte("s")
+	// the members are not integers, so they are returned in pages.
+	for i := 0; i < 50; i++ {
+		_, err := db.SAdd(key, []byte(fmt.Sprintf("m%d", i)))
+		assert.Nil(t, err)
+	}
+
+	seen := make(map[string]int)
+	cursor := 0
+	for {
+		var res [][]byte
+		cursor, res = db.SScan(key, cursor, "", 4)
+		for _, m := range res {
+			seen[string(m)]++
+		}
+		// remove a member which is returned already.
+		if len(res) > 0 {
+			_, err := db.SRem(key, res[0])
+			assert.Nil(t, err)
+		}
+		if cursor == 0 {
+			break
+		}
+	}
+	assert.Equal(t, 50, len(seen))
+
+	_, res := db.SScan(key, 0, "m1?", 100)
+	for _, m := range res {
+		assert.Equal(t, 3, len(m))
+		assert.Equal(t, []byte("m1"), m[:2])
+	}
+}
+
+func TestOpenDB_SInter(t *testing.T) {
+	db := openTestDB(t)
+
+	_, err := db.SAdd([]byte("s1"), []byte("a"), []byte("b"), []byte("c"), []byte("d"))
+	assert.Nil(t, err)
+	_, err = db.SAdd([]byte("s2"), []byte("c"), []byte("d"), []byte("e"))
+	assert.Nil(t, err)
+
+	assert.ElementsMatch(t, [][]byte{[]byte("c"), []byte("d")}, db.SInter([]byte("s1"), []byte("s2")))
+	assert.Nil(t, db.SInter([]byte("s1"), []byte("none")))
+	assert.Equal(t, 2, db.SInterCard(0, []byte("s1"), []byte("s2")))
+	assert.Equal(t, 1, db.SInterCard(1, []byte("s1"), []byte("s2")))
+	assert.Equal(t, 4, db.SInterCard(10, []byte("s1")))
+	assert.Equal(t, []bool{true, false, true}, db.SMIsMember([]byte("s2"), []byte("c"), []byte("a"), []byte("e")))
+}
+
+func TestOpenDB_SDiffExpired(t *testing.T) {
+	db := openTestDB(t)
+
+	_, err := db.SAdd([]byte("s1"), []byte("a"), []byte("b"))
+	assert.Nil(t, err)
+	_, err = db.SAdd([]byte("s2"), []byte("b"), []byte("c"))
+	assert.Nil(t, err)
+	_, err = db.SAdd([]byte("s3"), []byte("c"))
+	assert.Nil(t, err)
+
+	// an expired set is empty, so is the difference from it.
+	db.expires[Set]["s1"] = time.Now().Unix() - 1
+	assert.Nil(t, db.SDiff([]byte("s1"), []byte("s3")))
+	n, err := db.SDiffStore([]byte("dst"), []byte("s1"), []byte("s3"))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, n)
+
+	// an expired set after the first one removes nothing.
+	assert.ElementsMatch(t, [][]byte{[]byte("b"), []byte("c")}, db.SDiff([]byte("s2"), []byte("s1")))
+}
+
+func TestOpenDB_SStore(t *testing.T) {
+	path := t.TempDir()
+	db, err := Open(DefaultOptions(path))
+	assert.Nil(t, err)
+
+	_, err = db.SAdd([]byte("s1"), []byte("a"), []byte("b"), []byte("c"))
+	assert.Nil(t, err)
+	_, err = db.SAdd([]byte("s2"), []byte("b"), []byte("c"), []byte("d"))
+	assert.Nil(t, err)
+	_, err = db.SAdd([]byte("dst"), []byte("old"))
+	assert.Nil(t, err)
+
+	n, err := db.SUnionStore([]byte("union"), []byte("s1"), []byte("s2"))
+	assert.Nil(t, err)
+	assert.Equal(t, 4, n)
+	n, err = db.SInterStore([]byte("dst"), []byte("s1"), []byte("s2"))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+	n, err = db.SDiffStore([]byte("diff"), []byte("s1"), []byte("s2"))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+	// an empty result removes the destination.
+	n, err = db.SDiffStore([]byte("s2"), []byte("s1"), []byte("s1"))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, n)
+	assert.False(t, db.SKeyExists([]byte("s2")))
+
+	_, err = db.SInterStore([]byte("dst"))
+	assert.Equal(t, ErrWrongNumberOfArgs, err)
+
+	// the stored sets are rebuilt from the log.
+	db2, err := Open(DefaultOptions(path))
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, [][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("d")}, db2.SMembers([]byte("union")))
+	assert.ElementsMatch(t, [][]byte{[]byte("b"), []byte("c")}, db2.SMembers([]byte("dst")))
+	assert.Equal(t, [][]byte{[]byte("a")}, db2.SMembers([]byte("diff")))
+	assert.Equal(t, 3, db2.SCard([]byte("s1")))
+	assert.False(t, db2.SKeyExists([]byte("s2")))
+}
@@ -4,6 +4,7 @@ package opendb
 import (
 "bytes"
 	"opendb/logfile"
+	"strconv"
 	"strings"
 "sync"
 "time"
@@ -18,6 +19,15 @@ type StrIdx struct {
 	idxList *index.SkipList
 }
 
+// KeyValue a key and its value of String.
+type KeyValue struct {
+	Key   []byte
+	Value []byte
+}
+
+// the max length of a string grown by SetRange, same as redis (512MB).
+const maxStringLength = 1 << 29
+
 func newStrIdx() *StrIdx {
 	return &StrIdx{
 		idxList: index.NewSkipList(), mu: new(sync.RWMutex),
@@ -36,20 +46,49 @@ func (db *OpenDB) Set(key, value interface{}) error {
 
 // SetNx is short for "Set if not exists", set key to hold string value if key does not exist.
 // In that case, it is equal to Set. When key already holds a value, no operation is performed.
-//func (db *OpenDB) SetNx(key, value interface{}) (ok bool, err error) {
-//	encKey, encVal, err := db.encode(key, value)
-//	if err != nil {
-//		return false, err
-//	}
-//	if exist := db.StrExists(encKey); exist {
-//		return
-//	}
-//
-//	if err = db.Set(encKey, encVal); err == nil {
-//		ok = true
-//	}
-//	return
-//}
+func (db *OpenDB) SetNx(key, value interface{}) (ok bool, err error) {
+	encKey, encVal, err := db.encode(key, value)
+	if err != nil {
+		return false, err
+	}
+	if err = db.checkKeyValue(encKey, encVal); err != nil {
+		return
+	}
+
+	db.strIndex.mu.Lock()
+	defer db.strIndex.mu.Unlock()
+
+	if db.strExists(encKey) {
+		return
+	}
+	if err = db.putVal(encKey, encVal); err == nil {
+		ok = true
+	}
+	return
+}
+
+// SetXX set key to hold string value only if key already exists.
+// When key does not exist, no operation is performed.
+func (db *OpenDB) SetXX(key, value interface{}) (ok bool, err error) {
+	encKey, encVal, err := db.encode(key, value)
+	if err != nil {
+		return false, err
+	}
+	if err = db.checkKeyValue(encKey, encVal); err != nil {
+		return
+	}
+
+	db.strIndex.mu.Lock()
+	defer db.strIndex.mu.Unlock()
+
+	if !db.strExists(encKey) {
+		return
+	}
+	if err = db.putVal(encKey, encVal); err == nil {
+		ok = true
+	}
+	return
+}
 
 //// SetEx 设置key的过期时间
 //func (db *OpenDB) SetEx(key, value interface{}, duration int64) (err error) {
@@ -82,7 +121,7 @@ func (db *OpenDB) Set(key, value interface{}) error {
 
 // Get get the value of key. If the key does not exist an error is returned.
 func (db *OpenDB) Get(key, dest interface{}) error {
-	encKey, err := util.EncodeKey(key)
+	encKey, err := db.encodeKey(key)
 	if err != nil {
 		return err
 	}
@@ -100,19 +139,121 @@ func (db *OpenDB) Get(key, dest interface{}) error {
 	}
 
 	if len(val) > 0 {
-		err = util.DecodeValue(val, dest)
+		err = util.DecodeValueWith(db.opts.Codec, val, dest)
 	}
 	return err
 }
 
 // GetSet set key to value and returns the old value stored at key.
-// If the key not exist, return an err.
+// If the key not exist, the value is still set and dest is left untouched.
 func (db *OpenDB) GetSet(key, value, dest interface{}) (err error) {
-	err = db.Get(key, dest)
+	encKey, encVal, err := db.encode(key, value)
+	if err != nil {
+		return err
+	}
+	if err = db.checkKeyValue(encKey, encVal); err != nil {
+		return
+	}
+
+	db.strIndex.mu.Lock()
+	defer db.strIndex.mu.Unlock()
+
+	oldVal, err := db.getVal(encKey)
 	if err != nil && err != ErrKeyNotExist && err != ErrKeyExpired {
 		return
 	}
-	return db.Set(key, value)
+	if err = db.putVal(encKey, encVal); err != nil {
+		return
+	}
+
+	if len(oldVal) > 0 {
+		err = util.DecodeValueWith(db.opts.Codec, oldVal, dest)
+	}
+	return
+}
+
+// GetDel get the value of key and delete the key. If the key does not exist an error is returned.
+func (db *OpenDB) GetDel(key, dest interface{}) error {
+	encKey, err := db.encodeKey(key)
+	if err != nil {
+		return err
+	}
+	if err := db.checkKeyValue(encKey, nil); err != nil {
+		return err
+	}
+
+	db.strIndex.mu.Lock()
+	defer db.strIndex.mu.Unlock()
+
+	val, err := db.getVal(encKey)
+	if err != nil {
+		return err
+	}
+	if err = db.removeVal(encKey); err != nil {
+		return err
+	}
+
+	if len(val) > 0 {
+		err = util.DecodeValueWith(db.opts.Codec, val, dest)
+	}
+	return err
+}
+
+// GetExOptions the change of the time to live by GetEx, the zero value leaves it unchanged.
+type GetExOptions struct {
+	// Duration the key will expire after Duration seconds if it is positive.
+	Duration int64
+	// Persist removes the time to live of the key, it can not be used with Duration.
+	Persist bool
+}
+
+// GetEx get the value of key and update its time to live by opts.
+// If the key does not exist an error is returned.
+func (db *OpenDB) GetEx(key, dest interface{}, opts GetExOptions) error {
+	if opts.Duration < 0 || (opts.Duration > 0 && opts.Persist) {
+		return ErrInvalidTTL
+	}
+	encKey, err := db.encodeKey(key)
+	if err != nil {
+		return err
+	}
+	if err := db.checkKeyValue(encKey, nil); err != nil {
+		return err
+	}
+
+	db.strIndex.mu.Lock()
+	defer db.strIndex.mu.Unlock()
+
+	val, err := db.getVal(encKey)
+	if err != nil {
+		return err
+	}
+
+	if opts.Duration > 0 {
+		deadline := time.Now().Unix() + opts.Duration
+		e := logfile.NewEntryWithExpire(encKey, val, deadline, String, StringExpire)
+		if err = db.store(e); err != nil {
+			return err
+		}
+		if err = db.setIndexer(e); err != nil {
+			return err
+		}
+		db.expires[String][string(encKey)] = deadline
+	} else if _, ok := db.expires[String][string(encKey)]; ok && opts.Persist {
+		e := logfile.NewEntryNoExtra(encKey, val, String, StringPersist)
+		if err = db.store(e); err != nil {
+			return err
+		}
+		if err = db.setIndexer(e); err != nil {
+			return err
+		}
+		delete(db.expires[String], string(encKey))
+	}
+
+	if len(val) > 0 {
+		err = util.DecodeValueWith(db.opts.Codec, val, dest)
+	}
+	return err
 }
 
 // MSet set multiple keys to multiple values
@@ -189,7 +330,7 @@ func (db *OpenDB) MSet(values ...interface{}) error {
 func (db *OpenDB) MGet(keys ...interface{}) ([][]byte, error) {
 	encKeys := make([][]byte, 0)
 	for _, key := range keys {
-		encKey, err := util.EncodeKey(key)
+		encKey, err := db.encodeKey(key)
 		if err != nil {
 			return nil, err
 		}
@@ -238,9 +379,112 @@ func (db *OpenDB) Append(key interface{}, value string) (err error) {
 	return db.Set(encKey, existVal)
 }
 
+// StrLen returns the length of the string value stored at key.
+// If the key does not exist, 0 is returned.
+func (db *OpenDB) StrLen(key interface{}) int {
+	encKey, err := db.encodeKey(key)
+	if err != nil {
+		return 0
+	}
+	if err := db.checkKeyValue(encKey, nil); err != nil {
+		return 0
+	}
+
+	db.strIndex.mu.RLock()
+	defer db.strIndex.mu.RUnlock()
+
+	val, err := db.getVal(encKey)
+	if err != nil {
+		return 0
+	}
+	return len(val)
+}
+
+// GetRange returns the substring of the string value stored at key, determined by the offsets start and end (both are inclusive).
+// Negative offsets can be used in order to provide an offset starting from the end of the string.
+// So -1 means the last character, -2 the penultimate and so forth.
+// If the key does not exist, an empty string is returned.
+func (db *OpenDB) GetRange(key interface{}, start, end int) ([]byte, error) {
+	encKey, err := db.encodeKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.checkKeyValue(encKey, nil); err != nil {
+		return nil, err
+	}
+
+	db.strIndex.mu.RLock()
+	defer db.strIndex.mu.RUnlock()
+
+	val, err := db.getVal(encKey)
+	if err != nil {
+		if err == ErrKeyNotExist || err == ErrKeyExpired {
+			return []byte{}, nil
+		}
+		return nil, err
+	}
+
+	length := len(val)
+	if start < 0 {
+		start += length
+	}
+	if end < 0 {
+		end += length
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end >= length {
+		end = length - 1
+	}
+	if start > end || start >= length {
+		return []byte{}, nil
+	}
+
+	res := make([]byte, end-start+1)
+	copy(res, val[start:end+1])
+	return res, nil
+}
+
+// SetRange overwrites part of the string stored at key, starting at the specified offset, for the entire length of value.
+// If the offset is larger than the current length of the string at key, the string is padded with zero-bytes to make offset fit.
+// Non-existing keys are considered as empty strings. Returns the length of the string after it was modified.
+// ErrInvalidOffset is returned if the string would be longer than 512MB.
+func (db *OpenDB) SetRange(key interface{}, offset int, value []byte) (int, error) {
+	if offset < 0 || offset > maxStringLength-len(value) {
+		return 0, ErrInvalidOffset
+	}
+	encKey, err := db.encodeKey(key)
+	if err != nil {
+		return 0, err
+	}
+	if err := db.checkKeyValue(encKey, value); err != nil {
+		return 0, err
+	}
+
+	db.strIndex.mu.Lock()
+	defer db.strIndex.mu.Unlock()
+
+	existVal, err := db.getVal(encKey)
+	exist := err == nil
+	if err != nil && err != ErrKeyNotExist && err != ErrKeyExpired {
+		return 0, err
+	}
+	if len(value) == 0 {
+		if !exist {
+			return 0, nil
+		}
+		return len(existVal), nil
+	}
+	if !exist {
+		existVal = nil
+	}
+	return db.writeRange(encKey, existVal, offset, value)
+}
+
 // StrExists check whether the key exists.
 func (db *OpenDB) StrExists(key interface{}) bool {
-	encKey, err := util.EncodeKey(key)
+	encKey, err := db.encodeKey(key)
 	if err != nil {
 		return false
 	}
@@ -260,7 +504,7 @@ func (db *OpenDB) StrExists(key interface{}) bool {
 
 // Remove remove the value stored at key.
 func (db *OpenDB) Remove(key interface{}) error {
-	encKey, err := util.EncodeKey(key)
+	encKey, err := db.encodeKey(key)
 	if err != nil {
 		return err
 	}
@@ -271,14 +515,7 @@ func (db *OpenDB) Remove(key interface{}) error {
 	db.strIndex.mu.Lock()
 	defer db.strIndex.mu.Unlock()
 
-	e := logfile.NewEntryNoExtra(encKey, nil, String, StringRem)
-	if err := db.store(e); err != nil {
-		return err
-	}
-
-	db.strIndex.idxList.Remove(encKey)
-	delete(db.expires[String], string(encKey))
-	return nil
+	return db.removeVal(encKey)
 }
 
 // PrefixScan find the value corresponding to all matching keys based on the prefix.
@@ -333,40 +570,143 @@ func (db *OpenDB) PrefixScan(prefix string, limit, offset int) (val []interface{
 	return
 }
 
-// RangeScan find range of values from start to end.
+// Scan incrementally iterates the keys of String, returns the matched keys with their values and the cursor to resume.
+// cursor is the one returned by the previous call, use nil to start a new iteration, and the returned cursor is nil when the iteration is finished.
+// match is a glob-style pattern such as "user:*:profile", an empty match matches all the keys.
+// count is the max number of keys examined in one call(10 if not positive), so a call may return no keys while the iteration is not finished.
+// Keys are iterated in order and the cursor is the last examined key, so every key present for the whole iteration is returned exactly once,
+// even if other keys are inserted or removed between calls.
+func (db *OpenDB) Scan(cursor []byte, match string, count int) (kvs []KeyValue, next []byte, err error) {
+	if count <= 0 {
+		count = util.DefaultScanCount
+	}
+	if match == "" {
+		match = "*"
+	}
+	prefix := []byte(util.GlobPrefix(match))
+
+	db.strIndex.mu.RLock()
+	defer db.strIndex.mu.RUnlock()
+
+	var e *index.Element
+	if len(cursor) == 0 || bytes.Compare(cursor, prefix) < 0 {
+		e = db.strIndex.idxList.Seek(prefix)
+	} else if e = db.strIndex.idxList.Seek(cursor); e != nil && bytes.Equal(e.Key(), cursor) {
+		e = e.Next()
+	}
+
+	for ; e != nil && count > 0; e = e.Next() {
+		// keys are ordered, so no more keys will match the pattern.
+		if !bytes.HasPrefix(e.Key(), prefix) {
+			return kvs, nil, nil
+		}
+		count--
+		next = e.Key()
+
+		if db.checkExpired(e.Key(), String) || !util.GlobMatch(match, string(e.Key())) {
+			continue
+		}
+		val, err := db.getVal(e.Key())
+		if err != nil && err != ErrKeyNotExist && err != ErrKeyExpired {
+			return nil, nil, err
+		}
+		if err == nil {
+			kvs = append(kvs, KeyValue{Key: e.Key(), Value: val})
+		}
+	}
+
+	if e == nil {
+		next = nil
+	}
+	return kvs, next, nil
+}
+
+// RangeScan find range of values from start to end (both are inclusive).
 func (db *OpenDB) RangeScan(start, end interface{}) (val []interface{}, err error) {
-	startKey, err := util.EncodeKey(start)
+	kvs, err := db.RangeScanWithOptions(start, end, RangeOptions{})
 	if err != nil {
 		return nil, err
 	}
-	endKey, err := util.EncodeKey(end)
-	if err != nil {
-		return nil, err
+	for _, kv := range kvs {
+		val = append(val, kv.Value)
+	}
+	return
+}
+
+// RangeOptions options of RangeScanWithOptions.
+type RangeOptions struct {
+	// StartExclusive excludes the start key from the range.
+	StartExclusive bool
+	// EndExclusive excludes the end key from the range.
+	EndExclusive bool
+	// Limit the max number of keys returned, not positive means no limit.
+	Limit int
+	// Reverse returns the keys from end to start.
+	Reverse bool
+}
+
+// RangeScanWithOptions find range of keys and values from start to end, the bounds and order are specified by opts.
+// A nil start or end means the range is open on that side.
+// The values are loaded like Get, so in KeyOnlyMemMode they are read from the db files.
+func (db *OpenDB) RangeScanWithOptions(start, end interface{}, opts RangeOptions) (kvs []KeyValue, err error) {
+	var startKey, endKey []byte
+	if start != nil {
+		if startKey, err = db.encodeKey(start); err != nil {
+			return nil, err
+		}
+	}
+	if end != nil {
+		if endKey, err = db.encodeKey(end); err != nil {
+			return nil, err
+		}
+	}
+	if startKey != nil && endKey != nil && bytes.Compare(startKey, endKey) > 0 {
+		return
 	}
 
-	node := db.strIndex.idxList.Get(startKey)
+	// check if the key is out of the bound at the end of iteration.
+	beyond := func(key []byte) bool {
+		if opts.Reverse {
+			return startKey != nil && (bytes.Compare(key, startKey) < 0 || opts.StartExclusive && bytes.Equal(key, startKey))
+		}
+		return endKey != nil && (bytes.Compare(key, endKey) > 0 || opts.EndExclusive && bytes.Equal(key, endKey))
+	}
 
 	db.strIndex.mu.RLock()
 	defer db.strIndex.mu.RUnlock()
 
-	for node != nil && bytes.Compare(node.Key(), endKey) <= 0 {
-		if db.checkExpired(node.Key(), String) {
-			node = node.Next()
-			continue
+	iter := db.strIndex.idxList.NewIterator()
+	if opts.Reverse {
+		if endKey == nil {
+			iter.SeekToLast()
+		} else if iter.SeekForPrev(endKey); opts.EndExclusive && iter.Valid() && bytes.Equal(iter.Key(), endKey) {
+			iter.Prev()
+		}
+	} else {
+		if startKey == nil {
+			iter.SeekToFirst()
+		} else if iter.Seek(startKey); opts.StartExclusive && iter.Valid() && bytes.Equal(iter.Key(), startKey) {
+			iter.Next()
 		}
+	}
 
-		var value interface{}
-		if db.opts.IdxMode == KeyOnlyMemMode {
-			err = db.Get(node.Key(), &value)
-			if err != nil && err != ErrKeyNotExist {
-				return nil, err
+	for iter.Valid() && !beyond(iter.Key()) {
+		val, err := db.getVal(iter.Key())
+		if err != nil && err != ErrKeyNotExist && err != ErrKeyExpired {
+			return nil, err
+		}
+		if err == nil {
+			kvs = append(kvs, KeyValue{Key: iter.Key(), Value: val})
+			if opts.Limit > 0 && len(kvs) >= opts.Limit {
+				break
 			}
-		} else {
-			value = node.Value().(*Index).Meta.Value
 		}
 
-		val = append(val, value)
-		node = node.Next()
+		if opts.Reverse {
+			iter.Prev()
+		} else {
+			iter.Next()
+		}
 	}
 	return
 }
@@ -424,7 +764,7 @@ func (db *OpenDB) Persist(key interface{}) (err error) {
 
 // TTL Time to live.
 func (db *OpenDB) TTL(key interface{}) (ttl int64) {
-	encKey, err := util.EncodeKey(key)
+	encKey, err := db.encodeKey(key)
 	if err != nil {
 		return
 	}
@@ -448,6 +788,9 @@ func (db *OpenDB) setVal(key, value []byte) (err error) {
 		return err
 	}
 
+	db.strIndex.mu.Lock()
+	defer db.strIndex.mu.Unlock()
+
 	// If the existed value is the same as the set value, nothing will be done.
 	if db.opts.IdxMode == KeyValueMemMode {
 		var existVal []byte
@@ -457,13 +800,14 @@ func (db *OpenDB) setVal(key, value []byte) (err error) {
 		}
 
 		if bytes.Compare(existVal, value) == 0 {
-			return
+			return nil
 		}
 	}
+	return db.putVal(key, value)
+}
 
-	db.strIndex.mu.Lock()
-	defer db.strIndex.mu.Unlock()
-
+// putVal write the key and value to db file and update the index, strIndex.mu must be held.
+func (db *OpenDB) putVal(key, value []byte) (err error) {
 	e := logfile.NewEntryNoExtra(key, value, String, StringSet)
 	if err := db.store(e); err != nil {
 		return err
@@ -474,29 +818,77 @@ func (db *OpenDB) setVal(key, value []byte) (err error) {
 		delete(db.expires[String], string(key))
 	}
 	// set String index info, stored at skip list.
-	if err = db.setIndexer(e); err != nil {
-		return
+	return db.setIndexer(e)
+}
+
+// writeRange overwrite existVal by patch at offset and write it to db file, strIndex.mu must be held.
+// existVal is nil if the key does not exist. Returns the length of the new value.
+func (db *OpenDB) writeRange(key, existVal []byte, offset int, patch []byte) (int, error) {
+	newVal := setRange(existVal, offset, patch)
+
+	// In KeyValueMemMode the whole value is in memory, so only the overwritten part is written to db file,
+	// otherwise the value must be read from db file and the whole value is rewritten.
+	if db.opts.IdxMode == KeyValueMemMode && existVal != nil {
+		e := logfile.NewEntry(key, patch, []byte(strconv.Itoa(offset)), String, StringSetRange)
+		if err := db.store(e); err != nil {
+			return 0, err
+		}
+		return len(newVal), db.setIndexerWithValue(e, newVal)
 	}
-	return
+
+	return len(newVal), db.replaceVal(key, newVal)
+}
+
+// replaceVal replace the value of key and keep its time to live, strIndex.mu must be held.
+func (db *OpenDB) replaceVal(key, value []byte) error {
+	if deadline, ok := db.expires[String][string(key)]; ok && db.strExists(key) {
+		e := logfile.NewEntryWithExpire(key, value, deadline, String, StringExpire)
+		if err := db.store(e); err != nil {
+			return err
+		}
+		return db.setIndexer(e)
+	}
+	return db.putVal(key, value)
+}
+
+// removeVal write a remove entry to db file and delete the key from the index, strIndex.mu must be held.
+func (db *OpenDB) removeVal(key []byte) error {
+	e := logfile.NewEntryNoExtra(key, nil, String, StringRem)
+	if err := db.store(e); err != nil {
+		return err
+	}
+
+	db.strIndex.idxList.Remove(key)
+	delete(db.expires[String], string(key))
+	return nil
+}
+
+// strExists check whether the key exists and not expired, strIndex.mu must be held.
+func (db *OpenDB) strExists(key []byte) bool {
+	return db.strIndex.idxList.Exist(key) && !db.checkExpired(key, String)
 }
 
 func (db *OpenDB) setIndexer(e *logfile.Entry) error {
+	return db.setIndexerWithValue(e, e.Value)
+}
+
+// setIndexerWithValue set the index of the entry, value is the whole value of the key after the entry is applied.
+func (db *OpenDB) setIndexerWithValue(e *logfile.Entry, value []byte) error {
 	activeFile, err := db.getActiveFile(String)
 	if err != nil {
 		return err
 	}
 	// string indexes, stored in skiplist.
 	idx := &Index{
-		//Meta: &{TODO
-		//	Key: e.Key,
-		//},
 		FileId: activeFile.Id,
 		Offset: activeFile.Offset - int64(e.GetSize()),
 	}
 
+	idx.Meta.Key = e.Key
+
 	// in KeyValueMemMode, both key and value will store in memory.
 	if db.opts.IdxMode == KeyValueMemMode {
-		idx.Meta.Value = e.Value
+		idx.Meta.Value = value
 	}
 	db.strIndex.idxList.Put(idx.Meta.Key, idx)
 	return nil
@@ -545,9 +937,26 @@ func (db *OpenDB) getVal(key []byte) ([]byte, error) {
 		if err != nil {
 			return nil, err
 		}
+		// a SetRange entry only holds part of the value, the whole value is rebuilt in memory when loading.
+		if e.GetType() == StringSetRange {
+			return idx.Meta.Value, nil
+		}
 		value := e.Value
 		//db.cache.Set(key, value)
 		return value, nil
 	}
 	return nil, ErrKeyNotExist
 }
+
+// setRange returns a copy of val overwritten by patch at offset, padded with zero-bytes if necessary.
+func setRange(val []byte, offset int, patch []byte) []byte {
+	length := len(val)
+	if offset+len(patch) > length {
+		length = offset + len(patch)
+	}
+
+	res := make([]byte, length)
+	copy(res, val)
+	copy(res[offset:], patch)
+	return res
+}
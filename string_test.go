@@ -0,0 +1,475 @@
+package opendb
+
+import (
+	"fmt"
+	"opendb/util"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func openTestDB(t *testing.T) *OpenDB {
+	db, err := Open(DefaultOptions(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestOpenDB_SetNx(t *testing.T) {
+	db := openTestDB(t)
+
+	ok, err := db.SetNx("nx_key", "v1")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	ok, err = db.SetNx("nx_key", "v2")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	var val string
+	assert.Nil(t, db.Get("nx_key", &val))
+	assert.Equal(t, "v1", val)
+}
+
+func TestOpenDB_SetXX(t *testing.T) {
+	db := openTestDB(t)
+
+	ok, err := db.SetXX("xx_key", "v1")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	assert.False(t, db.StrExists("xx_key"))
+
+	assert.Nil(t, db.Set("xx_key", "v1"))
+	ok, err = db.SetXX("xx_key", "v2")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	var val string
+	assert.Nil(t, db.Get("xx_key", &val))
+	assert.Equal(t, "v2", val)
+}
+
+func TestOpenDB_GetSet(t *testing.T) {
+	db := openTestDB(t)
+
+	var old string
+	assert.Nil(t, db.GetSet("gs_key", "v1", &old))
+	assert.Equal(t, "", old)
+
+	assert.Nil(t, db.GetSet("gs_key", "v2", &old))
+	assert.Equal(t, "v1", old)
+
+	var val string
+	assert.Nil(t, db.Get("gs_key", &val))
+	assert.Equal(t, "v2", val)
+}
+
+func TestOpenDB_GetDel(t *testing.T) {
+	db := openTestDB(t)
+
+	var val string
+	assert.Equal(t, ErrKeyNotExist, db.GetDel("gd_key", &val))
+
+	assert.Nil(t, db.Set("gd_key", "v1"))
+	assert.Nil(t, db.GetDel("gd_key", &val))
+	assert.Equal(t, "v1", val)
+	assert.False(t, db.StrExists("gd_key"))
+}
+
+func TestOpenDB_GetEx(t *testing.T) {
+	db := openTestDB(t)
+
+	var val string
+	assert.Equal(t, ErrKeyNotExist, db.GetEx("ge_key", &val, GetExOptions{Duration: 10}))
+
+	assert.Nil(t, db.Set("ge_key", "v1"))
+	assert.Equal(t, ErrInvalidTTL, db.GetEx("ge_key", &val, GetExOptions{Duration: -1}))
+	assert.Equal(t, ErrInvalidTTL, db.GetEx("ge_key", &val, GetExOptions{Duration: 10, Persist: true}))
+
+	assert.Nil(t, db.GetEx("ge_key", &val, GetExOptions{Duration: 100}))
+	assert.Equal(t, "v1", val)
+	ttl := db.TTL("ge_key")
+	assert.True(t, ttl > 0 && ttl <= 100)
+
+	// the zero options leave the time to live unchanged.
+	val = ""
+	assert.Nil(t, db.GetEx("ge_key", &val, GetExOptions{}))
+	assert.Equal(t, "v1", val)
+	ttl = db.TTL("ge_key")
+	assert.True(t, ttl > 0 && ttl <= 100)
+
+	assert.Nil(t, db.GetEx("ge_key", &val, GetExOptions{Persist: true}))
+	assert.Equal(t, int64(0), db.TTL("ge_key"))
+}
+
+func TestOpenDB_StrReopen(t *testing.T) {
+	path := t.TempDir()
+	db, err := Open(DefaultOptions(path))
+	assert.Nil(t, err)
+
+	assert.Nil(t, db.Set("k1", "v1"))
+	assert.Nil(t, db.Set("k2", "v2"))
+	var val string
+	assert.Nil(t, db.GetDel("k2", &val))
+	assert.Nil(t, db.GetEx("k1", &val, GetExOptions{Duration: 100}))
+
+	db, err = Open(DefaultOptions(path))
+	assert.Nil(t, err)
+	assert.Nil(t, db.Get("k1", &val))
+	assert.Equal(t, "v1", val)
+	assert.False(t, db.StrExists("k2"))
+	assert.True(t, db.TTL("k1") > 0)
+}
+
+func TestOpenDB_StrLen(t *testing.T) {
+	db := openTestDB(t)
+
+	assert.Equal(t, 0, db.StrLen("len_key"))
+	assert.Nil(t, db.Set("len_key", "hello"))
+	assert.Equal(t, 5, db.StrLen("len_key"))
+}
+
+func TestOpenDB_GetRange(t *testing.T) {
+	db := openTestDB(t)
+	assert.Nil(t, db.Set("range_key", "This is a string"))
+
+	tests := []struct {
+		start, end int
+		want       string
+	}{
+		{0, 3, "This"},
+		{-3, -1, "ing"},
+		{0, -1, "This is a string"},
+		{10, 100, "string"},
+		{5, 2, ""},
+		{100, 200, ""},
+	}
+	for _, tt := range tests {
+		val, err := db.GetRange("range_key", tt.start, tt.end)
+		assert.Nil(t, err)
+		assert.Equal(t, tt.want, string(val))
+	}
+
+	val, err := db.GetRange("not_exist", 0, -1)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(val))
+}
+
+func TestOpenDB_SetRange(t *testing.T) {
+	for _, mode := range []DataIndexMode{KeyValueMemMode, KeyOnlyMemMode} {
+		path := t.TempDir()
+		opts := DefaultOptions(path)
+		opts.IdxMode = mode
+		db, err := Open(opts)
+		assert.Nil(t, err)
+
+		_, err = db.SetRange("sr_key", -1, []byte("x"))
+		assert.Equal(t, ErrInvalidOffset, err)
+		// the string can not be longer than 512MB.
+		_, err = db.SetRange("sr_key", 1<<62, []byte("x"))
+		assert.Equal(t, ErrInvalidOffset, err)
+		_, err = db.SetRange("sr_key", maxStringLength-1, []byte("xy"))
+		assert.Equal(t, ErrInvalidOffset, err)
+		assert.False(t, db.StrExists("sr_key"))
+
+		assert.Nil(t, db.Set("sr_key", "Hello World"))
+		n, err := db.SetRange("sr_key", 6, []byte("Redis"))
+		assert.Nil(t, err)
+		assert.Equal(t, 11, n)
+
+		n, err = db.SetRange("pad_key", 3, []byte("abc"))
+		assert.Nil(t, err)
+		assert.Equal(t, 6, n)
+
+		var val []byte
+		assert.Nil(t, db.Get("sr_key", &val))
+		assert.Equal(t, "Hello Redis", string(val))
+		assert.Nil(t, db.Get("pad_key", &val))
+		assert.Equal(t, []byte{0, 0, 0, 'a', 'b', 'c'}, val)
+
+		// reopen and the value should be rebuilt from the db files.
+		db, err = Open(opts)
+		assert.Nil(t, err)
+		assert.Nil(t, db.Get("sr_key", &val))
+		assert.Equal(t, "Hello Redis", string(val))
+
+		// a db written in KeyValueMemMode can also be read in KeyOnlyMemMode.
+		opts.IdxMode = KeyOnlyMemMode
+		db, err = Open(opts)
+		assert.Nil(t, err)
+		assert.Nil(t, db.Get("sr_key", &val))
+		assert.Equal(t, "Hello Redis", string(val))
+	}
+}
+
+func TestOpenDB_SetRangeExpired(t *testing.T) {
+	path := t.TempDir()
+	opts := DefaultOptions(path)
+	opts.IdxMode = KeyValueMemMode
+	db, err := Open(opts)
+	assert.Nil(t, err)
+
+	assert.Nil(t, db.Set("sr_key", "hello"))
+	var val []byte
+	assert.Nil(t, db.GetEx("sr_key", &val, GetExOptions{Duration: 1}))
+	_, err = db.SetRange("sr_key", 2, []byte("XY"))
+	assert.Nil(t, err)
+	time.Sleep(2 * time.Second)
+
+	// the overwritten part is not replayed on the expired value.
+	db, err = Open(opts)
+	assert.Nil(t, err)
+	assert.Equal(t, ErrKeyNotExist, db.Get("sr_key", &val))
+	assert.Equal(t, int64(0), db.TTL("sr_key"))
+}
+
+func TestOpenDB_Scan(t *testing.T) {
+	db := openTestDB(t)
+	for i := 0; i < 100; i++ {
+		assert.Nil(t, db.Set(fmt.Sprintf("user:%03d:profile", i), fmt.Sprintf("profile_%d", i)))
+		assert.Nil(t, db.Set(fmt.Sprintf("user:%03d:settings", i), fmt.Sprintf("settings_%d", i)))
+	}
+	assert.Nil(t, db.Set("other", "v"))
+
+	scanAll := func(match string, count int, between func()) map[string]string {
+		res := make(map[string]string)
+		var cursor []byte
+		for {
+			kvs, next, err := db.Scan(cursor, match, count)
+			assert.Nil(t, err)
+			for _, kv := range kvs {
+				_, dup := res[string(kv.Key)]
+				assert.False(t, dup, "duplicated key %s", kv.Key)
+				res[string(kv.Key)] = string(kv.Value)
+			}
+			if next == nil {
+				return res
+			}
+			cursor = next
+			if between != nil {
+				between()
+			}
+		}
+	}
+
+	res := scanAll("user:*:profile", 7, nil)
+	assert.Equal(t, 100, len(res))
+	assert.Equal(t, "profile_42", res["user:042:profile"])
+
+	assert.Equal(t, 201, len(scanAll("", 0, nil)))
+	assert.Equal(t, 0, len(scanAll("nothing*", 5, nil)))
+
+	// insert and remove keys between pages, the keys present for the whole scan must be returned.
+	n := 0
+	res = scanAll("user:*", 13, func() {
+		assert.Nil(t, db.Set(fmt.Sprintf("user:%03d:new", n), "new"))
+		assert.Nil(t, db.Remove(fmt.Sprintf("user:%03d:settings", 99-n)))
+		n++
+	})
+	for i := 0; i < 100; i++ {
+		_, ok := res[fmt.Sprintf("user:%03d:profile", i)]
+		assert.True(t, ok)
+	}
+}
+
+func TestOpenDB_RangeScan(t *testing.T) {
+	db := openTestDB(t)
+	for i := 0; i < 10; i++ {
+		assert.Nil(t, db.Set(fmt.Sprintf("key_%d", i), fmt.Sprintf("val_%d", i)))
+	}
+
+	// the start key need not to exist.
+	val, err := db.RangeScan("key_25", "key_4")
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{[]byte("val_3"), []byte("val_4")}, val)
+}
+
+func TestOpenDB_RangeScanWithOptions(t *testing.T) {
+	db := openTestDB(t)
+	for i := 0; i < 10; i++ {
+		assert.Nil(t, db.Set(fmt.Sprintf("key_%d", i), fmt.Sprintf("val_%d", i)))
+	}
+
+	tests := []struct {
+		start, end interface{}
+		opts       RangeOptions
+		want       []string
+	}{
+		{"key_2", "key_5", RangeOptions{}, []string{"key_2", "key_3", "key_4", "key_5"}},
+		{"key_2", "key_5", RangeOptions{StartExclusive: true, EndExclusive: true}, []string{"key_3", "key_4"}},
+		{"key_2", "key_5", RangeOptions{Reverse: true}, []string{"key_5", "key_4", "key_3", "key_2"}},
+		{"key_2", "key_5", RangeOptions{Reverse: true, StartExclusive: true, EndExclusive: true}, []string{"key_4", "key_3"}},
+		{nil, "key_2", RangeOptions{}, []string{"key_0", "key_1", "key_2"}},
+		{"key_7", nil, RangeOptions{}, []string{"key_7", "key_8", "key_9"}},
+		{nil, nil, RangeOptions{Limit: 2, Reverse: true}, []string{"key_9", "key_8"}},
+		{"key_45", nil, RangeOptions{Limit: 2}, []string{"key_5", "key_6"}},
+		{"key_5", "key_2", RangeOptions{}, nil},
+	}
+	for _, tt := range tests {
+		kvs, err := db.RangeScanWithOptions(tt.start, tt.end, tt.opts)
+		assert.Nil(t, err)
+		var keys []string
+		for _, kv := range kvs {
+			keys = append(keys, string(kv.Key))
+			assert.Equal(t, "val_"+string(kv.Key[4:]), string(kv.Value))
+		}
+		assert.Equal(t, tt.want, keys)
+	}
+}
+
+func TestOpenDB_RangeScanIdxMode(t *testing.T) {
+	for _, mode := range []DataIndexMode{KeyValueMemMode, KeyOnlyMemMode} {
+		opts := DefaultOptions(t.TempDir())
+		opts.IdxMode = mode
+		db, err := Open(opts)
+		assert.Nil(t, err)
+		for i := 0; i < 5; i++ {
+			assert.Nil(t, db.Set(fmt.Sprintf("key_%d", i), fmt.Sprintf("val_%d", i)))
+		}
+		_, err = db.SetRange("key_3", 4, []byte("X"))
+		assert.Nil(t, err)
+
+		// the values are the same as Get after reopening, when they are read from the db files.
+		for _, reopen := range []bool{false, true} {
+			if reopen {
+				db, err = Open(opts)
+				assert.Nil(t, err)
+			}
+			kvs, err := db.RangeScanWithOptions("key_2", "key_3", RangeOptions{})
+			assert.Nil(t, err)
+			assert.Equal(t, []KeyValue{
+				{Key: []byte("key_2"), Value: []byte("val_2")},
+				{Key: []byte("key_3"), Value: []byte("val_X")},
+			}, kvs)
+		}
+	}
+}
+
+func TestOpenDB_RangeScanNumericKeys(t *testing.T) {
+	db := openTestDB(t)
+	// int and float64 keys are both 8 bytes, so save them in different dbs.
+	fdb := openTestDB(t)
+	for i := -5; i <= 5; i++ {
+		assert.Nil(t, db.Set(i, fmt.Sprintf("val_%d", i)))
+		assert.Nil(t, fdb.Set(float64(i)/2, fmt.Sprintf("fval_%d", i)))
+	}
+
+	val, err := db.RangeScan(-2, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{[]byte("val_-2"), []byte("val_-1"), []byte("val_0"), []byte("val_1")}, val)
+
+	val, err = fdb.RangeScan(-1.0, 0.5)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{[]byte("fval_-2"), []byte("fval_-1"), []byte("fval_0"), []byte("fval_1")}, val)
+
+	db = openTestDB(t)
+	for _, id := range []int{-1, 2, 10} {
+		assert.Nil(t, db.Set(util.Tuple{"user", id, "name"}, fmt.Sprintf("name_%d", id)))
+		assert.Nil(t, db.Set(util.Tuple{"user", id, "age"}, fmt.Sprintf("age_%d", id)))
+	}
+	kvs, err := db.RangeScanWithOptions(util.Tuple{"user", -1}, util.Tuple{"user", 10}, RangeOptions{})
+	assert.Nil(t, err)
+	var keys []util.Tuple
+	for _, kv := range kvs {
+		var key util.Tuple
+		assert.Nil(t, util.DecodeKey(kv.Key, &key))
+		keys = append(keys, key)
+	}
+	assert.Equal(t, []util.Tuple{
+		{"user", int64(-1), "age"}, {"user", int64(-1), "name"},
+		{"user", int64(2), "age"}, {"user", int64(2), "name"},
+	}, keys)
+}
+
+func TestOpenDB_Codec(t *testing.T) {
+	type user struct {
+		Name string
+		Age  int
+	}
+	path := t.TempDir()
+	opts := DefaultOptions(path)
+	opts.Codec = util.JSONCodec
+	db, err := Open(opts)
+	assert.Nil(t, err)
+	assert.Nil(t, db.Set("user", user{Name: "lily", Age: 18}))
+
+	raw, err := db.getVal([]byte("user"))
+	assert.Nil(t, err)
+	assert.Equal(t, `{"Name":"lily","Age":18}`, string(raw))
+
+	// the db can not be opened by another codec.
+	_, err = Open(DefaultOptions(path))
+	assert.Equal(t, ErrCodecMismatch, err)
+
+	db2, err := Open(opts)
+	assert.Nil(t, err)
+	var u user
+	assert.Nil(t, db2.Get("user", &u))
+	assert.Equal(t, user{Name: "lily", Age: 18}, u)
+}
+
+func TestOpenDB_CodecOfExistingDB(t *testing.T) {
+	path := t.TempDir()
+	db, err := Open(DefaultOptions(path))
+	assert.Nil(t, err)
+	assert.Nil(t, db.Set("k", 1))
+
+	// a db written before the codec is recorded is msgpack.
+	assert.Nil(t, os.Remove(filepath.Join(path, codecFileName)))
+	opts := DefaultOptions(path)
+	opts.Codec = util.GobCodec
+	_, err = Open(opts)
+	assert.Equal(t, ErrCodecMismatch, err)
+
+	_, err = Open(DefaultOptions(path))
+	assert.Nil(t, err)
+}
+
+func TestOpenDB_KeyEncoding(t *testing.T) {
+	path := t.TempDir()
+	db, err := Open(DefaultOptions(path))
+	assert.Nil(t, err)
+	assert.False(t, db.legacyKeys)
+
+	// write the numeric keys as a db before the keys are order-preserving, which records neither the codec nor the key encoding.
+	db.legacyKeys = true
+	assert.Nil(t, db.Set(-1, "neg"))
+	assert.Nil(t, db.Set(int16(3), "short"))
+	assert.Nil(t, os.Remove(filepath.Join(path, codecFileName)))
+	assert.Nil(t, os.Remove(filepath.Join(path, keyEncodingFileName)))
+
+	// the numeric keys are still reachable.
+	for i := 0; i < 2; i++ {
+		db, err = Open(DefaultOptions(path))
+		assert.Nil(t, err)
+		assert.True(t, db.legacyKeys)
+		var val string
+		assert.Nil(t, db.Get(-1, &val))
+		assert.Equal(t, "neg", val)
+		assert.Nil(t, db.Get(int16(3), &val))
+		assert.Equal(t, "short", val)
+		assert.NotNil(t, db.strIndex.idxList.Get([]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}))
+	}
+
+	// a db with the codec recorded is written with the order-preserving keys.
+	path = t.TempDir()
+	db, err = Open(DefaultOptions(path))
+	assert.Nil(t, err)
+	assert.Nil(t, db.Set(-1, "neg"))
+	assert.Nil(t, os.Remove(filepath.Join(path, keyEncodingFileName)))
+	db, err = Open(DefaultOptions(path))
+	assert.Nil(t, err)
+	assert.False(t, db.legacyKeys)
+	var val string
+	assert.Nil(t, db.Get(-1, &val))
+	assert.Equal(t, "neg", val)
+
+	// an unknown key encoding is refused.
+	assert.Nil(t, os.WriteFile(filepath.Join(path, keyEncodingFileName), []byte("2"), 0644))
+	_, err = Open(DefaultOptions(path))
+	assert.Equal(t, ErrKeyEncodingMismatch, err)
+}
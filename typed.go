@@ -0,0 +1,68 @@
+package opendb
+
+import "opendb/util"
+
+// ZMember a member of the sorted set with its score, the member is decoded into T.
+type ZMember[T any] struct {
+	Member T
+	Score  float64
+}
+
+// GetAs get the value of key decoded into T. If the key does not exist an error is returned.
+//	u, err := opendb.GetAs[User](db, "user:1")
+func GetAs[T any](db *OpenDB, key interface{}) (T, error) {
+	var val T
+	err := db.Get(key, &val)
+	return val, err
+}
+
+// SetTyped set key to hold the value of T, the value is encoded by the codec of the db.
+func SetTyped[T any](db *OpenDB, key interface{}, value T) error {
+	return db.Set(key, value)
+}
+
+// HGetAs returns the value associated with field in the hash stored at key decoded into T.
+// If the key or the field does not exist, ErrKeyNotExist is returned.
+func HGetAs[T any](db *OpenDB, key, field []byte) (T, error) {
+	var val T
+	raw, ok := db.hGet(key, field)
+	if !ok {
+		return val, ErrKeyNotExist
+	}
+	err := util.DecodeValueWith(db.opts.Codec, raw, &val)
+	return val, err
+}
+
+// HSetTyped sets field in the hash stored at key to the value of T, the value is encoded by the codec of the db.
+// Return num of elements in hash of the specified key.
+func HSetTyped[T any](db *OpenDB, key, field []byte, value T) (int, error) {
+	encVal, err := util.EncodeValueWith(db.opts.Codec, value)
+	if err != nil {
+		return 0, err
+	}
+	return db.HSet(key, field, encVal)
+}
+
+// ZRangeAs returns the specified range of members with scores in the sorted set stored at key, the members are decoded into T.
+func ZRangeAs[T any](db *OpenDB, key []byte, start, stop int) ([]ZMember[T], error) {
+	return decodeZMembers[T](db, db.ZRangeWithScores(key, start, stop))
+}
+
+// ZRevRangeAs same as ZRangeAs, but the members are ordered from the highest to the lowest score.
+func ZRevRangeAs[T any](db *OpenDB, key []byte, start, stop int) ([]ZMember[T], error) {
+	return decodeZMembers[T](db, db.ZRevRangeWithScores(key, start, stop))
+}
+
+// decode the members and scores, every member is followed by its score in vals.
+func decodeZMembers[T any](db *OpenDB, vals []interface{}) ([]ZMember[T], error) {
+	members := make([]ZMember[T], 0, len(vals)/2)
+	for i := 0; i+1 < len(vals); i += 2 {
+		var m ZMember[T]
+		if err := util.DecodeValueWith(db.opts.Codec, []byte(vals[i].(string)), &m.Member); err != nil {
+			return nil, err
+		}
+		m.Score = vals[i+1].(float64)
+		members = append(members, m)
+	}
+	return members, nil
+}
@@ -0,0 +1,80 @@
+package opendb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type typedUser struct {
+	Name string
+	Age  int
+}
+
+func TestGetAs(t *testing.T) {
+	db := openTestDB(t)
+
+	assert.Nil(t, SetTyped(db, "user", typedUser{Name: "lily", Age: 18}))
+	u, err := GetAs[typedUser](db, "user")
+	assert.Nil(t, err)
+	assert.Equal(t, typedUser{Name: "lily", Age: 18}, u)
+
+	assert.Nil(t, SetTyped(db, "count", 42))
+	n, err := GetAs[int](db, "count")
+	assert.Nil(t, err)
+	assert.Equal(t, 42, n)
+
+	assert.Nil(t, SetTyped(db, "name", "opendb"))
+	s, err := GetAs[string](db, "name")
+	assert.Nil(t, err)
+	assert.Equal(t, "opendb", s)
+
+	_, err = GetAs[int](db, "missing")
+	assert.Equal(t, ErrKeyNotExist, err)
+}
+
+func TestHGetAs(t *testing.T) {
+	db := openTestDB(t)
+
+	_, err := HSetTyped(db, []byte("users"), []byte("1"), typedUser{Name: "lily", Age: 18})
+	assert.Nil(t, err)
+	u, err := HGetAs[typedUser](db, []byte("users"), []byte("1"))
+	assert.Nil(t, err)
+	assert.Equal(t, typedUser{Name: "lily", Age: 18}, u)
+
+	_, err = db.HSet([]byte("users"), []byte("raw"), []byte("v"))
+	assert.Nil(t, err)
+	raw, err := HGetAs[string](db, []byte("users"), []byte("raw"))
+	assert.Nil(t, err)
+	assert.Equal(t, "v", raw)
+
+	// an empty value is not a missing field.
+	_, err = db.HSet([]byte("users"), []byte("empty"), []byte{})
+	assert.Nil(t, err)
+	raw, err = HGetAs[string](db, []byte("users"), []byte("empty"))
+	assert.Nil(t, err)
+	assert.Equal(t, "", raw)
+
+	_, err = HGetAs[typedUser](db, []byte("users"), []byte("2"))
+	assert.Equal(t, ErrKeyNotExist, err)
+}
+
+func TestZRangeAs(t *testing.T) {
+	db := openTestDB(t)
+
+	assert.Nil(t, db.ZAdd([]byte("rank"), 3, []byte("c")))
+	assert.Nil(t, db.ZAdd([]byte("rank"), 1, []byte("a")))
+	assert.Nil(t, db.ZAdd([]byte("rank"), 2, []byte("b")))
+
+	members, err := ZRangeAs[string](db, []byte("rank"), 0, -1)
+	assert.Nil(t, err)
+	assert.Equal(t, []ZMember[string]{{"a", 1}, {"b", 2}, {"c", 3}}, members)
+
+	members, err = ZRevRangeAs[string](db, []byte("rank"), 0, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, []ZMember[string]{{"c", 3}, {"b", 2}}, members)
+
+	members, err = ZRangeAs[string](db, []byte("missing"), 0, -1)
+	assert.Nil(t, err)
+	assert.Empty(t, members)
+}
@@ -1,64 +1,480 @@
 package util
 
 import (
-"bytes"
-"encoding/binary"
-"github.com/vmihailenco/msgpack/v5"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"math"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Keys are encoded in an order-preserving way, so the encoded keys compare the same as the original values:
+//	[]byte, string  the raw bytes.
+//	bool            one byte, 0 for false and 1 for true.
+//	uint family     big-endian with the width of the type, uint is 8 bytes.
+//	int family      big-endian with the width of the type and the sign bit flipped, int is 8 bytes,
+//	                so negative numbers sort before positive ones.
+//	float32/64      big-endian IEEE 754 bits, the sign bit is flipped for positive numbers and all the bits are
+//	                flipped for negative numbers, -0 is encoded as 0 and every NaN is encoded as the same
+//	                positive NaN, which sorts after +Inf.
+//	Tuple           see Tuple.
+// Other types are encoded by msgpack, which does not preserve the order.
+
+// Tuple is a composite key made up of multiple elements, such as Tuple{"user", 1001, "profile"}.
+// Tuples are compared element by element, and a tuple sorts before the longer tuples it is the prefix of.
+// Every element is encoded with a type tag followed by the value, so elements of different types are ordered by type:
+//
+//	[]byte < string < signed integers < unsigned integers < floats < bool
+//
+// All signed integers are encoded as int64 and all unsigned integers as uint64, so they are decoded as int64 and uint64.
+type Tuple []interface{}
+
+// the type tags of the tuple elements.
+const (
+	tupleBytes byte = iota + 1
+	tupleString
+	tupleInt
+	tupleUint
+	tupleFloat
+	tupleBool
+)
+
+var (
+	// ErrInvalidKey the key can not be decoded to the dest.
+	ErrInvalidKey = errors.New("util: invalid encoded key")
+
+	// ErrUnsupportedTupleElem the type of the tuple element is not supported.
+	ErrUnsupportedTupleElem = errors.New("util: unsupported tuple element type")
 )
 
 // EncodeKey returns key in bytes.
 func EncodeKey(key interface{}) (res []byte, err error) {
-	switch key.(type) {
+	switch k := key.(type) {
 	case []byte:
-		return key.([]byte), nil
-	case bool, float32, float64, complex64, complex128, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return k, nil
+	case string:
+		return []byte(k), nil
+	case bool:
+		if k {
+			return []byte{1}, nil
+		}
+		return []byte{0}, nil
+	case int:
+		return encodeUint(uint64(k)^(1<<63), 8), nil
+	case int8:
+		return encodeUint(uint64(uint8(k)^(1<<7)), 1), nil
+	case int16:
+		return encodeUint(uint64(uint16(k)^(1<<15)), 2), nil
+	case int32:
+		return encodeUint(uint64(uint32(k)^(1<<31)), 4), nil
+	case int64:
+		return encodeUint(uint64(k)^(1<<63), 8), nil
+	case uint:
+		return encodeUint(uint64(k), 8), nil
+	case uint8:
+		return []byte{k}, nil
+	case uint16:
+		return encodeUint(uint64(k), 2), nil
+	case uint32:
+		return encodeUint(uint64(k), 4), nil
+	case uint64:
+		return encodeUint(k, 8), nil
+	case float32:
+		return encodeUint(uint64(float32ToOrdered(k)), 4), nil
+	case float64:
+		return encodeUint(float64ToOrdered(k), 8), nil
+	case Tuple:
+		return encodeTuple(k)
+	case complex64, complex128:
 		buf := new(bytes.Buffer)
 		err = binary.Write(buf, binary.BigEndian, key)
 		return buf.Bytes(), err
-	case int:
-		val := uint64(key.(int))
-		p := make([]byte, 8)
-		p[0] = byte(val >> 56)
-		p[1] = byte(val >> 48)
-		p[2] = byte(val >> 40)
-		p[3] = byte(val >> 32)
-		p[4] = byte(val >> 24)
-		p[5] = byte(val >> 16)
-		p[6] = byte(val >> 8)
-		p[7] = byte(val)
-		return p, err
+	default:
+		res, err = msgpack.Marshal(key)
+		return
+	}
+}
+
+// EncodeKeyLegacy returns key in bytes as the dbs written before EncodeKey is order-preserving,
+// numbers are big-endian in their two's complement or IEEE 754 bits, and int is 8 bytes.
+func EncodeKeyLegacy(key interface{}) (res []byte, err error) {
+	switch k := key.(type) {
+	case []byte:
+		return k, nil
 	case string:
-		return []byte(key.(string)), nil
+		return []byte(k), nil
+	case int:
+		return encodeUint(uint64(k), 8), nil
+	case bool, float32, float64, complex64, complex128, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		buf := new(bytes.Buffer)
+		err = binary.Write(buf, binary.BigEndian, key)
+		return buf.Bytes(), err
 	default:
 		res, err = msgpack.Marshal(key)
 		return
 	}
 }
 
-// EncodeValue returns value in bytes.
+// DecodeKey decode the key returned by EncodeKey to dest, dest must be a pointer to the type of the original key.
+func DecodeKey(key []byte, dest interface{}) error {
+	switch d := dest.(type) {
+	case *[]byte:
+		*d = key
+	case *string:
+		*d = string(key)
+	case *bool:
+		if len(key) != 1 {
+			return ErrInvalidKey
+		}
+		*d = key[0] == 1
+	case *int:
+		v, err := decodeUint(key, 8)
+		*d = int(v ^ (1 << 63))
+		return err
+	case *int8:
+		v, err := decodeUint(key, 1)
+		*d = int8(uint8(v) ^ (1 << 7))
+		return err
+	case *int16:
+		v, err := decodeUint(key, 2)
+		*d = int16(uint16(v) ^ (1 << 15))
+		return err
+	case *int32:
+		v, err := decodeUint(key, 4)
+		*d = int32(uint32(v) ^ (1 << 31))
+		return err
+	case *int64:
+		v, err := decodeUint(key, 8)
+		*d = int64(v ^ (1 << 63))
+		return err
+	case *uint:
+		v, err := decodeUint(key, 8)
+		*d = uint(v)
+		return err
+	case *uint8:
+		v, err := decodeUint(key, 1)
+		*d = uint8(v)
+		return err
+	case *uint16:
+		v, err := decodeUint(key, 2)
+		*d = uint16(v)
+		return err
+	case *uint32:
+		v, err := decodeUint(key, 4)
+		*d = uint32(v)
+		return err
+	case *uint64:
+		v, err := decodeUint(key, 8)
+		*d = v
+		return err
+	case *float32:
+		v, err := decodeUint(key, 4)
+		*d = orderedToFloat32(uint32(v))
+		return err
+	case *float64:
+		v, err := decodeUint(key, 8)
+		*d = orderedToFloat64(v)
+		return err
+	case *Tuple:
+		t, err := decodeTuple(key)
+		*d = t
+		return err
+	case *complex64, *complex128:
+		return binary.Read(bytes.NewReader(key), binary.BigEndian, dest)
+	default:
+		return msgpack.Unmarshal(key, dest)
+	}
+	return nil
+}
+
+// Codec encodes and decodes the values which are not []byte or string.
+type Codec interface {
+	// Name the unique name of the codec, it is recorded in the db, so a db is always decoded by the codec it is written by.
+	Name() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+type (
+	msgpackCodec struct{}
+	jsonCodec    struct{}
+	gobCodec     struct{}
+)
+
+var (
+	// MsgpackCodec encodes values by msgpack, it is the default codec.
+	MsgpackCodec Codec = msgpackCodec{}
+	// JSONCodec encodes values by encoding/json, which is readable by external tools.
+	JSONCodec Codec = jsonCodec{}
+	// GobCodec encodes values by encoding/gob.
+	GobCodec Codec = gobCodec{}
+)
+
+func (msgpackCodec) Name() string                               { return "msgpack" }
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error)      { return msgpack.Marshal(v) }
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error { return msgpack.Unmarshal(data, v) }
+
+func (jsonCodec) Name() string                               { return "json" }
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+func (gobCodec) Name() string { return "gob" }
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// EncodeValue returns value in bytes, values which are not []byte or string are encoded by msgpack.
 func EncodeValue(value interface{}) (res []byte, err error) {
+	return EncodeValueWith(MsgpackCodec, value)
+}
+
+// DecodeValue decode value to dest, values which are not []byte or string are decoded by msgpack.
+func DecodeValue(value []byte, dest interface{}) (err error) {
+	return DecodeValueWith(MsgpackCodec, value, dest)
+}
+
+// EncodeValueWith returns value in bytes, values which are not []byte or string are encoded by the codec.
+func EncodeValueWith(codec Codec, value interface{}) (res []byte, err error) {
 	switch value.(type) {
 	case []byte:
 		return value.([]byte), nil
 	case string:
 		return []byte(value.(string)), err
 	default:
-		res, err = msgpack.Marshal(value)
+		res, err = codec.Marshal(value)
 		return
 	}
 }
 
-// DecodeValue decode value to dest.
-func DecodeValue(value []byte, dest interface{}) (err error) {
+// DecodeValueWith decode value to dest, values which are not []byte or string are decoded by the codec.
+func DecodeValueWith(codec Codec, value []byte, dest interface{}) (err error) {
 	switch dest.(type) {
 	case *[]byte:
 		*dest.(*[]byte) = value
 	case *string:
 		*dest.(*string) = string(value)
 	default:
-		err = msgpack.Unmarshal(value, dest)
+		err = codec.Unmarshal(value, dest)
 		return
 	}
 	return
 }
 
+func encodeUint(v uint64, width int) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, v)
+	return buf[8-width:]
+}
+
+func decodeUint(key []byte, width int) (uint64, error) {
+	if len(key) != width {
+		return 0, ErrInvalidKey
+	}
+	buf := make([]byte, 8)
+	copy(buf[8-width:], key)
+	return binary.BigEndian.Uint64(buf), nil
+}
+
+func float64ToOrdered(f float64) uint64 {
+	if f == 0 {
+		// -0 and 0 are equal.
+		return 1 << 63
+	}
+	if math.IsNaN(f) {
+		// a NaN may have the sign bit set, which would sort it before -Inf.
+		f = math.NaN()
+	}
+	b := math.Float64bits(f)
+	if b>>63 == 1 {
+		return ^b
+	}
+	return b | 1<<63
+}
+
+func orderedToFloat64(b uint64) float64 {
+	if b>>63 == 1 {
+		return math.Float64frombits(b &^ (1 << 63))
+	}
+	return math.Float64frombits(^b)
+}
+
+func float32ToOrdered(f float32) uint32 {
+	if f == 0 {
+		return 1 << 31
+	}
+	if f != f {
+		f = math.Float32frombits(0x7fc00000)
+	}
+	b := math.Float32bits(f)
+	if b>>31 == 1 {
+		return ^b
+	}
+	return b | 1<<31
+}
+
+func orderedToFloat32(b uint32) float32 {
+	if b>>31 == 1 {
+		return math.Float32frombits(b &^ (1 << 31))
+	}
+	return math.Float32frombits(^b)
+}
+
+// encode the tuple, []byte and string elements are escaped(0x00 to 0x00 0xff) and terminated by 0x00 0x01,
+// so a shorter element sorts before the longer ones it is the prefix of.
+func encodeTuple(t Tuple) ([]byte, error) {
+	var buf bytes.Buffer
+	writeEscaped := func(b []byte) {
+		for _, c := range b {
+			buf.WriteByte(c)
+			if c == 0x00 {
+				buf.WriteByte(0xff)
+			}
+		}
+		buf.Write([]byte{0x00, 0x01})
+	}
+
+	for _, elem := range t {
+		switch e := elem.(type) {
+		case []byte:
+			buf.WriteByte(tupleBytes)
+			writeEscaped(e)
+		case string:
+			buf.WriteByte(tupleString)
+			writeEscaped([]byte(e))
+		case int, int8, int16, int32, int64:
+			buf.WriteByte(tupleInt)
+			buf.Write(encodeUint(uint64(toInt64(e))^(1<<63), 8))
+		case uint, uint8, uint16, uint32, uint64:
+			buf.WriteByte(tupleUint)
+			buf.Write(encodeUint(toUint64(e), 8))
+		case float32:
+			buf.WriteByte(tupleFloat)
+			buf.Write(encodeUint(float64ToOrdered(float64(e)), 8))
+		case float64:
+			buf.WriteByte(tupleFloat)
+			buf.Write(encodeUint(float64ToOrdered(e), 8))
+		case bool:
+			buf.WriteByte(tupleBool)
+			if e {
+				buf.WriteByte(1)
+			} else {
+				buf.WriteByte(0)
+			}
+		default:
+			return nil, ErrUnsupportedTupleElem
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeTuple(key []byte) (Tuple, error) {
+	t := make(Tuple, 0)
+	readEscaped := func() ([]byte, bool) {
+		res := make([]byte, 0)
+		for i := 0; i+1 < len(key); i++ {
+			if key[i] != 0x00 {
+				res = append(res, key[i])
+				continue
+			}
+			switch key[i+1] {
+			case 0xff:
+				res = append(res, 0x00)
+				i++
+			case 0x01:
+				key = key[i+2:]
+				return res, true
+			default:
+				return nil, false
+			}
+		}
+		return nil, false
+	}
+	readFixed := func(n int) ([]byte, bool) {
+		if len(key) < n {
+			return nil, false
+		}
+		b := key[:n]
+		key = key[n:]
+		return b, true
+	}
+
+	for len(key) > 0 {
+		tag := key[0]
+		key = key[1:]
+		switch tag {
+		case tupleBytes, tupleString:
+			b, ok := readEscaped()
+			if !ok {
+				return nil, ErrInvalidKey
+			}
+			if tag == tupleString {
+				t = append(t, string(b))
+			} else {
+				t = append(t, b)
+			}
+		case tupleInt, tupleUint, tupleFloat:
+			b, ok := readFixed(8)
+			if !ok {
+				return nil, ErrInvalidKey
+			}
+			v := binary.BigEndian.Uint64(b)
+			switch tag {
+			case tupleInt:
+				t = append(t, int64(v^(1<<63)))
+			case tupleUint:
+				t = append(t, v)
+			default:
+				t = append(t, orderedToFloat64(v))
+			}
+		case tupleBool:
+			b, ok := readFixed(1)
+			if !ok {
+				return nil, ErrInvalidKey
+			}
+			t = append(t, b[0] == 1)
+		default:
+			return nil, ErrInvalidKey
+		}
+	}
+	return t, nil
+}
+
+func toInt64(v interface{}) int64 {
+	switch n := v.(type) {
+	case int:
+		return int64(n)
+	case int8:
+		return int64(n)
+	case int16:
+		return int64(n)
+	case int32:
+		return int64(n)
+	}
+	return v.(int64)
+}
+
+func toUint64(v interface{}) uint64 {
+	switch n := v.(type) {
+	case uint:
+		return uint64(n)
+	case uint8:
+		return uint64(n)
+	case uint16:
+		return uint64(n)
+	case uint32:
+		return uint64(n)
+	}
+	return v.(uint64)
+}
@@ -0,0 +1,155 @@
+package util
+
+import (
+	"bytes"
+	"math"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// assert that the encoded keys are in the same order as the values.
+func assertOrdered(t *testing.T, values []interface{}) {
+	var prev []byte
+	for i, v := range values {
+		enc, err := EncodeKey(v)
+		assert.Nil(t, err)
+		if i > 0 {
+			assert.True(t, bytes.Compare(prev, enc) < 0, "%v should sort before %v", values[i-1], v)
+		}
+		prev = enc
+	}
+}
+
+func TestEncodeKey_Order(t *testing.T) {
+	assertOrdered(t, []interface{}{math.MinInt64, -1000, -1, 0, 1, 1000, math.MaxInt64})
+	assertOrdered(t, []interface{}{int8(math.MinInt8), int8(-1), int8(0), int8(1), int8(math.MaxInt8)})
+	assertOrdered(t, []interface{}{int16(-300), int16(-1), int16(0), int16(300)})
+	assertOrdered(t, []interface{}{int32(-70000), int32(-1), int32(0), int32(70000)})
+	assertOrdered(t, []interface{}{uint(0), uint(1), uint(math.MaxUint64)})
+	assertOrdered(t, []interface{}{math.Inf(-1), -math.MaxFloat64, -1.5, -math.SmallestNonzeroFloat64, 0.0,
+		math.SmallestNonzeroFloat64, 1.5, math.MaxFloat64, math.Inf(1)})
+	assertOrdered(t, []interface{}{float32(-2.5), float32(-1), float32(0), float32(0.5), float32(3)})
+	assertOrdered(t, []interface{}{
+		Tuple{"user", -1},
+		Tuple{"user", 1},
+		Tuple{"user", 1, "a"},
+		Tuple{"user", 1, "b"},
+		Tuple{"user", 2},
+		Tuple{"user\x00"},
+		Tuple{"users"},
+	})
+}
+
+func TestEncodeKey_NaN(t *testing.T) {
+	// every NaN is encoded the same and sorts after +Inf, even the ones with the sign bit set.
+	negNaN := math.Float64frombits(math.Float64bits(math.NaN()) | 1<<63)
+	enc, _ := EncodeKey(math.NaN())
+	negEnc, _ := EncodeKey(negNaN)
+	assert.Equal(t, enc, negEnc)
+	assertOrdered(t, []interface{}{math.Inf(1), negNaN})
+
+	negNaN32 := math.Float32frombits(0xffc00000)
+	enc, _ = EncodeKey(float32(math.NaN()))
+	negEnc, _ = EncodeKey(negNaN32)
+	assert.Equal(t, enc, negEnc)
+	assertOrdered(t, []interface{}{float32(math.Inf(1)), negNaN32})
+}
+
+func TestEncodeKeyLegacy(t *testing.T) {
+	enc, err := EncodeKeyLegacy(-1)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, enc)
+	enc, _ = EncodeKeyLegacy(int16(1))
+	assert.Equal(t, []byte{0, 1}, enc)
+	enc, _ = EncodeKeyLegacy(1.5)
+	assert.Equal(t, []byte{0x3f, 0xf8, 0, 0, 0, 0, 0, 0}, enc)
+	enc, _ = EncodeKeyLegacy("key")
+	assert.Equal(t, []byte("key"), enc)
+}
+
+func TestEncodeKey_SortInts(t *testing.T) {
+	values := []int{5, -3, 100, -100, 0, 42, -1}
+	encoded := make([][]byte, 0)
+	for _, v := range values {
+		enc, _ := EncodeKey(v)
+		encoded = append(encoded, enc)
+	}
+	sort.Slice(encoded, func(i, j int) bool { return bytes.Compare(encoded[i], encoded[j]) < 0 })
+	sort.Ints(values)
+	for i, enc := range encoded {
+		var v int
+		assert.Nil(t, DecodeKey(enc, &v))
+		assert.Equal(t, values[i], v)
+	}
+}
+
+func TestDecodeKey(t *testing.T) {
+	roundTrip := func(v, dest interface{}) {
+		enc, err := EncodeKey(v)
+		assert.Nil(t, err)
+		assert.Nil(t, DecodeKey(enc, dest))
+	}
+
+	var i int
+	roundTrip(-42, &i)
+	assert.Equal(t, -42, i)
+	var i8 int8
+	roundTrip(int8(-8), &i8)
+	assert.Equal(t, int8(-8), i8)
+	var i32 int32
+	roundTrip(int32(-32), &i32)
+	assert.Equal(t, int32(-32), i32)
+	var u16 uint16
+	roundTrip(uint16(16), &u16)
+	assert.Equal(t, uint16(16), u16)
+	var f float64
+	roundTrip(-3.25, &f)
+	assert.Equal(t, -3.25, f)
+	roundTrip(math.Inf(-1), &f)
+	assert.True(t, math.IsInf(f, -1))
+	var f32 float32
+	roundTrip(float32(-0.5), &f32)
+	assert.Equal(t, float32(-0.5), f32)
+	var b bool
+	roundTrip(true, &b)
+	assert.True(t, b)
+	var s string
+	roundTrip("hello", &s)
+	assert.Equal(t, "hello", s)
+
+	var tuple Tuple
+	roundTrip(Tuple{"user", []byte{0, 1, 0}, -7, uint8(7), 1.5, false}, &tuple)
+	assert.Equal(t, Tuple{"user", []byte{0, 1, 0}, int64(-7), uint64(7), 1.5, false}, tuple)
+
+	assert.Equal(t, ErrInvalidKey, DecodeKey([]byte{1, 2}, &i))
+	assert.Equal(t, ErrInvalidKey, DecodeKey([]byte{tupleString, 'a'}, &tuple))
+
+	_, err := EncodeKey(Tuple{struct{}{}})
+	assert.Equal(t, ErrUnsupportedTupleElem, err)
+}
+
+func TestCodec_RoundTrip(t *testing.T) {
+	type user struct {
+		Name string
+		Age  int
+	}
+	for _, codec := range []Codec{MsgpackCodec, JSONCodec, GobCodec} {
+		data, err := EncodeValueWith(codec, user{Name: "lily", Age: 18})
+		assert.Nil(t, err, codec.Name())
+
+		var u user
+		assert.Nil(t, DecodeValueWith(codec, data, &u), codec.Name())
+		assert.Equal(t, user{Name: "lily", Age: 18}, u, codec.Name())
+
+		// []byte and string are stored as they are.
+		data, err = EncodeValueWith(codec, "raw")
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("raw"), data)
+	}
+
+	data, err := EncodeValueWith(JSONCodec, map[string]int{"a": 1})
+	assert.Nil(t, err)
+	assert.Equal(t, `{"a":1}`, string(data))
+}
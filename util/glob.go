@@ -0,0 +1,105 @@
+package util
+
+// GlobMatch reports whether str matches the glob-style pattern, same as the MATCH option of redis.
+// Supported patterns:
+//	?       matches any single character
+//	*       matches any sequence of characters, including the empty sequence
+//	[abc]   matches one character given in the bracket
+//	[^abc]  matches one character not given in the bracket
+//	[a-z]   matches one character in the range
+//	\x      escapes the special character x
+func GlobMatch(pattern, str string) bool {
+	px, sx := 0, 0
+	// the position of the last star and the position in str it is matched up to,
+	// only the last star needs to match more characters on a mismatch, so the time is O(len(pattern)*len(str)).
+	starPx, starSx := -1, 0
+	for px < len(pattern) || sx < len(str) {
+		if px < len(pattern) {
+			if pattern[px] == '*' {
+				starPx, starSx = px, sx
+				px++
+				continue
+			}
+			if sx < len(str) {
+				if n, ok := matchChar(pattern[px:], str[sx]); ok {
+					px += n
+					sx++
+					continue
+				}
+			}
+		}
+		if starPx >= 0 && starSx < len(str) {
+			starSx++
+			px, sx = starPx+1, starSx
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// matchChar reports whether c matches the first element of the pattern, which is not a star,
+// and returns the length of the element in the pattern.
+func matchChar(pattern string, c byte) (int, bool) {
+	switch pattern[0] {
+	case '?':
+		return 1, true
+	case '[':
+		i := 1
+		not := i < len(pattern) && pattern[i] == '^'
+		if not {
+			i++
+		}
+
+		match := false
+		for ; i < len(pattern) && pattern[i] != ']'; i++ {
+			switch {
+			case pattern[i] == '\\' && i+1 < len(pattern):
+				i++
+				if pattern[i] == c {
+					match = true
+				}
+			case i+2 < len(pattern) && pattern[i+1] == '-' && pattern[i+2] != ']':
+				start, end := pattern[i], pattern[i+2]
+				if start > end {
+					start, end = end, start
+				}
+				if c >= start && c <= end {
+					match = true
+				}
+				i += 2
+			default:
+				if pattern[i] == c {
+					match = true
+				}
+			}
+		}
+		// the bracket is treated as closed at the end of the pattern.
+		if i < len(pattern) {
+			i++
+		}
+		return i, match != not
+	case '\\':
+		if len(pattern) >= 2 {
+			return 2, pattern[1] == c
+		}
+	}
+	return 1, pattern[0] == c
+}
+
+// GlobPrefix returns the literal prefix of the glob-style pattern, all the matched strings have the prefix.
+func GlobPrefix(pattern string) string {
+	prefix := make([]byte, 0, len(pattern))
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '*', '?', '[':
+			return string(prefix)
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+			}
+		}
+		prefix = append(prefix, pattern[i])
+	}
+	return string(prefix)
+}
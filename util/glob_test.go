@@ -0,0 +1,61 @@
+package util
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern, str string
+		want         bool
+	}{
+		{"*", "", true},
+		{"*", "anything", true},
+		{"user:*:profile", "user:1001:profile", true},
+		{"user:*:profile", "user:1001:settings", false},
+		{"user:*:profile", "user::profile", true},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-c]llo", "hbllo", true},
+		{"h[a-c]llo", "hdllo", false},
+		{"h\\*llo", "h*llo", true},
+		{"h\\*llo", "hello", false},
+		{"a*b*c", "aXXbYYc", true},
+		{"a*b*c", "aXXbYY", false},
+		{"abc", "abcd", false},
+		{"*[0-9]", "key9", true},
+		{"*?c", "abc", true},
+		{"*a", "ba", true},
+		{"a*", "b", false},
+		{"h[ab", "ha", true},
+		{"h[ab", "hax", false},
+		{"h\\", "h\\", true},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, GlobMatch(tt.pattern, tt.str), "pattern: %s, str: %s", tt.pattern, tt.str)
+	}
+}
+
+func TestGlobMatch_Stars(t *testing.T) {
+	// the stars do not backtrack exponentially.
+	str := strings.Repeat("a", 10000)
+	start := time.Now()
+	assert.False(t, GlobMatch("a*a*a*a*a*a*a*a*b", str))
+	assert.True(t, GlobMatch("a*a*a*a*a*a*a*a*a", str))
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+func TestGlobPrefix(t *testing.T) {
+	assert.Equal(t, "user:", GlobPrefix("user:*:profile"))
+	assert.Equal(t, "abc", GlobPrefix("abc"))
+	assert.Equal(t, "a*b", GlobPrefix("a\\*b?"))
+	assert.Equal(t, "", GlobPrefix("[ab]c"))
+}
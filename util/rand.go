@@ -0,0 +1,26 @@
+package util
+
+import "math/rand"
+
+// RandDistinct returns min(n, count) distinct random integers in [0, n).
+// It is a Fisher-Yates shuffle which only records the swapped positions, so it takes O(count) instead of O(n).
+func RandDistinct(n, count int) []int {
+	if count > n {
+		count = n
+	}
+	swapped := make(map[int]int, count)
+	at := func(i int) int {
+		if v, ok := swapped[i]; ok {
+			return v
+		}
+		return i
+	}
+
+	res := make([]int, count)
+	for i := 0; i < count; i++ {
+		j := i + rand.Intn(n-i)
+		res[i] = at(j)
+		swapped[j] = at(i)
+	}
+	return res
+}
@@ -0,0 +1,26 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRandDistinct(t *testing.T) {
+	assert.Empty(t, RandDistinct(0, 3))
+	assert.Equal(t, []int{0}, RandDistinct(1, 3))
+
+	seen := make(map[int]bool)
+	for _, v := range RandDistinct(1000, 1000) {
+		assert.True(t, v >= 0 && v < 1000)
+		seen[v] = true
+	}
+	assert.Equal(t, 1000, len(seen))
+
+	// a few integers are sampled from a large range.
+	res := RandDistinct(1<<40, 5)
+	assert.Equal(t, 5, len(res))
+	for _, v := range res {
+		assert.True(t, v >= 0 && v < 1<<40)
+	}
+}
@@ -0,0 +1,23 @@
+package util
+
+// DefaultScanCount the number of elements examined in a scan call if count is not positive.
+const DefaultScanCount = 10
+
+// ScanRange returns the positions [start, end) to examine in a slice of length n, start is the next cursor.
+// The slice is scanned from the end to the beginning, cursor 0 starts a new scan.
+// A removed element is replaced by the last one, which has been examined already,
+// so an element present for the whole scan is never moved past the cursor.
+func ScanRange(n, cursor, count int) (start, end int) {
+	if count <= 0 {
+		count = DefaultScanCount
+	}
+	end = n
+	if cursor > 0 && cursor < n {
+		end = cursor
+	}
+	start = end - count
+	if start < 0 {
+		start = 0
+	}
+	return
+}
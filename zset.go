@@ -1,11 +1,17 @@
 package opendb
 
 import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"math"
 	"opendb/ds/zset"
 	"opendb/logfile"
 	"opendb/util"
 
 	//"opendb/util"
+	"strconv"
 	"sync"
 	"time"
 )
@@ -14,11 +20,86 @@ const (
 	ZSetZRem
 	ZSetZClear
 	ZSetZExpire
+	ZSetZRemRangeByLex
+	ZSetZRemRangeByScore
+	ZSetZRemRangeByRank
+	ZSetZStore
 )
+
+// ZAddOptions the flags of ZAddMembers and ZAddIncr.
+type ZAddOptions struct {
+	// NX only adds new members.
+	NX bool
+	// XX only updates existing members.
+	XX bool
+	// GT only updates existing members if the new score is greater than the current one.
+	GT bool
+	// LT only updates existing members if the new score is less than the current one.
+	LT bool
+	// CH counts the updated members besides the added ones.
+	CH bool
+}
+
+// ZEntry a member of a sorted set with its score.
+type ZEntry struct {
+	Score  float64
+	Member []byte
+}
+
+// the results of adding a member.
+const (
+	zAddAborted = iota
+	zAddAdded
+	zAddUpdated
+	zAddUnchanged
+)
+
+// ZAggregate how the scores of a member in several sorted sets are combined.
+type ZAggregate = zset.Aggregate
+
+const (
+	// ZAggregateSum the scores are summed, it is the default.
+	ZAggregateSum = zset.AggregateSum
+	// ZAggregateMin the minimum score is kept.
+	ZAggregateMin = zset.AggregateMin
+	// ZAggregateMax the maximum score is kept.
+	ZAggregateMax = zset.AggregateMax
+)
+
+// ZCombineOptions the options of ZUnion, ZInter, ZUnionStore and ZInterStore.
+type ZCombineOptions struct {
+	// Weights multiply the scores of every sorted set, it must have a weight for every key, or be nil for all 1.
+	Weights []float64
+	// Aggregate how the scores of a member are combined.
+	Aggregate ZAggregate
+}
+
+// zsetScoreBinary the first byte of a score in the binary encoding, a score in the legacy decimal text never starts with it.
+const zsetScoreBinary byte = 0
+
+// errInvalidZSetMembers the members of a ZSetZStore entry can not be decoded.
+var errInvalidZSetMembers = errors.New("opendb: invalid sorted set members in log entry")
 // ZsetIdx the zset idx.
 type ZsetIdx struct {
 	mu      *sync.RWMutex
 	indexes *zset.SortedSet
+	// waiters the clients blocked by BZPopMin and BZPopMax, in the order they are blocked.
+	waiters []*zsetWaiter
+}
+
+// zsetWaiter a client blocked on some sorted sets.
+type zsetWaiter struct {
+	keys [][]byte
+	// max is true if the member with the highest score is popped.
+	max bool
+	// result receives the popped member once the waiter is served.
+	result chan zsetPopResult
+}
+
+type zsetPopResult struct {
+	key, member []byte
+	score       float64
+	err         error
 }
 
 // 初始化zset索引，里面包含跳表和读写锁
@@ -32,22 +113,73 @@ func (db *OpenDB) ZAdd(key []byte, score float64, member []byte) error {
 		return err
 	}
 
-	// 如果(key score member)全部相同，则直接返回
-	if ok, oldScore := db.ZScore(key, member); ok && oldScore == score {
-		return nil
+	db.zsetIndex.mu.Lock()
+	defer db.zsetIndex.mu.Unlock()
+
+	res, err := db.zAdd(key, score, member, ZAddOptions{}, false)
+	if res == zAddAdded {
+		db.wakeZSetWaiters(key)
+	}
+	return err
+}
+
+// ZAddMembers adds the members with their scores to the sorted set stored at key under the flags of opts,
+// and returns the number of added members, or the number of added and updated members with CH.
+// All the members are added under a single lock acquisition.
+func (db *OpenDB) ZAddMembers(key []byte, opts ZAddOptions, members ...ZEntry) (int, error) {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return 0, err
+	}
+	if err := opts.check(); err != nil {
+		return 0, err
+	}
+	if len(members) == 0 {
+		return 0, ErrWrongNumberOfArgs
+	}
+	// check all the scores first, so no member is added if any of them is invalid.
+	for _, m := range members {
+		if math.IsNaN(m.Score) {
+			return 0, ErrScoreIsNaN
+		}
 	}
 
 	db.zsetIndex.mu.Lock()
 	defer db.zsetIndex.mu.Unlock()
 
-	extra := []byte(util.Float64ToStr(score))
-	e := logfile.NewEntry(key, member, extra, ZSet, ZSetZAdd)
-	if err := db.store(e); err != nil {
-		return err
+	var n int
+	for _, m := range members {
+		res, err := db.zAdd(key, m.Score, m.Member, opts, false)
+		if err != nil {
+			return n, err
+		}
+		if res == zAddAdded || (opts.CH && res == zAddUpdated) {
+			n++
+		}
 	}
+	db.wakeZSetWaiters(key)
+	return n, nil
+}
 
-	db.zsetIndex.indexes.ZAdd(string(key), score, string(member))
-	return nil
+// ZAddIncr increments the score of member in the sorted set stored at key under the flags of opts, like ZAdd with INCR.
+// It returns the new score, ok is false if the increment is aborted by the flags.
+func (db *OpenDB) ZAddIncr(key []byte, opts ZAddOptions, increment float64, member []byte) (score float64, ok bool, err error) {
+	if err = db.checkKeyValue(key, member); err != nil {
+		return
+	}
+	if err = opts.check(); err != nil {
+		return
+	}
+
+	db.zsetIndex.mu.Lock()
+	defer db.zsetIndex.mu.Unlock()
+
+	res, err := db.zAdd(key, increment, member, opts, true)
+	if err != nil || res == zAddAborted {
+		return
+	}
+	_, score = db.zsetIndex.indexes.ZScore(string(key), string(member))
+	db.wakeZSetWaiters(key)
+	return score, true, nil
 }
 
 // ZScore returns the score of member in the sorted set at key.
@@ -62,6 +194,38 @@ func (db *OpenDB) ZScore(key, member []byte) (ok bool, score float64) {
 	return db.zsetIndex.indexes.ZScore(string(key), string(member))
 }
 
+// ZMScore returns the scores of the members in the sorted set at key, the score of a member that does not exist is nil.
+func (db *OpenDB) ZMScore(key []byte, members ...[]byte) []interface{} {
+	db.zsetIndex.mu.RLock()
+	defer db.zsetIndex.mu.RUnlock()
+
+	scores := make([]interface{}, len(members))
+	if db.checkExpired(key, ZSet) {
+		return scores
+	}
+	for i, m := range members {
+		if ok, score := db.zsetIndex.indexes.ZScore(string(key), string(m)); ok {
+			scores[i] = score
+		}
+	}
+	return scores
+}
+
+// ZCount returns the number of members in the sorted set at key with a score between min and max (inclusive).
+func (db *OpenDB) ZCount(key []byte, min, max float64) int {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return 0
+	}
+
+	db.zsetIndex.mu.RLock()
+	defer db.zsetIndex.mu.RUnlock()
+
+	if db.checkExpired(key, ZSet) {
+		return 0
+	}
+	return db.zsetIndex.indexes.ZCount(string(key), min, max)
+}
+
 // ZCard returns the sorted set cardinality (number of elements) of the sorted set stored at key.
 func (db *OpenDB) ZCard(key []byte) int {
 	db.zsetIndex.mu.RLock()
@@ -119,15 +283,13 @@ func (db *OpenDB) ZIncrBy(key []byte, increment float64, member []byte) (float64
 	db.zsetIndex.mu.Lock()
 	defer db.zsetIndex.mu.Unlock()
 
-	increment = db.zsetIndex.indexes.ZIncrBy(string(key), increment, string(member))
-
-	extra := util.Float64ToStr(increment)
-	e := logfile.NewEntry(key, member, []byte(extra), ZSet, ZSetZAdd)
-	if err := db.store(e); err != nil {
+	if _, err := db.zAdd(key, increment, member, ZAddOptions{}, true); err != nil {
 		return increment, err
 	}
+	_, score := db.zsetIndex.indexes.ZScore(string(key), string(member))
 
-	return increment, nil
+	db.wakeZSetWaiters(key)
+	return score, nil
 }
 
 // ZRange returns the specified range of elements in the sorted set stored at key.
@@ -222,6 +384,77 @@ func (db *OpenDB) ZRem(key, member []byte) (ok bool, err error) {
 	return
 }
 
+// ZRemRangeByScore removes all the members of the sorted set at key with a score between min and max (inclusive),
+// and returns the number of removed members. The removal is logged as a single entry.
+func (db *OpenDB) ZRemRangeByScore(key []byte, min, max float64) (int, error) {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return 0, err
+	}
+	if math.IsNaN(min) || math.IsNaN(max) {
+		return 0, ErrScoreIsNaN
+	}
+
+	db.zsetIndex.mu.Lock()
+	defer db.zsetIndex.mu.Unlock()
+
+	if db.checkExpired(key, ZSet) || len(db.zsetIndex.indexes.ZScoreRange(string(key), min, max)) == 0 {
+		return 0, nil
+	}
+
+	e := logfile.NewEntry(key, encodeScore(min), encodeScore(max), ZSet, ZSetZRemRangeByScore)
+	if err := db.store(e); err != nil {
+		return 0, err
+	}
+	return db.zsetIndex.indexes.ZRemRangeByScore(string(key), min, max), nil
+}
+
+// ZRemRangeByRank removes all the members of the sorted set at key with a rank between start and stop (inclusive),
+// and returns the number of removed members. The ranks are 0-based, and negative ranks count from the highest score.
+// The removal is logged as a single entry.
+func (db *OpenDB) ZRemRangeByRank(key []byte, start, stop int) (int, error) {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return 0, err
+	}
+
+	db.zsetIndex.mu.Lock()
+	defer db.zsetIndex.mu.Unlock()
+
+	if db.checkExpired(key, ZSet) || len(db.zsetIndex.indexes.ZRange(string(key), start, stop)) == 0 {
+		return 0, nil
+	}
+
+	e := logfile.NewEntry(key, []byte(strconv.Itoa(start)), []byte(strconv.Itoa(stop)), ZSet, ZSetZRemRangeByRank)
+	if err := db.store(e); err != nil {
+		return 0, err
+	}
+	return db.zsetIndex.indexes.ZRemRangeByRank(string(key), start, stop), nil
+}
+
+// ZPopMin removes and returns at most count members with the lowest scores in the sorted set at key,
+// every member is followed by its score, and the lowest comes first. The pop is logged as a single entry.
+func (db *OpenDB) ZPopMin(key []byte, count int) ([]interface{}, error) {
+	return db.zPopCount(key, count, false)
+}
+
+// ZPopMax removes and returns at most count members with the highest scores in the sorted set at key,
+// every member is followed by its score, and the highest comes first. The pop is logged as a single entry.
+func (db *OpenDB) ZPopMax(key []byte, count int) ([]interface{}, error) {
+	return db.zPopCount(key, count, true)
+}
+
+// BZPopMin pops the member with the lowest score from the first non-empty sorted set of keys, in the order they are given.
+// If all of them are empty, it blocks until a member is added to any of them by this db, ctx is done or timeout elapses.
+// A zero timeout blocks indefinitely, and nil key is returned if timeout elapses.
+// The blocked clients are served in the order they are blocked.
+func (db *OpenDB) BZPopMin(ctx context.Context, timeout time.Duration, keys ...[]byte) (key, member []byte, score float64, err error) {
+	return db.blockingZPop(ctx, timeout, keys, false)
+}
+
+// BZPopMax pops the member with the highest score from the first non-empty sorted set of keys, like BZPopMin.
+func (db *OpenDB) BZPopMax(ctx context.Context, timeout time.Duration, keys ...[]byte) (key, member []byte, score float64, err error) {
+	return db.blockingZPop(ctx, timeout, keys, true)
+}
+
 // ZGetByRank get the member at key by rank, the rank is ordered from lowest to highest.
 // The rank of lowest is 0 and so on.
 func (db *OpenDB) ZGetByRank(key []byte, rank int) []interface{} {
@@ -282,6 +515,173 @@ func (db *OpenDB) ZRevScoreRange(key []byte, max, min float64) []interface{} {
 	return db.zsetIndex.indexes.ZRevScoreRange(string(key), max, min)
 }
 
+// ZScan iterates the members of the sorted set stored at key, every member is followed by its score.
+// Start with cursor 0 and call ZScan with the returned cursor until it returns 0, the lock is released between the calls.
+// count is the number of members examined in a call, only the members matching the glob-style pattern match are returned.
+// A member present for the whole iteration is returned at least once, and it may be returned more than once.
+func (db *OpenDB) ZScan(key []byte, cursor int, match string, count int) (int, []interface{}) {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return 0, nil
+	}
+
+	db.zsetIndex.mu.RLock()
+	defer db.zsetIndex.mu.RUnlock()
+
+	if db.checkExpired(key, ZSet) {
+		return 0, nil
+	}
+
+	return db.zsetIndex.indexes.ZScan(string(key), cursor, match, count)
+}
+
+// ZRangeByLex returns the members of the sorted set at key in the lexicographical range between min and max.
+// A bound is "[member" for inclusive, "(member" for exclusive, "-" for negative infinity or "+" for positive infinity.
+// The range is only meaningful if all the members have the same score.
+// offset members are skipped and at most count members are returned, a negative count means all.
+func (db *OpenDB) ZRangeByLex(key, min, max []byte, offset, count int) ([][]byte, error) {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return nil, err
+	}
+	r, ok := zset.ParseLexRange(string(min), string(max))
+	if !ok {
+		return nil, ErrInvalidLexRange
+	}
+
+	db.zsetIndex.mu.RLock()
+	defer db.zsetIndex.mu.RUnlock()
+
+	if db.checkExpired(key, ZSet) {
+		return nil, nil
+	}
+
+	var val [][]byte
+	for _, m := range db.zsetIndex.indexes.ZRangeByLex(string(key), r, offset, count) {
+		val = append(val, []byte(m))
+	}
+	return val, nil
+}
+
+// ZLexCount returns the number of members of the sorted set at key in the lexicographical range between min and max.
+func (db *OpenDB) ZLexCount(key, min, max []byte) (int, error) {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return 0, err
+	}
+	r, ok := zset.ParseLexRange(string(min), string(max))
+	if !ok {
+		return 0, ErrInvalidLexRange
+	}
+
+	db.zsetIndex.mu.RLock()
+	defer db.zsetIndex.mu.RUnlock()
+
+	if db.checkExpired(key, ZSet) {
+		return 0, nil
+	}
+	return db.zsetIndex.indexes.ZLexCount(string(key), r), nil
+}
+
+// ZRemRangeByLex removes the members of the sorted set at key in the lexicographical range between min and max,
+// and returns the number of removed members. The removal is logged as a single entry.
+func (db *OpenDB) ZRemRangeByLex(key, min, max []byte) (int, error) {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return 0, err
+	}
+	r, ok := zset.ParseLexRange(string(min), string(max))
+	if !ok {
+		return 0, ErrInvalidLexRange
+	}
+
+	db.zsetIndex.mu.Lock()
+	defer db.zsetIndex.mu.Unlock()
+
+	if db.checkExpired(key, ZSet) || db.zsetIndex.indexes.ZLexCount(string(key), r) == 0 {
+		return 0, nil
+	}
+
+	e := logfile.NewEntry(key, min, max, ZSet, ZSetZRemRangeByLex)
+	if err := db.store(e); err != nil {
+		return 0, err
+	}
+	return db.zsetIndex.indexes.ZRemRangeByLex(string(key), r), nil
+}
+
+// ZUnion returns the union of the sorted sets at keys, every member is followed by its score, and they are ordered by score.
+// Keys that do not exist are considered to be empty sorted sets, opts can be nil.
+func (db *OpenDB) ZUnion(opts *ZCombineOptions, keys ...[]byte) ([]interface{}, error) {
+	if err := checkZCombine(opts, keys); err != nil {
+		return nil, err
+	}
+
+	db.zsetIndex.mu.RLock()
+	defer db.zsetIndex.mu.RUnlock()
+
+	return db.zUnion(opts, keys), nil
+}
+
+// ZInter returns the intersection of the sorted sets at keys, every member is followed by its score, and they are ordered by score.
+// opts can be nil.
+func (db *OpenDB) ZInter(opts *ZCombineOptions, keys ...[]byte) ([]interface{}, error) {
+	if err := checkZCombine(opts, keys); err != nil {
+		return nil, err
+	}
+
+	db.zsetIndex.mu.RLock()
+	defer db.zsetIndex.mu.RUnlock()
+
+	return db.zInter(opts, keys), nil
+}
+
+// ZDiff returns the members of the first sorted set that are not in the successive ones, every member is followed by its score.
+func (db *OpenDB) ZDiff(keys ...[]byte) ([]interface{}, error) {
+	if err := checkZCombine(nil, keys); err != nil {
+		return nil, err
+	}
+
+	db.zsetIndex.mu.RLock()
+	defer db.zsetIndex.mu.RUnlock()
+
+	return db.zDiff(keys), nil
+}
+
+// ZUnionStore stores the union of the sorted sets at keys in dst, and returns the number of members in it.
+// If dst already exists, it is overwritten.
+func (db *OpenDB) ZUnionStore(dst []byte, opts *ZCombineOptions, keys ...[]byte) (int, error) {
+	if err := db.checkZStore(dst, opts, keys); err != nil {
+		return 0, err
+	}
+
+	db.zsetIndex.mu.Lock()
+	defer db.zsetIndex.mu.Unlock()
+
+	return db.zStore(dst, db.zUnion(opts, keys))
+}
+
+// ZInterStore stores the intersection of the sorted sets at keys in dst, and returns the number of members in it.
+// If dst already exists, it is overwritten.
+func (db *OpenDB) ZInterStore(dst []byte, opts *ZCombineOptions, keys ...[]byte) (int, error) {
+	if err := db.checkZStore(dst, opts, keys); err != nil {
+		return 0, err
+	}
+
+	db.zsetIndex.mu.Lock()
+	defer db.zsetIndex.mu.Unlock()
+
+	return db.zStore(dst, db.zInter(opts, keys))
+}
+
+// ZDiffStore stores the members of the first sorted set that are not in the successive ones in dst, and returns the number of members in it.
+// If dst already exists, it is overwritten.
+func (db *OpenDB) ZDiffStore(dst []byte, keys ...[]byte) (int, error) {
+	if err := db.checkZStore(dst, nil, keys); err != nil {
+		return 0, err
+	}
+
+	db.zsetIndex.mu.Lock()
+	defer db.zsetIndex.mu.Unlock()
+
+	return db.zStore(dst, db.zDiff(keys))
+}
+
 // ZKeyExists check if the key exists in zset.
 func (db *OpenDB) ZKeyExists(key []byte) (ok bool) {
 	if err := db.checkKeyValue(key, nil); err != nil {
@@ -354,3 +754,349 @@ func (db *OpenDB) ZTTL(key []byte) (ttl int64) {
 	return deadline - time.Now().Unix()
 }
 
+
+func checkZCombine(opts *ZCombineOptions, keys [][]byte) error {
+	if len(keys) == 0 {
+		return ErrWrongNumberOfArgs
+	}
+	if opts != nil && opts.Weights != nil && len(opts.Weights) != len(keys) {
+		return ErrWrongNumberOfArgs
+	}
+	return nil
+}
+
+func (db *OpenDB) checkZStore(dst []byte, opts *ZCombineOptions, keys [][]byte) error {
+	if err := db.checkKeyValue(dst, nil); err != nil {
+		return err
+	}
+	return checkZCombine(opts, keys)
+}
+
+// zUnion skips the expired keys and their weights.
+func (db *OpenDB) zUnion(opts *ZCombineOptions, keys [][]byte) []interface{} {
+	if opts == nil {
+		opts = new(ZCombineOptions)
+	}
+
+	var validKeys []string
+	var weights []float64
+	for i, k := range keys {
+		if db.checkExpired(k, ZSet) {
+			continue
+		}
+		validKeys = append(validKeys, string(k))
+		if opts.Weights != nil {
+			weights = append(weights, opts.Weights[i])
+		}
+	}
+	return db.zsetIndex.indexes.ZUnion(validKeys, weights, opts.Aggregate)
+}
+
+// zInter returns nil if any key is expired, since an expired sorted set is empty.
+func (db *OpenDB) zInter(opts *ZCombineOptions, keys [][]byte) []interface{} {
+	if opts == nil {
+		opts = new(ZCombineOptions)
+	}
+
+	validKeys := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if db.checkExpired(k, ZSet) {
+			return nil
+		}
+		validKeys = append(validKeys, string(k))
+	}
+	return db.zsetIndex.indexes.ZInter(validKeys, opts.Weights, opts.Aggregate)
+}
+
+func (db *OpenDB) zDiff(keys [][]byte) []interface{} {
+	if db.checkExpired(keys[0], ZSet) {
+		return nil
+	}
+
+	validKeys := []string{string(keys[0])}
+	for _, k := range keys[1:] {
+		if !db.checkExpired(k, ZSet) {
+			validKeys = append(validKeys, string(k))
+		}
+	}
+	return db.zsetIndex.indexes.ZDiff(validKeys)
+}
+
+// zStore replaces the sorted set dst with the members followed by their scores by a single log entry,
+// so the result is written atomically. dst is removed if there is no member.
+func (db *OpenDB) zStore(dst []byte, pairs []interface{}) (int, error) {
+	var e *logfile.Entry
+	if len(pairs) == 0 {
+		if !db.zsetIndex.indexes.ZKeyExists(string(dst)) {
+			return 0, nil
+		}
+		e = logfile.NewEntryNoExtra(dst, nil, ZSet, ZSetZClear)
+	} else {
+		e = logfile.NewEntryNoExtra(dst, encodeZSetMembers(pairs), ZSet, ZSetZStore)
+	}
+	if err := db.store(e); err != nil {
+		return 0, err
+	}
+
+	db.zsetIndex.indexes.ZStore(string(dst), pairs)
+	delete(db.expires[ZSet], string(dst))
+	db.wakeZSetWaiters(dst)
+	return len(pairs) / 2, nil
+}
+
+// encodeZSetMembers encodes the members followed by their scores as the uvarint count,
+// then every member prefixed with its uvarint length and followed by the 8 bytes of its score.
+func encodeZSetMembers(pairs []interface{}) []byte {
+	size := binary.MaxVarintLen64
+	for i := 0; i < len(pairs); i += 2 {
+		size += binary.MaxVarintLen64 + len(pairs[i].(string)) + 8
+	}
+
+	buf := make([]byte, size)
+	n := binary.PutUvarint(buf, uint64(len(pairs)/2))
+	for i := 0; i < len(pairs); i += 2 {
+		member := pairs[i].(string)
+		n += binary.PutUvarint(buf[n:], uint64(len(member)))
+		n += copy(buf[n:], member)
+		binary.BigEndian.PutUint64(buf[n:], math.Float64bits(pairs[i+1].(float64)))
+		n += 8
+	}
+	return buf[:n]
+}
+
+func decodeZSetMembers(buf []byte) ([]interface{}, error) {
+	count, n := binary.Uvarint(buf)
+	if n <= 0 || count > uint64(len(buf)) {
+		return nil, errInvalidZSetMembers
+	}
+	buf = buf[n:]
+
+	pairs := make([]interface{}, 0, 2*count)
+	for i := uint64(0); i < count; i++ {
+		size, n := binary.Uvarint(buf)
+		// size+8 may overflow, so the score is taken off the remaining bytes instead.
+		if n <= 0 || len(buf)-n < 8 || size > uint64(len(buf)-n-8) {
+			return nil, errInvalidZSetMembers
+		}
+		end := n + int(size)
+		pairs = append(pairs, string(buf[n:end]), math.Float64frombits(binary.BigEndian.Uint64(buf[end:])))
+		buf = buf[end+8:]
+	}
+	if len(buf) != 0 {
+		return nil, errInvalidZSetMembers
+	}
+	return pairs, nil
+}
+
+func (opts ZAddOptions) check() error {
+	if (opts.NX && (opts.XX || opts.GT || opts.LT)) || (opts.GT && opts.LT) {
+		return ErrZAddFlagsConflict
+	}
+	return nil
+}
+
+// zAdd adds or updates a member under the flags, the score is an increment if incr is true.
+// A change is logged as a ZSetZAdd entry with the new score.
+func (db *OpenDB) zAdd(key []byte, score float64, member []byte, opts ZAddOptions, incr bool) (int, error) {
+	if db.checkExpired(key, ZSet) {
+		if err := db.zClearExpired(key); err != nil {
+			return zAddAborted, err
+		}
+	}
+	exist, old := db.zsetIndex.indexes.ZScore(string(key), string(member))
+	if (opts.NX && exist) || (opts.XX && !exist) {
+		return zAddAborted, nil
+	}
+
+	if incr && exist {
+		score += old
+	}
+	if math.IsNaN(score) {
+		return zAddAborted, ErrScoreIsNaN
+	}
+	res := zAddAdded
+	if exist {
+		if (opts.GT && score <= old) || (opts.LT && score >= old) {
+			return zAddAborted, nil
+		}
+		if score == old {
+			return zAddUnchanged, nil
+		}
+		res = zAddUpdated
+	}
+
+	e := logfile.NewEntry(key, member, encodeScore(score), ZSet, ZSetZAdd)
+	if err := db.store(e); err != nil {
+		return zAddAborted, err
+	}
+	db.zsetIndex.indexes.ZAdd(string(key), score, string(member))
+	return res, nil
+}
+
+// zClearExpired removes the expired sorted set stored at key before it is written again,
+// so the old members and time to live are not kept. The lock of ZSet must be held.
+func (db *OpenDB) zClearExpired(key []byte) error {
+	e := logfile.NewEntryNoExtra(key, nil, ZSet, ZSetZClear)
+	if err := db.store(e); err != nil {
+		return err
+	}
+	db.zsetIndex.indexes.ZClear(string(key))
+	delete(db.expires[ZSet], string(key))
+	return nil
+}
+
+func (db *OpenDB) zPopCount(key []byte, count int, max bool) ([]interface{}, error) {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return nil, err
+	}
+
+	db.zsetIndex.mu.Lock()
+	defer db.zsetIndex.mu.Unlock()
+
+	return db.zPop(key, count, max)
+}
+
+// zPop removes and returns the count members with the lowest or highest scores, the lock of ZSet must be held.
+// The pop is logged as a ZSetZRemRangeByRank entry.
+func (db *OpenDB) zPop(key []byte, count int, max bool) ([]interface{}, error) {
+	if count <= 0 || db.checkExpired(key, ZSet) || db.zsetIndex.indexes.ZCard(string(key)) == 0 {
+		return nil, nil
+	}
+
+	start, stop := 0, count-1
+	pairs := db.zsetIndex.indexes.ZRangeWithScores(string(key), start, stop)
+	if max {
+		start, stop = -count, -1
+		pairs = db.zsetIndex.indexes.ZRevRangeWithScores(string(key), 0, count-1)
+	}
+
+	e := logfile.NewEntry(key, []byte(strconv.Itoa(start)), []byte(strconv.Itoa(stop)), ZSet, ZSetZRemRangeByRank)
+	if err := db.store(e); err != nil {
+		return nil, err
+	}
+	db.zsetIndex.indexes.ZRemRangeByRank(string(key), start, stop)
+	return pairs, nil
+}
+
+func (db *OpenDB) blockingZPop(ctx context.Context, timeout time.Duration, keys [][]byte, max bool) ([]byte, []byte, float64, error) {
+	if len(keys) == 0 {
+		return nil, nil, 0, ErrWrongNumberOfArgs
+	}
+	if timeout < 0 {
+		return nil, nil, 0, ErrInvalidTTL
+	}
+	for _, key := range keys {
+		if err := db.checkKeyValue(key, nil); err != nil {
+			return nil, nil, 0, err
+		}
+	}
+
+	w := &zsetWaiter{keys: keys, max: max, result: make(chan zsetPopResult, 1)}
+	db.zsetIndex.mu.Lock()
+	for _, key := range keys {
+		// there is no waiter on a non-empty sorted set, so it is fair to pop directly.
+		if db.zsetLen(key) > 0 {
+			res := db.serveZSetWaiter(w, key)
+			db.zsetIndex.mu.Unlock()
+			return res.key, res.member, res.score, res.err
+		}
+	}
+	db.zsetIndex.waiters = append(db.zsetIndex.waiters, w)
+	db.zsetIndex.mu.Unlock()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	var err error
+	select {
+	case res := <-w.result:
+		return res.key, res.member, res.score, res.err
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-expired:
+	}
+
+	db.zsetIndex.mu.Lock()
+	removed := db.removeZSetWaiter(w)
+	db.zsetIndex.mu.Unlock()
+	// the waiter is served before it is removed, the pop is done and must be returned.
+	if !removed {
+		res := <-w.result
+		return res.key, res.member, res.score, res.err
+	}
+	return nil, nil, 0, err
+}
+
+// wakeZSetWaiters serve the waiters blocked on the key while the sorted set is not empty, the lock of ZSet must be held.
+func (db *OpenDB) wakeZSetWaiters(key []byte) {
+	for i := 0; i < len(db.zsetIndex.waiters) && db.zsetLen(key) > 0; {
+		w := db.zsetIndex.waiters[i]
+		if !w.waitsOn(key) {
+			i++
+			continue
+		}
+		db.zsetIndex.waiters = append(db.zsetIndex.waiters[:i], db.zsetIndex.waiters[i+1:]...)
+		db.serveZSetWaiter(w, key)
+	}
+}
+
+// serveZSetWaiter pops from the key for the waiter and sends the result to it, the lock of ZSet must be held.
+// The pop is logged here, so it is persisted exactly once even if the waiter gives up at the same time.
+func (db *OpenDB) serveZSetWaiter(w *zsetWaiter, key []byte) zsetPopResult {
+	res := zsetPopResult{key: key}
+	var pairs []interface{}
+	if pairs, res.err = db.zPop(key, 1, w.max); len(pairs) == 2 {
+		res.member, res.score = []byte(pairs[0].(string)), pairs[1].(float64)
+	}
+	w.result <- res
+	return res
+}
+
+// removeZSetWaiter returns false if the waiter is not blocked anymore, the lock of ZSet must be held.
+func (db *OpenDB) removeZSetWaiter(w *zsetWaiter) bool {
+	for i, waiter := range db.zsetIndex.waiters {
+		if waiter == w {
+			db.zsetIndex.waiters = append(db.zsetIndex.waiters[:i], db.zsetIndex.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (w *zsetWaiter) waitsOn(key []byte) bool {
+	for _, k := range w.keys {
+		if bytes.Equal(k, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// zsetLen returns the number of members of the sorted set, 0 if it is expired.
+func (db *OpenDB) zsetLen(key []byte) int {
+	if db.checkExpired(key, ZSet) {
+		return 0
+	}
+	return db.zsetIndex.indexes.ZCard(string(key))
+}
+
+// encodeScore encodes the score in 9 bytes, zsetScoreBinary followed by the IEEE 754 bits in big endian,
+// so every value is kept exactly, including -0, infinities and subnormals.
+func encodeScore(score float64) []byte {
+	buf := make([]byte, 9)
+	buf[0] = zsetScoreBinary
+	binary.BigEndian.PutUint64(buf[1:], math.Float64bits(score))
+	return buf
+}
+
+// decodeScore decodes a score in the binary encoding, or in the decimal text written by the former versions.
+func decodeScore(buf []byte) (float64, error) {
+	if len(buf) == 9 && buf[0] == zsetScoreBinary {
+		return math.Float64frombits(binary.BigEndian.Uint64(buf[1:])), nil
+	}
+	return util.StrToFloat64(string(buf))
+}
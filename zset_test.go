@@ -1,6 +1,16 @@
 package opendb
 
-import "testing"
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"opendb/logfile"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
 
 func TestOpenDB_ZAdd(t *testing.T) {
 	opts := DefaultOptions("/tmp/opendb")
@@ -28,4 +38,498 @@ func TestOpenDB_ZAdd(t *testing.T) {
 	}
 	ok, s := db.ZScore(key, []byte("roseduan"))
 	t.Log(ok, s)
-}
\ No newline at end of file
+}
+
+func TestOpenDB_ZScan(t *testing.T) {
+	db := openTestDB(t)
+
+	key := []byte("z")
+	for i := 0; i < 30; i++ {
+		assert.Nil(t, db.ZAdd(key, float64(i), []byte(fmt.Sprintf("m%d", i))))
+	}
+
+	seen := make(map[string]float64)
+	cursor := 0
+	for {
+		var res []interface{}
+		cursor, res = db.ZScan(key, cursor, "m*", 8)
+		for i := 0; i < len(res); i += 2 {
+			seen[res[i].(string)] = res[i+1].(float64)
+		}
+		// changing a score keeps the position of the member.
+		assert.Nil(t, db.ZAdd(key, 100, []byte("m0")))
+		if cursor == 0 {
+			break
+		}
+	}
+	assert.Equal(t, 30, len(seen))
+	assert.Equal(t, float64(7), seen["m7"])
+}
+
+func TestOpenDB_ZRangeByLex(t *testing.T) {
+	path := t.TempDir()
+	db, err := Open(DefaultOptions(path))
+	assert.Nil(t, err)
+
+	key := []byte("lex")
+	for _, m := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+		assert.Nil(t, db.ZAdd(key, 0, []byte(m)))
+	}
+
+	rangeByLex := func(min, max string, offset, count int) []string {
+		val, err := db.ZRangeByLex(key, []byte(min), []byte(max), offset, count)
+		assert.Nil(t, err)
+		var res []string
+		for _, v := range val {
+			res = append(res, string(v))
+		}
+		return res
+	}
+	assert.Equal(t, []string{"a", "b", "c"}, rangeByLex("-", "[c", 0, -1))
+	assert.Equal(t, []string{"a", "b"}, rangeByLex("-", "(c", 0, -1))
+	assert.Equal(t, []string{"c", "d", "e", "f"}, rangeByLex("[bb", "(g", 0, -1))
+	assert.Equal(t, []string{"e", "f"}, rangeByLex("(c", "+", 1, 2))
+	assert.Equal(t, []string{"g"}, rangeByLex("(f", "+", 0, -1))
+	assert.Nil(t, rangeByLex("(c", "+", 10, -1))
+	assert.Nil(t, rangeByLex("[d", "(d", 0, -1))
+	assert.Nil(t, rangeByLex("+", "-", 0, -1))
+	assert.Nil(t, rangeByLex("[z", "+", 0, -1))
+
+	_, err = db.ZRangeByLex(key, []byte("a"), []byte("+"), 0, -1)
+	assert.Equal(t, ErrInvalidLexRange, err)
+	_, err = db.ZLexCount(key, []byte("-"), []byte("++"))
+	assert.Equal(t, ErrInvalidLexRange, err)
+
+	lexCount := func(min, max string) int {
+		n, err := db.ZLexCount(key, []byte(min), []byte(max))
+		assert.Nil(t, err)
+		return n
+	}
+	assert.Equal(t, 7, lexCount("-", "+"))
+	assert.Equal(t, 3, lexCount("[b", "(e"))
+	assert.Equal(t, 1, lexCount("[g", "[g"))
+	assert.Equal(t, 0, lexCount("(g", "+"))
+	assert.Equal(t, 0, lexCount("-", "(a"))
+
+	n, err := db.ZRemRangeByLex(key, []byte("(b"), []byte("[e"))
+	assert.Nil(t, err)
+	assert.Equal(t, 3, n)
+	n, err = db.ZRemRangeByLex(key, []byte("(b"), []byte("[e"))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, n)
+	assert.Equal(t, []string{"a", "b", "f", "g"}, rangeByLex("-", "+", 0, -1))
+
+	// the removal is replayed from the log.
+	db2, err := Open(DefaultOptions(path))
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{"a", "b", "f", "g"}, db2.ZRange(key, 0, -1))
+	// the remaining members can still be scanned after the removal.
+	_, res := db2.ZScan(key, 0, "", 10)
+	assert.Equal(t, 8, len(res))
+}
+
+func TestOpenDB_ZRemRange(t *testing.T) {
+	path := t.TempDir()
+	db, err := Open(DefaultOptions(path))
+	assert.Nil(t, err)
+
+	key := []byte("board")
+	for i := 0; i < 10; i++ {
+		assert.Nil(t, db.ZAdd(key, float64(i*10), []byte(fmt.Sprintf("p%d", i))))
+	}
+
+	n, err := db.ZRemRangeByScore(key, 15, 40)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, n)
+	n, err = db.ZRemRangeByScore(key, 15, 40)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, n)
+	n, err = db.ZRemRangeByScore(key, 50, 40)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, n)
+	assert.Equal(t, []interface{}{"p0", "p1", "p5", "p6", "p7", "p8", "p9"}, db.ZRange(key, 0, -1))
+
+	// remove the two lowest and the highest.
+	n, err = db.ZRemRangeByRank(key, 0, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+	n, err = db.ZRemRangeByRank(key, -1, -1)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+	n, err = db.ZRemRangeByRank(key, 5, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, n)
+	assert.Equal(t, []interface{}{"p5", "p6", "p7", "p8"}, db.ZRange(key, 0, -1))
+
+	// every removal is replayed from its single entry.
+	db2, err := Open(DefaultOptions(path))
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{"p5", "p6", "p7", "p8"}, db2.ZRange(key, 0, -1))
+	assert.Equal(t, int64(1), db2.ZRank(key, []byte("p6")))
+
+	n, err = db2.ZRemRangeByRank(key, 0, -1)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, n)
+	assert.Equal(t, 0, db2.ZCard(key))
+	assert.Nil(t, db2.ZScoreRange(key, 0, 100))
+}
+
+func TestOpenDB_ZCombine(t *testing.T) {
+	path := t.TempDir()
+	db, err := Open(DefaultOptions(path))
+	assert.Nil(t, err)
+
+	z1, z2 := []byte("z1"), []byte("z2")
+	assert.Nil(t, db.ZAdd(z1, 1, []byte("a")))
+	assert.Nil(t, db.ZAdd(z1, 2, []byte("b")))
+	assert.Nil(t, db.ZAdd(z1, 3, []byte("c")))
+	assert.Nil(t, db.ZAdd(z2, 10, []byte("b")))
+	assert.Nil(t, db.ZAdd(z2, 20, []byte("c")))
+	assert.Nil(t, db.ZAdd(z2, 30, []byte("d")))
+
+	val, err := db.ZUnion(nil, z1, z2, []byte("none"))
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{"a", float64(1), "b", float64(12), "c", float64(23), "d", float64(30)}, val)
+
+	val, err = db.ZUnion(&ZCombineOptions{Weights: []float64{2, 0.1}, Aggregate: ZAggregateMax}, z1, z2)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{"a", float64(2), "d", float64(3), "b", float64(4), "c", float64(6)}, val)
+
+	val, err = db.ZInter(&ZCombineOptions{Aggregate: ZAggregateMin}, z1, z2)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{"b", float64(2), "c", float64(3)}, val)
+	val, err = db.ZInter(nil, z1, []byte("none"))
+	assert.Nil(t, err)
+	assert.Nil(t, val)
+
+	val, err = db.ZDiff(z1, z2)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{"a", float64(1)}, val)
+
+	_, err = db.ZUnion(&ZCombineOptions{Weights: []float64{1}}, z1, z2)
+	assert.Equal(t, ErrWrongNumberOfArgs, err)
+	_, err = db.ZInter(nil)
+	assert.Equal(t, ErrWrongNumberOfArgs, err)
+
+	n, err := db.ZUnionStore([]byte("union"), &ZCombineOptions{Weights: []float64{1, -1}}, z1, z2)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, n)
+	n, err = db.ZInterStore(z1, nil, z1, z2)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+	n, err = db.ZDiffStore([]byte("diff"), z2, z1)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+	// an empty result removes the destination.
+	n, err = db.ZDiffStore(z2, z2, z2)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, n)
+	assert.False(t, db.ZKeyExists(z2))
+
+	// the stored sorted sets are rebuilt from the log.
+	db2, err := Open(DefaultOptions(path))
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{"d", float64(-30), "c", float64(-17), "b", float64(-8), "a", float64(1)}, db2.ZRangeWithScores([]byte("union"), 0, -1))
+	assert.Equal(t, []interface{}{"b", float64(12), "c", float64(23)}, db2.ZRangeWithScores(z1, 0, -1))
+	assert.Equal(t, []interface{}{"d", float64(30)}, db2.ZRangeWithScores([]byte("diff"), 0, -1))
+	assert.False(t, db2.ZKeyExists(z2))
+}
+
+func TestOpenDB_ZAddMembers(t *testing.T) {
+	path := t.TempDir()
+	db, err := Open(DefaultOptions(path))
+	assert.Nil(t, err)
+
+	key := []byte("z")
+	n, err := db.ZAddMembers(key, ZAddOptions{}, ZEntry{1, []byte("a")}, ZEntry{2, []byte("b")}, ZEntry{3, []byte("c")})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, n)
+
+	// NX only adds new members.
+	n, err = db.ZAddMembers(key, ZAddOptions{NX: true, CH: true}, ZEntry{10, []byte("a")}, ZEntry{4, []byte("d")})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+	// XX only updates, CH counts the updates.
+	n, err = db.ZAddMembers(key, ZAddOptions{XX: true}, ZEntry{10, []byte("a")}, ZEntry{5, []byte("e")})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, n)
+	n, err = db.ZAddMembers(key, ZAddOptions{XX: true, CH: true}, ZEntry{11, []byte("a")}, ZEntry{2, []byte("b")})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+	// GT and LT only update in one direction, and they still add new members.
+	n, err = db.ZAddMembers(key, ZAddOptions{GT: true, CH: true}, ZEntry{1, []byte("b")}, ZEntry{30, []byte("c")}, ZEntry{6, []byte("f")})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+	n, err = db.ZAddMembers(key, ZAddOptions{LT: true, CH: true}, ZEntry{0, []byte("b")}, ZEntry{40, []byte("c")})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []interface{}{float64(11), float64(0), float64(30), float64(4), nil, float64(6)},
+		db.ZMScore(key, []byte("a"), []byte("b"), []byte("c"), []byte("d"), []byte("e"), []byte("f")))
+
+	_, err = db.ZAddMembers(key, ZAddOptions{NX: true, GT: true}, ZEntry{1, []byte("a")})
+	assert.Equal(t, ErrZAddFlagsConflict, err)
+	_, err = db.ZAddMembers(key, ZAddOptions{GT: true, LT: true}, ZEntry{1, []byte("a")})
+	assert.Equal(t, ErrZAddFlagsConflict, err)
+	_, err = db.ZAddMembers(key, ZAddOptions{})
+	assert.Equal(t, ErrWrongNumberOfArgs, err)
+
+	score, ok, err := db.ZAddIncr(key, ZAddOptions{}, 5, []byte("a"))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, float64(16), score)
+	_, ok, err = db.ZAddIncr(key, ZAddOptions{GT: true}, -1, []byte("a"))
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	_, ok, err = db.ZAddIncr(key, ZAddOptions{XX: true}, 1, []byte("none"))
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	score, ok, err = db.ZAddIncr(key, ZAddOptions{NX: true}, 7, []byte("g"))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, float64(7), score)
+
+	assert.Equal(t, 3, db.ZCount(key, 4, 11))
+	assert.Equal(t, 6, db.ZCount(key, math.Inf(-1), math.Inf(1)))
+	assert.Equal(t, 0, db.ZCount(key, 12, 15))
+	assert.Equal(t, 0, db.ZCount(key, 11, 4))
+
+	db2, err := Open(DefaultOptions(path))
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{"b", float64(0), "d", float64(4), "f", float64(6), "g", float64(7), "a", float64(16), "c", float64(30)},
+		db2.ZRangeWithScores(key, 0, -1))
+}
+
+func TestOpenDB_ZPop(t *testing.T) {
+	path := t.TempDir()
+	db, err := Open(DefaultOptions(path))
+	assert.Nil(t, err)
+
+	key := []byte("q")
+	for i := 1; i <= 5; i++ {
+		assert.Nil(t, db.ZAdd(key, float64(i), []byte(fmt.Sprintf("t%d", i))))
+	}
+
+	val, err := db.ZPopMin(key, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{"t1", float64(1), "t2", float64(2)}, val)
+	val, err = db.ZPopMax(key, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{"t5", float64(5)}, val)
+	val, err = db.ZPopMin(key, 0)
+	assert.Nil(t, err)
+	assert.Nil(t, val)
+	val, err = db.ZPopMin([]byte("none"), 1)
+	assert.Nil(t, err)
+	assert.Nil(t, val)
+
+	db2, err := Open(DefaultOptions(path))
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{"t3", "t4"}, db2.ZRange(key, 0, -1))
+	val, err = db2.ZPopMax(key, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{"t4", float64(4), "t3", float64(3)}, val)
+	assert.Equal(t, 0, db2.ZCard(key))
+}
+
+func waitZSetWaiters(t *testing.T, db *OpenDB, n int) {
+	for i := 0; i < 1000; i++ {
+		db.zsetIndex.mu.RLock()
+		cnt := len(db.zsetIndex.waiters)
+		db.zsetIndex.mu.RUnlock()
+		if cnt == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("%d clients are not blocked", n)
+}
+
+func TestOpenDB_BZPop(t *testing.T) {
+	path := t.TempDir()
+	db, err := Open(DefaultOptions(path))
+	assert.Nil(t, err)
+	ctx := context.Background()
+
+	// the first non-empty sorted set is popped without blocking.
+	assert.Nil(t, db.ZAdd([]byte("z2"), 1, []byte("a")))
+	assert.Nil(t, db.ZAdd([]byte("z2"), 2, []byte("b")))
+	key, member, score, err := db.BZPopMax(ctx, time.Second, []byte("z1"), []byte("z2"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("z2"), key)
+	assert.Equal(t, []byte("b"), member)
+	assert.Equal(t, float64(2), score)
+
+	key, _, _, err = db.BZPopMin(ctx, 10*time.Millisecond, []byte("z1"))
+	assert.Nil(t, err)
+	assert.Nil(t, key)
+	_, _, _, err = db.BZPopMin(ctx, -1, []byte("z1"))
+	assert.Equal(t, ErrInvalidTTL, err)
+	_, _, _, err = db.BZPopMin(ctx, 0)
+	assert.Equal(t, ErrWrongNumberOfArgs, err)
+
+	// the waiters are served in the order they are blocked.
+	done := make(chan zsetPopResult, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			key, member, score, err := db.BZPopMin(ctx, 0, []byte("z1"), []byte("z3"))
+			done <- zsetPopResult{key, member, score, err}
+		}()
+		waitZSetWaiters(t, db, i+1)
+	}
+	n, err := db.ZAddMembers([]byte("z3"), ZAddOptions{}, ZEntry{5, []byte("x")}, ZEntry{3, []byte("y")}, ZEntry{4, []byte("z")})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, n)
+	res1, res2 := <-done, <-done
+	assert.Nil(t, res1.err)
+	assert.Nil(t, res2.err)
+	assert.ElementsMatch(t, [][]byte{[]byte("y"), []byte("z")}, [][]byte{res1.member, res2.member})
+	assert.Equal(t, []interface{}{"x"}, db.ZRange([]byte("z3"), 0, -1))
+
+	ctx2, cancel := context.WithCancel(ctx)
+	go func() {
+		waitZSetWaiters(t, db, 1)
+		cancel()
+	}()
+	_, _, _, err = db.BZPopMax(ctx2, 0, []byte("z1"))
+	assert.Equal(t, context.Canceled, err)
+	waitZSetWaiters(t, db, 0)
+
+	// every pop is persisted exactly once.
+	db2, err := Open(DefaultOptions(path))
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{"a"}, db2.ZRange([]byte("z2"), 0, -1))
+	assert.Equal(t, []interface{}{"x"}, db2.ZRange([]byte("z3"), 0, -1))
+}
+
+func TestOpenDB_ZScoreEncoding(t *testing.T) {
+	path := t.TempDir()
+	db, err := Open(DefaultOptions(path))
+	assert.Nil(t, err)
+
+	key := []byte("scores")
+	// entries written by the former versions, with the scores in decimal text.
+	db.zsetIndex.mu.Lock()
+	for _, e := range []*logfile.Entry{
+		logfile.NewEntry(key, []byte("legacy"), []byte("12345678"), ZSet, ZSetZAdd),
+		logfile.NewEntry(key, []byte("legacy-inf"), []byte("-Inf"), ZSet, ZSetZAdd),
+		logfile.NewEntry(key, []byte("legacy-nan"), []byte("NaN"), ZSet, ZSetZAdd),
+	} {
+		assert.Nil(t, db.store(e))
+	}
+	db.zsetIndex.mu.Unlock()
+
+	edges := map[string]float64{
+		"subnormal": math.SmallestNonzeroFloat64,
+		"max":       math.MaxFloat64,
+		"neg-zero":  math.Copysign(0, -1),
+		"inf":       math.Inf(1),
+		"third":     1.0 / 3,
+	}
+	for m, score := range edges {
+		assert.Nil(t, db.ZAdd(key, score, []byte(m)))
+	}
+
+	// NaN is rejected.
+	assert.Equal(t, ErrScoreIsNaN, db.ZAdd(key, math.NaN(), []byte("nan")))
+	_, err = db.ZAddMembers(key, ZAddOptions{}, ZEntry{1, []byte("one")}, ZEntry{math.NaN(), []byte("nan")})
+	assert.Equal(t, ErrScoreIsNaN, err)
+	_, err = db.ZIncrBy(key, math.Inf(-1), []byte("inf"))
+	assert.Equal(t, ErrScoreIsNaN, err)
+	_, err = db.ZRemRangeByScore(key, math.NaN(), 0)
+	assert.Equal(t, ErrScoreIsNaN, err)
+	ok, _ := db.ZScore(key, []byte("one"))
+	assert.False(t, ok)
+
+	db2, err := Open(DefaultOptions(path))
+	assert.Nil(t, err)
+	for m, score := range edges {
+		ok, got := db2.ZScore(key, []byte(m))
+		assert.True(t, ok)
+		assert.Equal(t, math.Float64bits(score), math.Float64bits(got), m)
+	}
+	_, score := db2.ZScore(key, []byte("legacy"))
+	assert.Equal(t, float64(12345678), score)
+	_, score = db2.ZScore(key, []byte("legacy-inf"))
+	assert.Equal(t, math.Inf(-1), score)
+	ok, _ = db2.ZScore(key, []byte("legacy-nan"))
+	assert.False(t, ok)
+	assert.Equal(t, 7, db2.ZCard(key))
+}
+
+func TestDecodeScore(t *testing.T) {
+	for _, score := range []float64{0, -1.5, math.Inf(-1), math.SmallestNonzeroFloat64, 1e308} {
+		buf := encodeScore(score)
+		assert.Equal(t, 9, len(buf))
+		got, err := decodeScore(buf)
+		assert.Nil(t, err)
+		assert.Equal(t, score, got)
+	}
+
+	// a decimal text of 9 bytes is still read as text.
+	got, err := decodeScore([]byte("123456789"))
+	assert.Nil(t, err)
+	assert.Equal(t, float64(123456789), got)
+	_, err = decodeScore([]byte("abc"))
+	assert.NotNil(t, err)
+}
+
+func TestDecodeZSetMembers(t *testing.T) {
+	pairs := []interface{}{"a", 1.5, "bc", math.Inf(1)}
+	got, err := decodeZSetMembers(encodeZSetMembers(pairs))
+	assert.Nil(t, err)
+	assert.Equal(t, pairs, got)
+
+	// a corrupt size near 2^64 is rejected instead of overflowing.
+	buf := make([]byte, 1+binary.MaxVarintLen64)
+	buf[0] = 1
+	buf = buf[:1+binary.PutUvarint(buf[1:], math.MaxUint64-3)]
+	buf = append(buf, make([]byte, 16)...)
+	_, err = decodeZSetMembers(buf)
+	assert.Equal(t, errInvalidZSetMembers, err)
+
+	// the score is truncated.
+	buf = []byte{1, 1, 'a', 0, 0}
+	_, err = decodeZSetMembers(buf)
+	assert.Equal(t, errInvalidZSetMembers, err)
+}
+
+func TestOpenDB_ZAddExpired(t *testing.T) {
+	path := t.TempDir()
+	db, err := Open(DefaultOptions(path))
+	assert.Nil(t, err)
+	key := []byte("zset")
+	assert.Nil(t, db.ZAdd(key, 1, []byte("old")))
+	db.expires[ZSet][string(key)] = time.Now().Unix() - 1
+
+	// the waiter is blocked on the expired sorted set, which is empty.
+	done := make(chan []byte)
+	go func() {
+		_, member, _, _ := db.BZPopMin(context.Background(), 0, key)
+		done <- member
+	}()
+	waitZSetWaiters(t, db, 1)
+
+	// adding to an expired sorted set starts a new one without the old members and time to live.
+	assert.Nil(t, db.ZAdd(key, 2, []byte("new")))
+	select {
+	case member := <-done:
+		assert.Equal(t, []byte("new"), member)
+	case <-time.After(time.Second):
+		t.Fatal("the waiter is not woken")
+	}
+
+	n, err := db.GeoAdd(key, GeoLocation{Longitude: 13.361389, Latitude: 38.115556, Member: []byte("Palermo")})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+	db.expires[ZSet][string(key)] = time.Now().Unix() - 1
+	n, err = db.ZAddMembers(key, ZAddOptions{}, ZEntry{Score: 3, Member: []byte("a")}, ZEntry{Score: 4, Member: []byte("b")})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+
+	db2, err := Open(DefaultOptions(path))
+	assert.Nil(t, err)
+	for _, d := range []*OpenDB{db, db2} {
+		assert.Equal(t, []interface{}{"a", "b"}, d.ZRange(key, 0, -1))
+		assert.Equal(t, int64(0), d.ZTTL(key))
+	}
+}